@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/backup"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+// checkStorage rejects backends this build cannot run.
+func checkStorage(config StorageConf) error {
+	switch config.Type {
+	case "memory":
+		return nil
+	case "sql":
+		return errors.New("sql storage is not implemented yet, use memory")
+	default:
+		return fmt.Errorf("unknown storage type %q", config.Type)
+	}
+}
+
+// runBackup writes all data of the configured storage to the -out archive.
+// The memory storage is read from its snapshot, so it is up to date only
+// while the service is stopped.
+func runBackup(ctx context.Context, config Config, args []string) error {
+	fset := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fset.String("out", "", "Archive to write, - for stdout")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" || fset.NArg() != 0 {
+		return errors.New("usage: calendar backup -out FILE")
+	}
+	if err := checkStorage(config.Storage); err != nil {
+		return err
+	}
+	if config.Storage.Snapshot == "" {
+		return errors.New("memory storage without storage.snapshot keeps no data outside the service")
+	}
+
+	data := newData(config)
+	if _, err := loadSnapshot(ctx, config.Storage.Snapshot, data.sinks()); err != nil {
+		return err
+	}
+
+	var stats backup.Stats
+	err := writeFile(*out, func(w io.Writer) (err error) {
+		stats, err = backup.Dump(ctx, w, data.sources())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "backed up "+stats.String())
+	return nil
+}
+
+// runRestore replaces the data of the configured storage with the -in
+// archive. For the memory storage it becomes the new snapshot, the service
+// must be stopped meanwhile or it overwrites the snapshot on exit.
+func runRestore(ctx context.Context, config Config, args []string) error {
+	fset := flag.NewFlagSet("restore", flag.ContinueOnError)
+	in := fset.String("in", "", "Archive to read, - for stdin")
+	force := fset.Bool("force", false, "Replace an existing snapshot")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" || fset.NArg() != 0 {
+		return errors.New("usage: calendar restore -in FILE [-force]")
+	}
+	if err := checkStorage(config.Storage); err != nil {
+		return err
+	}
+	if config.Storage.Snapshot == "" {
+		return errors.New("memory storage without storage.snapshot has nowhere to restore to")
+	}
+	if _, err := os.Stat(config.Storage.Snapshot); err == nil && !*force {
+		return fmt.Errorf("snapshot %s exists, pass -force to replace it", config.Storage.Snapshot)
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		file, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	data := newData(config)
+	stats, err := backup.Load(ctx, r, data.sinks())
+	if err != nil {
+		return err
+	}
+	if _, err := saveSnapshot(ctx, config.Storage.Snapshot, data.sources()); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "restored "+stats.String())
+	return nil
+}
+
+// data holds the stores kept in the snapshot.
+type data struct {
+	storage       *memorystorage.Storage
+	webhooks      *webhook.Dispatcher
+	subscriptions *subscription.Manager
+	availability  *availability.Store
+	digests       *digest.Store
+}
+
+// newData creates empty stores for the backup and restore commands.
+func newData(config Config) data {
+	logg := logger.NewWithWriter(config.Logger.Level, os.Stderr)
+	return data{
+		storage:       memorystorage.New(),
+		webhooks:      webhook.NewDispatcher(logg, webhook.Config(config.Webhooks)),
+		subscriptions: subscription.NewManager(logg, subscription.Config(config.Subscriptions)),
+		availability:  availability.NewStore(),
+		digests:       digest.NewStore(),
+	}
+}
+
+func (d data) sources() backup.Sources {
+	return backup.Sources{
+		Events:        d.storage,
+		Webhooks:      d.webhooks,
+		Subscriptions: d.subscriptions,
+		Availability:  d.availability,
+		Digests:       d.digests,
+	}
+}
+
+func (d data) sinks() backup.Sinks {
+	return backup.Sinks{
+		Events:        d.storage,
+		Webhooks:      d.webhooks,
+		Subscriptions: d.subscriptions,
+		Availability:  d.availability,
+		Digests:       d.digests,
+	}
+}
+
+// loadSnapshot fills the memory storage from the snapshot archive, a
+// missing snapshot leaves it empty.
+func loadSnapshot(ctx context.Context, path string, dst backup.Sinks) (backup.Stats, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return backup.Stats{}, nil
+	}
+	if err != nil {
+		return backup.Stats{}, err
+	}
+	defer file.Close()
+
+	stats, err := backup.Load(ctx, file, dst)
+	if err != nil {
+		return stats, fmt.Errorf("load snapshot %s: %w", path, err)
+	}
+	return stats, nil
+}
+
+// saveSnapshot replaces the snapshot archive atomically.
+func saveSnapshot(ctx context.Context, path string, src backup.Sources) (stats backup.Stats, err error) {
+	err = writeFile(path, func(w io.Writer) error {
+		stats, err = backup.Dump(ctx, w, src)
+		return err
+	})
+	if err != nil {
+		return stats, fmt.Errorf("save snapshot %s: %w", path, err)
+	}
+	return stats, nil
+}
+
+// writeFile writes to a temporary file renamed over path on success, or to
+// stdout when path is -.
+func writeFile(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,20 +1,204 @@
 package main
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
 // При желании конфигурацию можно вынести в internal/config.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger LoggerConf
-	// TODO
+	Logger        LoggerConf
+	HTTP          HTTPConf
+	Admin         AdminConf
+	Metrics       MetricsConf
+	Shutdown      ShutdownConf
+	Feed          FeedConf
+	Webhooks      WebhooksConf
+	Tracing       TracingConf
+	Health        HealthConf
+	RateLimit     RateLimitConf
+	Auth          AuthConf
+	Cleanup       CleanupConf
+	Reminders     RemindersConf
+	Storage       StorageConf
+	Cache         CacheConf
+	Subscriptions SubscriptionsConf
+	Digests       DigestsConf
+	Tenants       TenantsConf
+	Attachments   AttachmentsConf
 }
 
 type LoggerConf struct {
 	Level string
-	// TODO
 }
 
-func NewConfig() Config {
-	return Config{}
+type HTTPConf struct {
+	Host        string
+	Port        string
+	MaxBodySize int64 `toml:"max_body_size"`
+}
+
+type FeedConf struct {
+	BufferSize       int `toml:"buffer_size"`
+	SubscriberBuffer int `toml:"subscriber_buffer"`
+}
+
+type WebhooksConf struct {
+	Workers       int
+	QueueSize     int           `toml:"queue_size"`
+	MaxAttempts   int           `toml:"max_attempts"`
+	RetryInterval time.Duration `toml:"retry_interval"`
+	Timeout       time.Duration
+	LogSize       int  `toml:"log_size"`
+	AllowPrivate  bool `toml:"allow_private"`
+}
+
+type TracingConf struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	ServiceName string  `toml:"service_name"`
+	SampleRatio float64 `toml:"sample_ratio"`
+}
+
+type HealthConf struct {
+	Timeout time.Duration
+}
+
+type AuthConf struct {
+	Mode      string
+	Secret    string
+	JWKSFile  string `toml:"jwks_file"`
+	UserClaim string `toml:"user_claim"`
+	OrgClaim  string `toml:"org_claim"`
+	Issuer    string
+	Audience  string
+	Leeway    time.Duration
+}
+
+type CleanupConf struct {
+	Interval       time.Duration
+	TrashRetention time.Duration `toml:"trash_retention"`
+	EventRetention time.Duration `toml:"event_retention"`
+}
+
+type RemindersConf struct {
+	Interval time.Duration
+}
+
+type SubscriptionsConf struct {
+	Interval     time.Duration
+	Timeout      time.Duration
+	MaxSize      int64 `toml:"max_size"`
+	Dir          string
+	AllowPrivate bool `toml:"allow_private"`
+}
+
+type DigestsConf struct {
+	Interval  time.Duration
+	Templates string
 }
 
-// TODO
+// AdminConf configures the operator API, it is off while Port is empty.
+type AdminConf struct {
+	Host  string
+	Port  string
+	Token string
+}
+
+// MetricsConf adds a listener serving only /metrics without a token, it is
+// off while Port is empty. The admin listener serves /metrics either way.
+type MetricsConf struct {
+	Host string
+	Port string
+}
+
+type ShutdownConf struct {
+	// StopTimeout bounds draining requests, stopping workers and each closer.
+	StopTimeout time.Duration `toml:"stop_timeout"`
+}
+
+type AttachmentsConf struct {
+	Dir     string
+	MaxSize int64 `toml:"max_size"`
+	Types   []string
+}
+
+type TenantsConf struct {
+	RequireOrg   bool `toml:"require_org"`
+	MaxEvents    int  `toml:"max_events"`
+	MaxCalendars int  `toml:"max_calendars"`
+	// Orgs override the quotas above for single organizations.
+	Orgs map[string]QuotaConf
+}
+
+type QuotaConf struct {
+	MaxEvents    int `toml:"max_events"`
+	MaxCalendars int `toml:"max_calendars"`
+}
+
+type StorageConf struct {
+	Type     string
+	Snapshot string
+}
+
+type CacheConf struct {
+	Size int
+	TTL  time.Duration
+}
+
+type RateLimitConf struct {
+	Rate  float64
+	Burst int
+}
+
+func NewConfig(path string) (Config, error) {
+	config := Config{
+		Logger:   LoggerConf{Level: "INFO"},
+		HTTP:     HTTPConf{Host: "0.0.0.0", Port: "8888", MaxBodySize: 1 << 20},
+		Admin:    AdminConf{Host: "127.0.0.1"},
+		Metrics:  MetricsConf{Host: "0.0.0.0"},
+		Shutdown: ShutdownConf{StopTimeout: 10 * time.Second},
+		Feed:     FeedConf{BufferSize: 1000, SubscriberBuffer: 64},
+		Webhooks: WebhooksConf{
+			Workers:       4,
+			QueueSize:     100,
+			MaxAttempts:   5,
+			RetryInterval: time.Second,
+			Timeout:       5 * time.Second,
+			LogSize:       50,
+		},
+		Tracing:   TracingConf{Exporter: "none", ServiceName: "calendar", SampleRatio: 1},
+		Health:    HealthConf{Timeout: 2 * time.Second},
+		RateLimit: RateLimitConf{Rate: 10, Burst: 20},
+		Auth:      AuthConf{Mode: "header", UserClaim: "sub", OrgClaim: "org", Leeway: 30 * time.Second},
+		Cleanup:   CleanupConf{Interval: time.Hour, TrashRetention: 30 * 24 * time.Hour},
+		Reminders: RemindersConf{Interval: 10 * time.Second},
+		Storage:   StorageConf{Type: "memory"},
+		Cache:     CacheConf{Size: 10000, TTL: time.Minute},
+		Subscriptions: SubscriptionsConf{
+			Interval: 6 * time.Hour,
+			Timeout:  30 * time.Second,
+			MaxSize:  1 << 20,
+		},
+		Digests: DigestsConf{Interval: time.Minute},
+		Attachments: AttachmentsConf{
+			MaxSize: 10 << 20,
+			Types: []string{
+				"text/plain", "text/markdown", "text/calendar", "application/pdf", "image/*",
+				"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+				"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+				"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+			},
+		},
+	}
+
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return Config{}, fmt.Errorf("read config %s: %w", path, err)
+	}
+	return config, nil
+}
@@ -3,15 +3,28 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cache"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cleanup"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/lifecycle"
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/admin"
 	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
-	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/service"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
 )
 
 var configFile string
@@ -28,34 +41,174 @@ func main() {
 		return
 	}
 
-	config := NewConfig()
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch command := flag.Arg(0); command {
+	case "backup", "restore":
+		run := runBackup
+		if command == "restore" {
+			run = runRestore
+		}
+		if err := run(context.Background(), config, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, want version, backup or restore\n", command)
+		os.Exit(2)
+	}
+
+	// SIGHUP is caught for the whole life of the process, the reload worker
+	// only handles it. Sent while starting or stopping, it must not kill the
+	// process before the snapshot is saved.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
 	logg := logger.New(config.Logger.Level)
+	if err := checkStorage(config.Storage); err != nil {
+		logg.Error("failed to set up storage: " + err.Error())
+		os.Exit(1)
+	}
 
-	storage := memorystorage.New()
-	calendar := app.New(logg, storage)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config(config.Tracing))
+	if err != nil {
+		logg.Error("failed to set up tracing: " + err.Error())
+		os.Exit(1)
+	}
+
+	authenticator, err := newAuthenticator(config.Auth)
+	if err != nil {
+		logg.Error("failed to set up auth: " + err.Error())
+		os.Exit(1)
+	}
+	blobs, err := newBlobStore(config.Attachments.Dir)
+	if err != nil {
+		logg.Error("failed to set up attachments: " + err.Error())
+		os.Exit(1)
+	}
+	calendar, err := service.New(logg, newServiceConfig(config), authenticator, blobs)
+	if err != nil {
+		logg.Error("failed to set up calendar: " + err.Error())
+		os.Exit(1)
+	}
+	if path := config.Storage.Snapshot; path != "" {
+		stats, err := loadSnapshot(context.Background(), path, calendar.Sinks())
+		if err != nil {
+			logg.Error(err.Error())
+			os.Exit(1)
+		}
+		logg.Info(fmt.Sprintf("loaded snapshot %s: %s", path, stats))
+	}
+
+	group := calendar.Group
+	// Closers run in reverse: the snapshot is saved before traces are flushed.
+	group.AddCloser("tracing", shutdownTracing)
+	if path := config.Storage.Snapshot; path != "" {
+		group.AddCloser("snapshot", func(ctx context.Context) error {
+			stats, err := saveSnapshot(ctx, path, calendar.Sources())
+			if err != nil {
+				return err
+			}
+			logg.Info(fmt.Sprintf("saved snapshot %s: %s", path, stats))
+			return nil
+		})
+	}
 
-	server := internalhttp.NewServer(logg, calendar)
+	group.AddWorker("reload", service.Loop(func(ctx context.Context) {
+		watchReload(ctx, hup, config, reloadTargets{
+			logger:        logg,
+			webhooks:      calendar.Webhooks,
+			limiter:       calendar.Limiter,
+			cleaner:       calendar.Cleaner,
+			reminders:     calendar.Reminders,
+			subscriptions: calendar.Subscriptions,
+			digests:       calendar.Digests,
+			attachments:   calendar.Attachments,
+			cache:         calendar.Cache,
+		})
+	}))
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	go func() {
-		<-ctx.Done()
+	logg.Info("calendar is running...")
+	if err := group.Run(ctx); err != nil {
+		cancel()
+		os.Exit(1) //nolint:gocritic
+	}
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+func newAuthenticator(config AuthConf) (internalhttp.Authenticator, error) {
+	switch config.Mode {
+	case "header":
+		return auth.Header{}, nil
+	case "jwt":
+		return auth.NewJWT(auth.JWTConfig{
+			Secret:    config.Secret,
+			JWKSFile:  config.JWKSFile,
+			UserClaim: config.UserClaim,
+			OrgClaim:  config.OrgClaim,
+			Issuer:    config.Issuer,
+			Audience:  config.Audience,
+			Leeway:    config.Leeway,
+		})
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", config.Mode)
+	}
+}
 
-		if err := server.Stop(ctx); err != nil {
-			logg.Error("failed to stop http server: " + err.Error())
-		}
-	}()
+// newBlobStore keeps attachments in dir, or in memory when it is empty.
+func newBlobStore(dir string) (attachment.BlobStore, error) {
+	if dir == "" {
+		return attachment.NewMemory(), nil
+	}
+	return attachment.NewFS(dir)
+}
 
-	logg.Info("calendar is running...")
+func newAttachmentConfig(config AttachmentsConf) attachment.Config {
+	return attachment.Config{MaxSize: config.MaxSize, Types: config.Types}
+}
 
-	if err := server.Start(ctx); err != nil {
-		logg.Error("failed to start http server: " + err.Error())
-		cancel()
-		os.Exit(1) //nolint:gocritic
+// newServiceConfig converts the sections configuring the components of the
+// service, the rest is handled by main.
+func newServiceConfig(config Config) service.Config {
+	return service.Config{
+		HTTP:     internalhttp.Config(config.HTTP),
+		Admin:    admin.Config(config.Admin),
+		Metrics:  service.MetricsConfig(config.Metrics),
+		Shutdown: lifecycle.Config(config.Shutdown),
+		Feed:     service.FeedConfig(config.Feed),
+		Webhooks: webhook.Config(config.Webhooks),
+		Health: service.HealthConfig{
+			Timeout: config.Health.Timeout,
+			Version: health.Version{Release: release, BuildDate: buildDate, GitHash: gitHash},
+		},
+		RateLimit:     ratelimit.Config(config.RateLimit),
+		Cleanup:       cleanup.Config(config.Cleanup),
+		Reminders:     reminder.Config(config.Reminders),
+		Cache:         cache.Config(config.Cache),
+		Subscriptions: subscription.Config(config.Subscriptions),
+		Digests:       digest.Config(config.Digests),
+		Tenants:       newTenants(config.Tenants),
+		Attachments:   newAttachmentConfig(config.Attachments),
+	}
+}
+
+func newTenants(config TenantsConf) tenant.Config {
+	tenants := tenant.Config{
+		RequireOrg: config.RequireOrg,
+		Default:    tenant.Quota{MaxEvents: config.MaxEvents, MaxCalendars: config.MaxCalendars},
+		Orgs:       make(map[string]tenant.Quota, len(config.Orgs)),
+	}
+	for orgID, quota := range config.Orgs {
+		tenants.Orgs[orgID] = tenant.Quota(quota)
 	}
+	return tenants
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cache"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cleanup"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+type reloadTargets struct {
+	logger        *logger.Logger
+	webhooks      *webhook.Dispatcher
+	limiter       *ratelimit.Limiter
+	cleaner       *cleanup.Cleaner
+	reminders     *reminder.Worker
+	subscriptions *subscription.Manager
+	digests       *digest.Worker
+	attachments   *attachment.Store
+	cache         *cache.CachedStorage
+}
+
+// watchReload reloads the config file on every signal from hup until ctx
+// is done.
+func watchReload(ctx context.Context, hup <-chan os.Signal, running Config, targets reloadTargets) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			next, err := reload(configFile, running, targets)
+			if err != nil {
+				targets.logger.Error("failed to reload config, keeping the current one: " + err.Error())
+				continue
+			}
+			running = next
+		}
+	}
+}
+
+// mergeReload returns the config the process runs with after a reload:
+// reloadable settings are taken from loaded, the rest stays as running.
+// It also lists which settings were applied and which need a restart.
+func mergeReload(running, loaded Config) (next Config, applied, restart []string) {
+	next = running
+
+	if loaded.Logger != running.Logger {
+		next.Logger = loaded.Logger
+		applied = append(applied, "logger.level")
+	}
+
+	rw, lw := running.Webhooks, loaded.Webhooks
+	if lw.MaxAttempts != rw.MaxAttempts || lw.RetryInterval != rw.RetryInterval || lw.Timeout != rw.Timeout ||
+		lw.AllowPrivate != rw.AllowPrivate {
+		next.Webhooks.MaxAttempts = lw.MaxAttempts
+		next.Webhooks.RetryInterval = lw.RetryInterval
+		next.Webhooks.Timeout = lw.Timeout
+		next.Webhooks.AllowPrivate = lw.AllowPrivate
+		applied = append(applied,
+			"webhooks.max_attempts", "webhooks.retry_interval", "webhooks.timeout", "webhooks.allow_private")
+	}
+	if lw.Workers != rw.Workers || lw.QueueSize != rw.QueueSize || lw.LogSize != rw.LogSize {
+		restart = append(restart, "webhooks.workers", "webhooks.queue_size", "webhooks.log_size")
+	}
+
+	if loaded.RateLimit != running.RateLimit {
+		next.RateLimit = loaded.RateLimit
+		applied = append(applied, "ratelimit")
+	}
+
+	if loaded.HTTP != running.HTTP {
+		restart = append(restart, "http")
+	}
+	if loaded.Admin != running.Admin {
+		restart = append(restart, "admin")
+	}
+	if loaded.Metrics != running.Metrics {
+		restart = append(restart, "metrics")
+	}
+	if loaded.Shutdown != running.Shutdown {
+		restart = append(restart, "shutdown")
+	}
+	if loaded.Feed != running.Feed {
+		restart = append(restart, "feed")
+	}
+	if loaded.Tracing != running.Tracing {
+		restart = append(restart, "tracing")
+	}
+	if loaded.Cleanup != running.Cleanup {
+		next.Cleanup = loaded.Cleanup
+		applied = append(applied, "cleanup")
+	}
+
+	if loaded.Reminders != running.Reminders {
+		next.Reminders = loaded.Reminders
+		applied = append(applied, "reminders")
+	}
+
+	if loaded.Subscriptions != running.Subscriptions {
+		next.Subscriptions = loaded.Subscriptions
+		applied = append(applied, "subscriptions")
+	}
+
+	if loaded.Digests.Interval != running.Digests.Interval {
+		next.Digests.Interval = loaded.Digests.Interval
+		applied = append(applied, "digests.interval")
+	}
+	if loaded.Digests.Templates != running.Digests.Templates {
+		restart = append(restart, "digests.templates")
+	}
+
+	ra, la := running.Attachments, loaded.Attachments
+	if la.MaxSize != ra.MaxSize || !slices.Equal(la.Types, ra.Types) {
+		next.Attachments.MaxSize = la.MaxSize
+		next.Attachments.Types = la.Types
+		applied = append(applied, "attachments.max_size", "attachments.types")
+	}
+	if la.Dir != ra.Dir {
+		restart = append(restart, "attachments.dir")
+	}
+
+	if loaded.Auth != running.Auth {
+		restart = append(restart, "auth")
+	}
+	if !reflect.DeepEqual(loaded.Tenants, running.Tenants) {
+		restart = append(restart, "tenants")
+	}
+	if loaded.Health != running.Health {
+		restart = append(restart, "health")
+	}
+	if loaded.Storage != running.Storage {
+		restart = append(restart, "storage")
+	}
+	if loaded.Cache != running.Cache {
+		next.Cache = loaded.Cache
+		applied = append(applied, "cache")
+	}
+	return next, applied, restart
+}
+
+// reload re-reads the config file and applies reloadable settings.
+// On error the running config is kept.
+func reload(path string, running Config, targets reloadTargets) (Config, error) {
+	loaded, err := NewConfig(path)
+	if err != nil {
+		return running, err
+	}
+	if _, err := logger.ParseLevel(loaded.Logger.Level); err != nil {
+		return running, fmt.Errorf("logger: %w", err)
+	}
+
+	next, applied, restart := mergeReload(running, loaded)
+
+	_ = targets.logger.SetLevel(next.Logger.Level)
+	targets.webhooks.Reconfigure(webhook.Config(next.Webhooks))
+	targets.limiter.Reconfigure(ratelimit.Config(next.RateLimit))
+	targets.cleaner.Reconfigure(cleanup.Config(next.Cleanup))
+	targets.reminders.Reconfigure(reminder.Config(next.Reminders))
+	targets.subscriptions.Reconfigure(subscription.Config(next.Subscriptions))
+	targets.digests.Reconfigure(digest.Config(next.Digests))
+	targets.attachments.Reconfigure(newAttachmentConfig(next.Attachments))
+	targets.cache.Reconfigure(cache.Config(next.Cache))
+
+	if len(applied) == 0 {
+		targets.logger.Info("config reloaded, nothing changed")
+	} else {
+		targets.logger.Info("config reloaded, applied: " + strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		targets.logger.Warn("config changes ignored until restart: " + strings.Join(restart, ", "))
+	}
+	return next, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeReload(t *testing.T) {
+	running := Config{
+		Logger:   LoggerConf{Level: "INFO"},
+		HTTP:     HTTPConf{Host: "0.0.0.0", Port: "8888"},
+		Webhooks: WebhooksConf{Workers: 4, MaxAttempts: 5, RetryInterval: time.Second},
+	}
+
+	t.Run("nothing changed", func(t *testing.T) {
+		next, applied, restart := mergeReload(running, running)
+		require.Equal(t, running, next)
+		require.Empty(t, applied)
+		require.Empty(t, restart)
+	})
+
+	t.Run("reloadable and restart-only changes", func(t *testing.T) {
+		loaded := running
+		loaded.Logger.Level = "DEBUG"
+		loaded.Webhooks.MaxAttempts = 2
+		loaded.Webhooks.Workers = 8
+		loaded.HTTP.Port = "9999"
+		loaded.Admin.Token = "rotated"
+		loaded.Metrics.Port = "9100"
+		loaded.Shutdown.StopTimeout = time.Minute
+
+		next, applied, restart := mergeReload(running, loaded)
+		require.Equal(t, "DEBUG", next.Logger.Level)
+		require.Equal(t, 2, next.Webhooks.MaxAttempts)
+		require.Equal(t, 4, next.Webhooks.Workers)
+		require.Equal(t, "8888", next.HTTP.Port)
+		require.Contains(t, applied, "logger.level")
+		require.Contains(t, applied, "webhooks.max_attempts")
+		require.Contains(t, restart, "webhooks.workers")
+		require.Contains(t, restart, "http")
+		require.Contains(t, restart, "admin")
+		require.Contains(t, restart, "metrics")
+		require.Contains(t, restart, "shutdown")
+	})
+	t.Run("digest templates need a restart", func(t *testing.T) {
+		loaded := running
+		loaded.Digests = DigestsConf{Interval: time.Hour, Templates: "/etc/calendar/digest"}
+
+		next, applied, restart := mergeReload(running, loaded)
+		require.Equal(t, DigestsConf{Interval: time.Hour}, next.Digests)
+		require.Equal(t, []string{"digests.interval"}, applied)
+		require.Equal(t, []string{"digests.templates"}, restart)
+	})
+	t.Run("attachment limits", func(t *testing.T) {
+		loaded := running
+		loaded.Attachments = AttachmentsConf{Dir: "/var/lib/calendar", MaxSize: 1 << 20, Types: []string{"image/*"}}
+
+		next, applied, restart := mergeReload(running, loaded)
+		require.Equal(t, AttachmentsConf{MaxSize: 1 << 20, Types: []string{"image/*"}}, next.Attachments)
+		require.Equal(t, []string{"attachments.max_size", "attachments.types"}, applied)
+		require.Equal(t, []string{"attachments.dir"}, restart)
+	})
+	t.Run("cache", func(t *testing.T) {
+		loaded := running
+		loaded.Cache = CacheConf{Size: 100, TTL: 10 * time.Second}
+
+		next, applied, restart := mergeReload(running, loaded)
+		require.Equal(t, loaded.Cache, next.Cache)
+		require.Equal(t, []string{"cache"}, applied)
+		require.Empty(t, restart)
+	})
+	t.Run("tenants need a restart", func(t *testing.T) {
+		loaded := running
+		loaded.Tenants = TenantsConf{Orgs: map[string]QuotaConf{"acme": {MaxEvents: 100}}}
+
+		next, applied, restart := mergeReload(running, loaded)
+		require.Equal(t, running.Tenants, next.Tenants)
+		require.Empty(t, applied)
+		require.Equal(t, []string{"tenants"}, restart)
+	})
+}
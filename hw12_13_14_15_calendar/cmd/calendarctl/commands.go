@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/client"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+)
+
+var errUsage = errors.New("invalid arguments")
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+
+const dateLayout = "2006-01-02"
+
+// importBatchSize matches the largest batch the server accepts.
+const importBatchSize = 100
+
+type cli struct {
+	api    *client.Client
+	output string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func newCLI(config Config, stdin io.Reader, stdout, stderr io.Writer) (*cli, error) {
+	switch config.Transport {
+	case "http":
+	case "grpc":
+		return nil, errors.New("grpc transport is not supported by this build, use -transport http")
+	default:
+		return nil, fmt.Errorf("unknown transport %q", config.Transport)
+	}
+
+	return &cli{
+		api: client.New(client.Config{
+			BaseURL: config.URL,
+			UserID:  config.User,
+			OrgID:   config.Org,
+			Token:   config.Token,
+			Timeout: config.Timeout,
+		}),
+		output: config.Output,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}, nil
+}
+
+// eventFlags are shared by create and update.
+type eventFlags struct {
+	fs          *flag.FlagSet
+	title       string
+	start       string
+	end         string
+	description string
+	notify      time.Duration
+	reminders   []client.Reminder
+}
+
+func newEventFlags(name string, stderr io.Writer) *eventFlags {
+	f := &eventFlags{fs: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.fs.SetOutput(stderr)
+	f.fs.StringVar(&f.title, "title", "", "Event title")
+	f.fs.StringVar(&f.start, "start", "", "Start time, RFC 3339 or local YYYY-MM-DD HH:MM")
+	f.fs.StringVar(&f.end, "end", "", "End time, RFC 3339 or local YYYY-MM-DD HH:MM")
+	f.fs.StringVar(&f.description, "description", "", "Event description")
+	f.fs.DurationVar(&f.notify, "notify", 0, "Notify before the start, e.g. 15m")
+	f.fs.Func("remind", "Reminder BEFORE[:CHANNEL], e.g. 24h:log, repeatable, replaces the event reminders",
+		func(value string) error {
+			reminder, err := parseReminder(value)
+			if err != nil {
+				return err
+			}
+			f.reminders = append(f.reminders, reminder)
+			return nil
+		})
+	return f
+}
+
+// parseReminder reads BEFORE[:CHANNEL], the server picks the channel when it is omitted.
+func parseReminder(value string) (client.Reminder, error) {
+	before, channel, _ := strings.Cut(value, ":")
+	d, err := time.ParseDuration(before)
+	if err != nil {
+		return client.Reminder{}, err
+	}
+	return client.Reminder{Before: d, Channel: channel}, nil
+}
+
+// apply copies the flags given on the command line onto event.
+func (f *eventFlags) apply(event *client.Event) error {
+	var err error
+	f.fs.Visit(func(fl *flag.Flag) {
+		if err != nil {
+			return
+		}
+		switch fl.Name {
+		case "title":
+			event.Title = f.title
+		case "start":
+			event.StartAt, err = parseTime(f.start)
+		case "end":
+			event.EndAt, err = parseTime(f.end)
+		case "description":
+			event.Description = f.description
+		case "notify":
+			event.NotifyBefore = f.notify
+		case "remind":
+			event.Reminders = f.reminders
+		}
+	})
+	return err
+}
+
+func runCreate(ctx context.Context, c *cli, args []string) error {
+	f := newEventFlags("create", c.stderr)
+	if err := f.fs.Parse(args); err != nil || f.fs.NArg() != 0 {
+		return errUsage
+	}
+
+	var event client.Event
+	if err := f.apply(&event); err != nil {
+		return err
+	}
+	created, err := c.api.Create(ctx, event)
+	if err != nil {
+		return err
+	}
+	return printEvent(c.stdout, c.output, created)
+}
+
+func runUpdate(ctx context.Context, c *cli, args []string) error {
+	f := newEventFlags("update", c.stderr)
+	if err := f.fs.Parse(args); err != nil || f.fs.NArg() != 1 {
+		return errUsage
+	}
+	id := f.fs.Arg(0)
+
+	event, err := c.api.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := f.apply(&event); err != nil {
+		return err
+	}
+	updated, err := c.api.Update(ctx, id, event)
+	if err != nil {
+		return err
+	}
+	return printEvent(c.stdout, c.output, updated)
+}
+
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return c.api.Delete(ctx, args[0])
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	event, err := c.api.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printEvent(c.stdout, c.output, event)
+}
+
+func runList(period string) func(ctx context.Context, c *cli, args []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		events, err := c.list(ctx, period, args[0])
+		if err != nil {
+			return err
+		}
+		return printEvents(c.stdout, c.output, events)
+	}
+}
+
+func (c *cli) list(ctx context.Context, period, date string) ([]client.Event, error) {
+	day, err := time.ParseInLocation(dateLayout, date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("date must be in YYYY-MM-DD format: %w", errUsage)
+	}
+
+	switch period {
+	case "day":
+		return c.api.Day(ctx, day)
+	case "week":
+		return c.api.Week(ctx, day)
+	case "month":
+		return c.api.Month(ctx, day)
+	default:
+		return nil, fmt.Errorf("unknown period %q: %w", period, errUsage)
+	}
+}
+
+// runImport creates an event for every VEVENT of the file. Failed events are
+// reported and do not stop the import.
+func runImport(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	in := c.stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	events, err := ical.Decode(in)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for start := 0; start < len(events); start += importBatchSize {
+		chunk := events[start:min(start+importBatchSize, len(events))]
+		ops := make([]client.Operation, 0, len(chunk))
+		for _, event := range chunk {
+			ops = append(ops, client.Operation{Op: "create", Event: client.Event{
+				Title:        event.Summary,
+				StartAt:      event.Start,
+				EndAt:        event.End,
+				Description:  event.Description,
+				NotifyBefore: event.Alarm,
+			}})
+		}
+
+		results, err := c.api.Batch(ctx, ops, false)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result.Err != nil {
+				failed++
+				fmt.Fprintf(c.stderr, "skip %q at %s: %s\n",
+					chunk[i].Summary, chunk[i].Start.Format(time.RFC3339), result.Err)
+			}
+		}
+	}
+
+	fmt.Fprintf(c.stdout, "imported %d of %d events\n", len(events)-failed, len(events))
+	if failed > 0 {
+		return fmt.Errorf("%d events failed", failed)
+	}
+	return nil
+}
+
+// runExport writes events of the period as iCalendar whatever the output format.
+func runExport(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	out := fs.String("out", "-", "Output file, - for stdout")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+
+	events, err := c.list(ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		return ical.Encode(c.stdout, toICal(events))
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := ical.Encode(file, toICal(events)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want RFC 3339 or YYYY-MM-DD HH:MM", value)
+}
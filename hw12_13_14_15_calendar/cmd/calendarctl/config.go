@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is read from the file first, then overridden by CALENDARCTL_*
+// environment variables and finally by command-line flags.
+type Config struct {
+	URL       string
+	User      string
+	Org       string
+	Token     string
+	Output    string
+	Transport string
+	Timeout   time.Duration
+}
+
+const envPrefix = "CALENDARCTL_"
+
+func defaultConfig() Config {
+	return Config{
+		URL:       "http://localhost:8888",
+		Output:    "table",
+		Transport: "http",
+		Timeout:   10 * time.Second,
+	}
+}
+
+func defaultConfigPath(getenv func(string) string) string {
+	if path := getenv(envPrefix + "CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "calendarctl", "config.toml")
+}
+
+// loadConfig reads path over the defaults. A missing file is not an error
+// unless the path was given explicitly.
+func loadConfig(path string, explicit bool, getenv func(string) string) (Config, error) {
+	config := defaultConfig()
+
+	if path != "" {
+		_, err := toml.DecodeFile(path, &config)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return Config{}, fmt.Errorf("read config %s: %w", path, err)
+		}
+	}
+
+	for key, field := range map[string]*string{
+		"URL":       &config.URL,
+		"USER":      &config.User,
+		"ORG":       &config.Org,
+		"TOKEN":     &config.Token,
+		"OUTPUT":    &config.Output,
+		"TRANSPORT": &config.Transport,
+	} {
+		if value := getenv(envPrefix + key); value != "" {
+			*field = value
+		}
+	}
+	if value := getenv(envPrefix + "TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("%sTIMEOUT: %w", envPrefix, err)
+		}
+		config.Timeout = d
+	}
+	return config, nil
+}
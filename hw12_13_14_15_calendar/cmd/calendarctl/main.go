@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, cli *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"create": {
+		"create -title T -start TIME -end TIME [-description D] [-notify 15m] [-remind 24h:log]...",
+		runCreate,
+	},
+	"update": {
+		"update [-title T] [-start TIME] [-end TIME] [-description D] [-notify 15m] [-remind 24h:log]... ID",
+		runUpdate,
+	},
+	"delete": {"delete ID", runDelete},
+	"get":    {"get ID", runGet},
+	"day":    {"day DATE", runList("day")},
+	"week":   {"week DATE", runList("week")},
+	"month":  {"month DATE", runList("month")},
+	"import": {"import FILE.ics|-", runImport},
+	"export": {"export [-out FILE] day|week|month DATE", runExport},
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv)
+	cancel()
+	os.Exit(code)
+}
+
+func run(
+	ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string,
+) int {
+	fs := flag.NewFlagSet("calendarctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs, stderr) }
+
+	configPath := fs.String("config", defaultConfigPath(getenv), "Path to configuration file")
+	url := fs.String("url", "", "Calendar API address")
+	user := fs.String("user", "", "User ID for the header auth mode")
+	org := fs.String("org", "", "Organization ID for the header auth mode")
+	token := fs.String("token", "", "Bearer token for the jwt auth mode")
+	output := fs.String("o", "", "Output format: table, json or ics")
+	transport := fs.String("transport", "", "Transport: http or grpc")
+	timeout := fs.Duration("timeout", 0, "Request timeout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+	config, err := loadConfig(*configPath, explicit, getenv)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	overrideString(&config.URL, *url)
+	overrideString(&config.User, *user)
+	overrideString(&config.Org, *org)
+	overrideString(&config.Token, *token)
+	overrideString(&config.Output, *output)
+	overrideString(&config.Transport, *transport)
+	if *timeout > 0 {
+		config.Timeout = *timeout
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	c, err := newCLI(config, stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := cmd.run(ctx, c, fs.Args()[1:]); err != nil {
+		fmt.Fprintln(stderr, err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(stderr, "usage: calendarctl "+cmd.usage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func overrideString(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "usage: calendarctl [flags] <command> [args]")
+	fmt.Fprintln(w, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(w, "  "+commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\nenvironment: "+strings.Join([]string{
+		envPrefix + "CONFIG", envPrefix + "URL", envPrefix + "USER", envPrefix + "ORG",
+		envPrefix + "TOKEN", envPrefix + "OUTPUT", envPrefix + "TRANSPORT", envPrefix + "TIMEOUT",
+	}, ", "))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+	webhooks := webhook.NewDispatcher(logg, webhook.Config{LogSize: 10})
+	calendar := app.New(logg, memorystorage.New(), feed.NewBroker(10, 10), webhooks,
+		subscription.NewManager(logg, subscription.Config{}), availability.NewStore(),
+		digest.NewStore(), attachment.NewStore(attachment.NewMemory(), attachment.Config{}), tenant.Config{})
+	server := internalhttp.NewServer(logg, calendar, health.NewChecker(health.Version{}, time.Second),
+		auth.Header{}, ratelimit.New(ratelimit.Config{}), internalhttp.Config{})
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func runCtl(t *testing.T, env map[string]string, stdin string, args ...string) result {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	getenv := func(key string) string { return env[key] }
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr, getenv)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func TestCommands(t *testing.T) {
+	ts := newTestServer(t)
+	env := map[string]string{
+		"CALENDARCTL_CONFIG": filepath.Join(t.TempDir(), "missing.toml"),
+		"CALENDARCTL_URL":    ts.URL,
+		"CALENDARCTL_USER":   "alice",
+	}
+
+	res := runCtl(t, env, "", "-o", "json", "create", "-title", "standup",
+		"-start", "2024-03-01T10:00:00Z", "-end", "2024-03-01T10:15:00Z", "-notify", "5m",
+		"-remind", "24h:log", "-remind", "1h")
+	require.Equal(t, 0, res.code, res.stderr)
+	var created eventJSON
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &created))
+	require.Equal(t, "standup", created.Title)
+	require.Equal(t, "5m0s", created.NotifyBefore)
+	require.Equal(t, []string{"24h0m0s:log", "1h0m0s:webhook"}, created.Reminders)
+
+	res = runCtl(t, env, "", "-o", "json", "update", "-title", "retro", created.ID)
+	require.Equal(t, 0, res.code, res.stderr)
+	var updated eventJSON
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &updated))
+	require.Equal(t, "retro", updated.Title)
+	require.Equal(t, created.StartAt, updated.StartAt, "unset flags keep values")
+	require.Equal(t, created.Reminders, updated.Reminders, "unset flags keep values")
+
+	res = runCtl(t, env, "", "day", "2024-03-01")
+	require.Equal(t, 0, res.code, res.stderr)
+	require.Contains(t, res.stdout, "TITLE")
+	require.Contains(t, res.stdout, created.ID)
+	require.Contains(t, res.stdout, "retro")
+
+	res = runCtl(t, env, "", "export", "month", "2024-03-01")
+	require.Equal(t, 0, res.code, res.stderr)
+	require.Contains(t, res.stdout, "SUMMARY:retro")
+	exported := res.stdout
+
+	require.Equal(t, 0, runCtl(t, env, "", "delete", created.ID).code)
+	res = runCtl(t, env, "", "get", created.ID)
+	require.Equal(t, 1, res.code)
+	require.Contains(t, res.stderr, "404")
+
+	res = runCtl(t, env, exported, "import", "-")
+	require.Equal(t, 0, res.code, res.stderr)
+	require.Equal(t, "imported 1 of 1 events\n", res.stdout)
+
+	res = runCtl(t, env, "", "-o", "ics", "week", "2024-02-26")
+	require.Equal(t, 0, res.code, res.stderr)
+	require.Contains(t, res.stdout, "TRIGGER:-PT5M")
+
+	res = runCtl(t, env, exported, "import", "-")
+	require.Equal(t, 1, res.code, "the time is taken now")
+	require.Contains(t, res.stderr, "409")
+
+	require.Equal(t, 2, runCtl(t, env, "", "get").code)
+	require.Equal(t, 2, runCtl(t, env, "", "fly").code)
+	require.Equal(t, 1, runCtl(t, env, "", "-transport", "grpc", "day", "2024-03-01").code)
+}
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("url = \"http://file\"\nuser = \"file\"\noutput = \"json\"\n"), 0o600))
+
+	env := map[string]string{"CALENDARCTL_USER": "env"}
+	config, err := loadConfig(path, true, func(key string) string { return env[key] })
+	require.NoError(t, err)
+	require.Equal(t, "http://file", config.URL)
+	require.Equal(t, "env", config.User)
+	require.Equal(t, "json", config.Output)
+	require.Equal(t, 10*time.Second, config.Timeout)
+
+	_, err = loadConfig(path+".missing", false, os.Getenv)
+	require.NoError(t, err)
+	_, err = loadConfig(path+".missing", true, os.Getenv)
+	require.Error(t, err)
+}
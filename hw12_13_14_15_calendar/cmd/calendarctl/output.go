@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/client"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+)
+
+const tableTimeLayout = "2006-01-02 15:04"
+
+type eventJSON struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	StartAt      time.Time `json:"startAt"`
+	EndAt        time.Time `json:"endAt"`
+	Description  string    `json:"description,omitempty"`
+	UserID       string    `json:"userId"`
+	NotifyBefore string    `json:"notifyBefore,omitempty"`
+	Reminders    []string  `json:"reminders,omitempty"`
+}
+
+func printEvents(w io.Writer, format string, events []client.Event) error {
+	switch format {
+	case "table":
+		return printTable(w, events)
+	case "json":
+		out := make([]eventJSON, 0, len(events))
+		for _, event := range events {
+			out = append(out, toJSON(event))
+		}
+		return printJSON(w, out)
+	case "ics":
+		return ical.Encode(w, toICal(events))
+	default:
+		return fmt.Errorf("unknown output format %q, want table, json or ics", format)
+	}
+}
+
+// printEvent prints a single event, as an object rather than a list in JSON.
+func printEvent(w io.Writer, format string, event client.Event) error {
+	if format == "json" {
+		return printJSON(w, toJSON(event))
+	}
+	return printEvents(w, format, []client.Event{event})
+}
+
+func printTable(w io.Writer, events []client.Event) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTART\tEND\tTITLE\tNOTIFY")
+	for _, event := range events {
+		notify := "-"
+		if event.NotifyBefore > 0 {
+			notify = event.NotifyBefore.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			event.ID,
+			event.StartAt.Local().Format(tableTimeLayout),
+			event.EndAt.Local().Format(tableTimeLayout),
+			event.Title,
+			notify,
+		)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func toJSON(event client.Event) eventJSON {
+	out := eventJSON{
+		ID:          event.ID,
+		Title:       event.Title,
+		StartAt:     event.StartAt,
+		EndAt:       event.EndAt,
+		Description: event.Description,
+		UserID:      event.UserID,
+	}
+	if event.NotifyBefore > 0 {
+		out.NotifyBefore = event.NotifyBefore.String()
+	}
+	for _, reminder := range event.Reminders {
+		out.Reminders = append(out.Reminders, reminder.Before.String()+":"+reminder.Channel)
+	}
+	return out
+}
+
+func toICal(events []client.Event) []ical.Event {
+	out := make([]ical.Event, 0, len(events))
+	for _, event := range events {
+		out = append(out, ical.Event{
+			UID:         event.ID,
+			Summary:     event.Title,
+			Description: event.Description,
+			Start:       event.StartAt,
+			End:         event.EndAt,
+			Alarm:       event.NotifyBefore,
+		})
+	}
+	return out
+}
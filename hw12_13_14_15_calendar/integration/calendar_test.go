@@ -0,0 +1,237 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/client"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+var day = time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+func event(title string, start time.Time, d time.Duration) client.Event {
+	return client.Event{Title: title, StartAt: start, EndAt: start.Add(d)}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	c := startCalendar(t)
+	alice := client.New(client.Config{BaseURL: c.URL, UserID: "alice", Timeout: 5 * time.Second})
+	bob := client.New(client.Config{BaseURL: c.URL, UserID: "bob", Timeout: 5 * time.Second})
+
+	t.Run("create", func(t *testing.T) {
+		created, err := alice.Create(ctx, event("standup", day.Add(10*time.Hour), 15*time.Minute))
+		require.NoError(t, err)
+		require.NotEmpty(t, created.ID)
+
+		got, err := alice.Get(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, created, got)
+	})
+
+	t.Run("business errors", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			client *client.Client
+			event  client.Event
+			status int
+		}{
+			{
+				"time is taken", alice,
+				event("clash", day.Add(10*time.Hour+5*time.Minute), time.Hour), http.StatusConflict,
+			},
+			{"empty title", alice, event("", day.Add(20*time.Hour), time.Hour), http.StatusBadRequest},
+			{"ends before start", alice, event("back", day.Add(20*time.Hour), -time.Hour), http.StatusBadRequest},
+			{
+				"no user", client.New(client.Config{BaseURL: c.URL}),
+				event("anon", day, time.Hour), http.StatusUnauthorized,
+			},
+		}
+		for _, tt := range tests {
+			_, err := tt.client.Create(ctx, tt.event)
+			var apiErr *client.APIError
+			require.ErrorAs(t, err, &apiErr, tt.name)
+			require.Equal(t, tt.status, apiErr.Status, tt.name)
+		}
+
+		events, err := alice.Day(ctx, day)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		_, err = bob.Get(ctx, events[0].ID)
+		require.True(t, client.IsNotFound(err), "foreign events are hidden")
+		require.True(t, client.IsNotFound(alice.Delete(ctx, "missing")))
+	})
+
+	t.Run("listings", func(t *testing.T) {
+		for _, e := range []client.Event{
+			event("next day", day.AddDate(0, 0, 1).Add(9*time.Hour), time.Hour),
+			event("next week", day.AddDate(0, 0, 7).Add(9*time.Hour), time.Hour),
+			event("next month", day.AddDate(0, 1, 0).Add(9*time.Hour), time.Hour),
+		} {
+			_, err := alice.Create(ctx, e)
+			require.NoError(t, err)
+		}
+		_, err := bob.Create(ctx, event("bob's", day.Add(10*time.Hour), time.Hour))
+		require.NoError(t, err)
+
+		for name, tt := range map[string]struct {
+			list   func(context.Context, time.Time) ([]client.Event, error)
+			titles []string
+		}{
+			"day":   {alice.Day, []string{"standup"}},
+			"week":  {alice.Week, []string{"standup", "next day"}},
+			"month": {alice.Month, []string{"standup", "next day", "next week"}},
+		} {
+			events, err := tt.list(ctx, day)
+			require.NoError(t, err, name)
+			titles := make([]string, 0, len(events))
+			for _, e := range events {
+				titles = append(titles, e.Title)
+			}
+			require.Equal(t, tt.titles, titles, name)
+		}
+	})
+}
+
+type delivery struct {
+	eventType string
+	body      []byte
+}
+
+// TestNotificationDelivery follows an event change through the feed and the
+// webhook dispatcher to a signed HTTP delivery.
+func TestNotificationDelivery(t *testing.T) {
+	ctx := context.Background()
+	c := startCalendar(t)
+	alice := client.New(client.Config{BaseURL: c.URL, UserID: "alice", Timeout: 5 * time.Second})
+
+	const secret = "integration-secret"
+	received := make(chan delivery, 10)
+	var failures atomic.Int32
+	failures.Store(1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		signature := r.Header.Get(webhook.HeaderSignature)
+		if !webhook.Verify(secret, timestamp, body, signature, webhook.DefaultMaxAge, time.Now()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- delivery{eventType: r.Header.Get(webhook.HeaderEvent), body: body}
+	}))
+	t.Cleanup(receiver.Close)
+
+	addWebhook(t, c, "alice", receiver.URL, secret)
+
+	created, err := alice.Create(ctx, event("standup", day.Add(10*time.Hour), 15*time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, alice.Delete(ctx, created.ID))
+
+	// The first attempt fails and is retried, so deliveries may come in any order.
+	var types []string
+	for i := 0; i < 2; i++ {
+		select {
+		case d := <-received:
+			types = append(types, d.eventType)
+			var payload struct {
+				Event struct {
+					ID    string `json:"id"`
+					Title string `json:"title"`
+				} `json:"event"`
+			}
+			require.NoError(t, json.Unmarshal(d.body, &payload))
+			require.Equal(t, created.ID, payload.Event.ID)
+			require.Equal(t, "standup", payload.Event.Title)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got deliveries %v, want two", types)
+		}
+	}
+	require.ElementsMatch(t, []string{string(webhook.EventCreated), string(webhook.EventDeleted)}, types)
+}
+
+// TestReminderDelivery follows a reminder from the reminder worker through
+// the webhook dispatcher to a notification.due delivery.
+func TestReminderDelivery(t *testing.T) {
+	ctx := context.Background()
+	c := startCalendar(t)
+	alice := client.New(client.Config{BaseURL: c.URL, UserID: "alice", Timeout: 5 * time.Second})
+
+	const secret = "integration-secret"
+	received := make(chan delivery, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		signature := r.Header.Get(webhook.HeaderSignature)
+		if !webhook.Verify(secret, timestamp, body, signature, webhook.DefaultMaxAge, time.Now()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- delivery{eventType: r.Header.Get(webhook.HeaderEvent), body: body}
+	}))
+	t.Cleanup(receiver.Close)
+	addWebhook(t, c, "alice", receiver.URL, secret)
+
+	// The reminder becomes due one to two seconds from now.
+	standup := event("standup", time.Now().Add(time.Minute+2*time.Second).Truncate(time.Second), 15*time.Minute)
+	standup.Reminders = []client.Reminder{{Before: time.Minute, Channel: "webhook"}}
+	created, err := alice.Create(ctx, standup)
+	require.NoError(t, err)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case d := <-received:
+			if d.eventType != string(webhook.EventNotificationDue) {
+				continue
+			}
+			var payload struct {
+				Event struct {
+					ID string `json:"id"`
+				} `json:"event"`
+				Reminder struct {
+					Before  string `json:"before"`
+					Channel string `json:"channel"`
+				} `json:"reminder"`
+			}
+			require.NoError(t, json.Unmarshal(d.body, &payload))
+			require.Equal(t, created.ID, payload.Event.ID)
+			require.Equal(t, "1m0s", payload.Reminder.Before)
+			require.Equal(t, "webhook", payload.Reminder.Channel)
+			return
+		case <-timeout:
+			t.Fatal("notification.due was not delivered")
+		}
+	}
+}
+
+// addWebhook registers the receiver for the changes and notifications of the user.
+func addWebhook(t *testing.T, c *calendar, userID, url, secret string) {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"url": url, "secret": secret})
+	require.NoError(t, err)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, c.URL+"/webhooks",
+		bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("X-User-ID", userID)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+}
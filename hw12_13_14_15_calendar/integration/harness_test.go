@@ -0,0 +1,114 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cache"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cleanup"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/lifecycle"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/admin"
+	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/service"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+const adminToken = "integration-admin"
+
+// calendar is the service cmd/calendar runs, with memory storage and
+// every component running in the test process.
+type calendar struct {
+	URL      string
+	AdminURL string
+}
+
+func startCalendar(t *testing.T) *calendar {
+	t.Helper()
+
+	host := "127.0.0.1"
+	httpPort, adminPort := freePort(t), freePort(t)
+	svc, err := service.New(logger.NewWithWriter("ERROR", io.Discard), service.Config{
+		HTTP:     internalhttp.Config{Host: host, Port: httpPort, MaxBodySize: 1 << 20},
+		Admin:    admin.Config{Host: host, Port: adminPort, Token: adminToken},
+		Shutdown: lifecycle.Config{StopTimeout: 3 * time.Second},
+		Feed:     service.FeedConfig{BufferSize: 1000, SubscriberBuffer: 64},
+		Webhooks: webhook.Config{
+			Workers:       2,
+			QueueSize:     100,
+			MaxAttempts:   3,
+			RetryInterval: 10 * time.Millisecond,
+			Timeout:       time.Second,
+			LogSize:       10,
+			AllowPrivate:  true,
+		},
+		Health:    service.HealthConfig{Timeout: time.Second, Version: health.Version{Release: "integration"}},
+		Cleanup:   cleanup.Config{Interval: time.Hour, TrashRetention: time.Hour},
+		Reminders: reminder.Config{Interval: 50 * time.Millisecond},
+		Cache:     cache.Config{Size: 1000, TTL: time.Minute},
+		Digests:   digest.Config{Interval: time.Minute},
+	}, auth.Header{}, attachment.NewMemory())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.Group.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		// Connections dialed by the shared transport but never used would keep
+		// Stop waiting, the server treats them as active for a while.
+		http.DefaultClient.CloseIdleConnections()
+		require.NoError(t, <-done)
+	})
+
+	c := &calendar{
+		URL:      "http://" + net.JoinHostPort(host, httpPort),
+		AdminURL: "http://" + net.JoinHostPort(host, adminPort),
+	}
+	require.Eventually(t, func() bool {
+		return ready(c.URL+"/readyz", "") && ready(c.AdminURL+"/queues", adminToken)
+	}, 5*time.Second, 10*time.Millisecond, "calendar is not ready")
+	return c
+}
+
+func ready(url, token string) bool {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// freePort returns a port nothing listens on, the service binds it itself.
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	return port
+}
@@ -2,25 +2,441 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmptyUserID     = errors.New("user id is required")
+	ErrEmptyOrgID      = errors.New("organization id is required")
+	ErrEmptyTitle      = errors.New("event title is required")
+	ErrInvalidPeriod   = errors.New("event must end after it starts")
+	ErrNegativeNotify  = errors.New("notify before must not be negative")
+	ErrForeignEvent    = errors.New("event belongs to another user")
+	ErrInvalidReminder = errors.New("invalid reminder")
 )
 
-type App struct { // TODO
+const maxReminders = 10
+
+type App struct {
+	logger        Logger
+	storage       Storage
+	changes       *feed.Broker
+	webhooks      Webhooks
+	subscriptions Subscriptions
+	availability  Availability
+	digests       Digests
+	attachments   Attachments
+	tenants       tenant.Config
+
+	// quotaMu serializes writes adding live events while the organization
+	// has an event quota, so that concurrent ones cannot exceed it.
+	quotaMu sync.Mutex
+	// events serializes the writes of each event, so that attachment
+	// changes, updates carrying them over and restores do not overwrite
+	// each other. It is taken before quotaMu.
+	events eventLocks
+}
+
+type Logger interface {
+	Debug(msg string)
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event) error
+	UpdateEvent(ctx context.Context, id string, event storage.Event) error
+	DeleteEvent(ctx context.Context, orgID, id string) error
+	GetEvent(ctx context.Context, orgID, id string) (storage.Event, error)
+	ListEvents(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error)
+	CountEvents(ctx context.Context, orgID string) (int, error)
+	ListDeleted(ctx context.Context, orgID, userID string) ([]storage.Event, error)
+	AppendRevision(ctx context.Context, revision storage.Revision) (storage.Revision, error)
+	ListRevisions(ctx context.Context, orgID, eventID string) ([]storage.Revision, error)
+}
+
+type Webhooks interface {
+	Register(ctx context.Context, orgID, userID, url, secret string) (webhook.Webhook, error)
+	List(ctx context.Context, orgID, userID string) ([]webhook.Webhook, error)
+	Delete(ctx context.Context, orgID, userID, id string) error
+	Deliveries(ctx context.Context, orgID, userID, id string) ([]webhook.Delivery, error)
+}
+
+type Subscriptions interface {
+	Subscribe(ctx context.Context, orgID, userID, name, source string, limit int) (subscription.Subscription, error)
+	List(ctx context.Context, orgID, userID string) ([]subscription.Subscription, error)
+	Unsubscribe(ctx context.Context, orgID, userID, id string) error
+	Entries(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error)
+}
+
+type Availability interface {
+	Get(ctx context.Context, orgID, userID string) (availability.Settings, error)
+	Set(ctx context.Context, settings availability.Settings) (availability.Settings, error)
+}
+
+type Digests interface {
+	Get(ctx context.Context, orgID, userID string) (digest.Settings, error)
+	Set(ctx context.Context, settings digest.Settings) (digest.Settings, error)
+	Delete(ctx context.Context, orgID, userID string) error
+}
+
+type Attachments interface {
+	Upload(ctx context.Context, name, contentType string, body io.Reader) (storage.Attachment, error)
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	Delete(ctx context.Context, id string) error
 }
 
-type Logger interface { // TODO
+func New(
+	logger Logger, storage Storage, changes *feed.Broker, webhooks Webhooks, subscriptions Subscriptions,
+	availability Availability, digests Digests, attachments Attachments, tenants tenant.Config,
+) *App {
+	return &App{
+		logger:        logger,
+		storage:       storage,
+		changes:       changes,
+		webhooks:      webhooks,
+		subscriptions: subscriptions,
+		availability:  availability,
+		digests:       digests,
+		attachments:   attachments,
+		tenants:       tenants,
+	}
 }
 
-type Storage interface { // TODO
+func (a *App) CreateEvent(ctx context.Context, event storage.Event) (storage.Event, error) {
+	c, err := a.createEvent(ctx, event)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.commit(ctx, c)
+	return *c.after, nil
 }
 
-func New(logger Logger, storage Storage) *App {
-	return &App{}
+func (a *App) UpdateEvent(ctx context.Context, id string, event storage.Event) (storage.Event, error) {
+	c, err := a.updateEvent(ctx, id, event)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.commit(ctx, c)
+	return *c.after, nil
 }
 
-func (a *App) CreateEvent(ctx context.Context, id, title string) error {
-	// TODO
+// DeleteEvent moves the event to the trash, it is purged later by the cleanup.
+func (a *App) DeleteEvent(ctx context.Context, orgID, userID, id string) error {
+	c, err := a.deleteEvent(ctx, orgID, userID, id)
+	if err != nil {
+		return err
+	}
+	a.commit(ctx, c)
 	return nil
-	// return a.storage.CreateEvent(storage.Event{ID: id, Title: title})
 }
 
-// TODO
+// change is a stored mutation whose revision and feed entry are not written yet.
+type change struct {
+	action   storage.RevisionAction
+	feedType feed.ChangeType
+	actor    string
+	before   *storage.Event
+	after    *storage.Event
+}
+
+func (a *App) createEvent(ctx context.Context, event storage.Event) (change, error) {
+	if err := a.checkOwner(event.OrgID, event.UserID); err != nil {
+		return change{}, err
+	}
+	if err := validate(event); err != nil {
+		return change{}, err
+	}
+	if err := a.checkAvailability(ctx, event, nil); err != nil {
+		return change{}, err
+	}
+
+	event.ID = uuid.NewString()
+	err := a.withinQuota(ctx, event.OrgID, func() error {
+		return a.storage.CreateEvent(ctx, event)
+	})
+	if err != nil {
+		return change{}, fmt.Errorf("create event: %w", err)
+	}
+	return change{
+		action:   storage.RevisionCreated,
+		feedType: feed.ChangeCreated,
+		actor:    event.UserID,
+		after:    &event,
+	}, nil
+}
+
+func (a *App) updateEvent(ctx context.Context, id string, event storage.Event) (change, error) {
+	if err := validate(event); err != nil {
+		return change{}, err
+	}
+	defer a.events.lock(event.OrgID, id)()
+
+	before, err := a.GetEvent(ctx, event.OrgID, event.UserID, id)
+	if err != nil {
+		return change{}, err
+	}
+	if err := a.checkAvailability(ctx, event, &before); err != nil {
+		return change{}, err
+	}
+
+	event.ID = id
+	event.Attachments = before.Attachments
+	if err := a.storage.UpdateEvent(ctx, id, event); err != nil {
+		return change{}, fmt.Errorf("update event: %w", err)
+	}
+	return change{
+		action:   storage.RevisionUpdated,
+		feedType: feed.ChangeUpdated,
+		actor:    event.UserID,
+		before:   &before,
+		after:    &event,
+	}, nil
+}
+
+func (a *App) deleteEvent(ctx context.Context, orgID, userID, id string) (change, error) {
+	defer a.events.lock(orgID, id)()
+
+	event, err := a.GetEvent(ctx, orgID, userID, id)
+	if err != nil {
+		return change{}, err
+	}
+
+	trashed := event
+	trashed.DeletedAt = time.Now().UTC()
+	if err := a.storage.UpdateEvent(ctx, id, trashed); err != nil {
+		return change{}, fmt.Errorf("delete event: %w", err)
+	}
+	return change{action: storage.RevisionDeleted, feedType: feed.ChangeDeleted, actor: userID, before: &event}, nil
+}
+
+// commit writes the revision and publishes the change.
+func (a *App) commit(ctx context.Context, c change) {
+	a.record(ctx, c.action, c.actor, c.before, c.after)
+	if c.after != nil {
+		a.publish(ctx, c.feedType, *c.after)
+	} else {
+		a.publish(ctx, c.feedType, *c.before)
+	}
+}
+
+// undo reverts a stored change that has not been committed. Attachments
+// changed meanwhile are kept, as updates carry them over too.
+func (a *App) undo(ctx context.Context, c change) error {
+	if c.before == nil {
+		defer a.events.lock(c.after.OrgID, c.after.ID)()
+		return a.storage.DeleteEvent(ctx, c.after.OrgID, c.after.ID)
+	}
+
+	defer a.events.lock(c.before.OrgID, c.before.ID)()
+	before := *c.before
+	current, err := a.storage.GetEvent(ctx, before.OrgID, before.ID)
+	if err != nil {
+		return fmt.Errorf("get event: %w", err)
+	}
+	before.Attachments = current.Attachments
+	return a.storage.UpdateEvent(ctx, before.ID, before)
+}
+
+func (a *App) GetEvent(ctx context.Context, orgID, userID, id string) (storage.Event, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return storage.Event{}, err
+	}
+
+	event, err := a.storage.GetEvent(ctx, orgID, id)
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("get event: %w", err)
+	}
+	if event.UserID != userID {
+		return storage.Event{}, ErrForeignEvent
+	}
+	if event.Deleted() {
+		return storage.Event{}, fmt.Errorf("get event: %w", storage.ErrEventNotFound)
+	}
+	return event, nil
+}
+
+func (a *App) ListDay(ctx context.Context, orgID, userID string, date time.Time) ([]storage.Event, error) {
+	from := startOfDay(date)
+	return a.ListEvents(ctx, orgID, userID, from, from.AddDate(0, 0, 1))
+}
+
+func (a *App) ListWeek(ctx context.Context, orgID, userID string, weekStart time.Time) ([]storage.Event, error) {
+	from := startOfDay(weekStart)
+	return a.ListEvents(ctx, orgID, userID, from, from.AddDate(0, 0, 7))
+}
+
+func (a *App) ListMonth(ctx context.Context, orgID, userID string, monthStart time.Time) ([]storage.Event, error) {
+	from := startOfDay(monthStart)
+	return a.ListEvents(ctx, orgID, userID, from, from.AddDate(0, 1, 0))
+}
+
+// WatchEvents returns changes of user events published after lastID
+// followed by a channel with new ones until ctx is done.
+func (a *App) WatchEvents(
+	ctx context.Context, orgID, userID string, lastID uint64,
+) ([]feed.Change, <-chan feed.Change, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, nil, err
+	}
+	return a.changes.Subscribe(ctx, orgID, userID, lastID)
+}
+
+// RegisterWebhook subscribes url to changes of user events. An empty secret
+// is generated, it is returned only here.
+func (a *App) RegisterWebhook(ctx context.Context, orgID, userID, url, secret string) (webhook.Webhook, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return webhook.Webhook{}, err
+	}
+	return a.webhooks.Register(ctx, orgID, userID, url, secret)
+}
+
+func (a *App) ListWebhooks(ctx context.Context, orgID, userID string) ([]webhook.Webhook, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+	return a.webhooks.List(ctx, orgID, userID)
+}
+
+func (a *App) DeleteWebhook(ctx context.Context, orgID, userID, id string) error {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return err
+	}
+	return a.webhooks.Delete(ctx, orgID, userID, id)
+}
+
+func (a *App) WebhookDeliveries(ctx context.Context, orgID, userID, id string) ([]webhook.Delivery, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+	return a.webhooks.Deliveries(ctx, orgID, userID, id)
+}
+
+// ListEvents returns user events intersecting [from, to) merged with entries
+// of the user subscriptions, ordered by start time.
+func (a *App) ListEvents(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.ListEvents(ctx, orgID, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	entries, err := a.subscriptions.Entries(ctx, orgID, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list subscribed entries: %w", err)
+	}
+	if len(entries) == 0 {
+		return events, nil
+	}
+
+	events = append(events, entries...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+	return events, nil
+}
+
+func (a *App) publish(ctx context.Context, changeType feed.ChangeType, event storage.Event) {
+	change := a.changes.Publish(feed.Change{
+		Type:   changeType,
+		OrgID:  event.OrgID,
+		UserID: event.UserID,
+		Event:  event,
+		Trace:  tracing.Inject(ctx),
+	})
+	a.logger.Debug(fmt.Sprintf("event %s %s, change %d", event.ID, changeType, change.ID))
+}
+
+// checkOwner rejects anonymous requests and, when organizations are
+// required, users outside of them.
+func (a *App) checkOwner(orgID, userID string) error {
+	switch {
+	case userID == "":
+		return ErrEmptyUserID
+	case orgID == "" && a.tenants.RequireOrg:
+		return ErrEmptyOrgID
+	}
+	return nil
+}
+
+// withinQuota runs add, which makes one more live event of the
+// organization, unless the organization has used up its event quota.
+func (a *App) withinQuota(ctx context.Context, orgID string, add func() error) error {
+	limit := a.tenants.Quota(orgID).MaxEvents
+	if limit <= 0 {
+		return add()
+	}
+
+	a.quotaMu.Lock()
+	defer a.quotaMu.Unlock()
+	count, err := a.storage.CountEvents(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("count events: %w", err)
+	}
+	if count >= limit {
+		return fmt.Errorf("%w: at most %d events", tenant.ErrQuotaExceeded, limit)
+	}
+	return add()
+}
+
+func validate(event storage.Event) error {
+	switch {
+	case event.UserID == "":
+		return ErrEmptyUserID
+	case strings.TrimSpace(event.Title) == "":
+		return ErrEmptyTitle
+	case !event.EndAt.After(event.StartAt):
+		return ErrInvalidPeriod
+	case event.NotifyBefore < 0:
+		return ErrNegativeNotify
+	case event.NotifyBefore > reminder.MaxBefore:
+		// The reminder worker looks no further ahead, it would never fire.
+		return fmt.Errorf("%w: notify before must not exceed %s", ErrInvalidReminder, reminder.MaxBefore)
+	}
+	return validateReminders(event.Reminders)
+}
+
+func validateReminders(reminders []storage.Reminder) error {
+	if len(reminders) > maxReminders {
+		return fmt.Errorf("%w: at most %d reminders per event", ErrInvalidReminder, maxReminders)
+	}
+
+	seen := make(map[storage.Reminder]bool, len(reminders))
+	for _, r := range reminders {
+		switch {
+		case r.Before < 0 || r.Before > reminder.MaxBefore:
+			return fmt.Errorf("%w: before must be between 0 and %s", ErrInvalidReminder, reminder.MaxBefore)
+		case !reminder.Known(r.Channel):
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidReminder, r.Channel)
+		case seen[r]:
+			return fmt.Errorf("%w: duplicate %s via %s", ErrInvalidReminder, r.Before, r.Channel)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,237 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// blobs keeps uploaded attachments in memory.
+type blobs struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (b *blobs) Upload(_ context.Context, name, contentType string, body io.Reader) (storage.Attachment, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	att := storage.Attachment{ID: uuid.NewString(), Name: name, ContentType: contentType, Size: int64(len(data))}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blobs[att.ID] = data
+	return att, nil
+}
+
+func (b *blobs) Open(context.Context, string) (io.ReadCloser, error) {
+	return nil, io.EOF
+}
+
+func (b *blobs) Delete(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blobs, id)
+	return nil
+}
+
+func (b *blobs) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.blobs)
+}
+
+type testApp struct {
+	*App
+	storage *memorystorage.Storage
+	blobs   *blobs
+}
+
+func newTestApp(t *testing.T, tenants tenant.Config) testApp {
+	t.Helper()
+
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+	events := memorystorage.New()
+	attachments := &blobs{blobs: make(map[string][]byte)}
+	a := New(logg, events, feed.NewBroker(100, 10), webhook.NewDispatcher(logg, webhook.Config{LogSize: 10}),
+		subscription.NewManager(logg, subscription.Config{}), availability.NewStore(), digest.NewStore(),
+		attachments, tenants)
+	return testApp{App: a, storage: events, blobs: attachments}
+}
+
+// newEvent returns an event of the user lasting an hour from the given hour of day.
+func newEvent(orgID, userID string, hour int) storage.Event {
+	start := day.Add(time.Duration(hour) * time.Hour)
+	return storage.Event{OrgID: orgID, UserID: userID, Title: "event", StartAt: start, EndAt: start.Add(time.Hour)}
+}
+
+func TestValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		edit func(event *storage.Event)
+		err  error
+	}{
+		"valid":          {edit: func(*storage.Event) {}},
+		"empty title":    {edit: func(e *storage.Event) { e.Title = " " }, err: ErrEmptyTitle},
+		"empty period":   {edit: func(e *storage.Event) { e.EndAt = e.StartAt }, err: ErrInvalidPeriod},
+		"negative":       {edit: func(e *storage.Event) { e.NotifyBefore = -time.Minute }, err: ErrNegativeNotify},
+		"notify at most": {edit: func(e *storage.Event) { e.NotifyBefore = reminder.MaxBefore }},
+		"notify too early": {
+			edit: func(e *storage.Event) { e.NotifyBefore = reminder.MaxBefore + time.Hour },
+			err:  ErrInvalidReminder,
+		},
+		"reminder too early": {
+			edit: func(e *storage.Event) {
+				e.Reminders = []storage.Reminder{{Before: reminder.MaxBefore + time.Hour, Channel: reminder.ChannelLog}}
+			},
+			err: ErrInvalidReminder,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := newEvent("", "alice", 10)
+			tc.edit(&event)
+			err := validate(event)
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestCheckOwner(t *testing.T) {
+	for name, tc := range map[string]struct {
+		requireOrg    bool
+		orgID, userID string
+		err           error
+	}{
+		"single tenant":     {userID: "alice"},
+		"organization":      {orgID: "acme", userID: "alice"},
+		"no user":           {orgID: "acme", err: ErrEmptyUserID},
+		"required org":      {requireOrg: true, orgID: "acme", userID: "alice"},
+		"missing org":       {requireOrg: true, userID: "alice", err: ErrEmptyOrgID},
+		"missing org, user": {requireOrg: true, err: ErrEmptyUserID},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := newTestApp(t, tenant.Config{RequireOrg: tc.requireOrg})
+			_, err := a.CreateEvent(context.Background(), newEvent(tc.orgID, tc.userID, 10))
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+// slowCount widens the window between counting events and adding one.
+type slowCount struct {
+	*memorystorage.Storage
+}
+
+func (s slowCount) CountEvents(ctx context.Context, orgID string) (int, error) {
+	count, err := s.Storage.CountEvents(ctx, orgID)
+	time.Sleep(time.Millisecond)
+	return count, err
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	const limit = 5
+	a := newTestApp(t, tenant.Config{Orgs: map[string]tenant.Quota{"acme": {MaxEvents: limit}}})
+	a.App.storage = slowCount{a.storage}
+
+	// Users do not share time, only the quota of their organization.
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created []storage.Event
+	)
+	for i := range 4 * limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			event, err := a.CreateEvent(ctx, newEvent("acme", fmt.Sprintf("user%d", i), 10))
+			if err != nil {
+				assert.ErrorIs(t, err, tenant.ErrQuotaExceeded)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			created = append(created, event)
+		}()
+	}
+	wg.Wait()
+	require.Len(t, created, limit)
+
+	_, err := a.CreateEvent(ctx, newEvent("globex", "user0", 10))
+	require.NoError(t, err, "other organizations have their own quota")
+
+	trashed := created[0]
+	require.NoError(t, a.DeleteEvent(ctx, "acme", trashed.UserID, trashed.ID))
+	results := make(chan error, 2)
+	go func() {
+		_, err := a.CreateEvent(ctx, newEvent("acme", "late", 10))
+		results <- err
+	}()
+	go func() {
+		_, err := a.RestoreDeleted(ctx, "acme", trashed.UserID, trashed.ID)
+		results <- err
+	}()
+	errs := []error{<-results, <-results}
+	require.Len(t, slices.DeleteFunc(errs, func(err error) bool { return err == nil }), 1,
+		"the trash frees one place, only one of a create and a restore takes it")
+	require.ErrorIs(t, errs[0], tenant.ErrQuotaExceeded)
+}
+
+func TestListEvents(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	holidays := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:spring-day\r\n" +
+		"DTSTART;VALUE=DATE:20240301\r\nDTEND;VALUE=DATE:20240302\r\nSUMMARY:Spring Day\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "holidays.ics"), []byte(holidays), 0o600))
+	a := newTestApp(t, tenant.Config{})
+	a.App.subscriptions = subscription.NewManager(logger.NewWithWriter("ERROR", io.Discard),
+		subscription.Config{Dir: dir})
+
+	event, err := a.CreateEvent(ctx, newEvent("", "alice", 10))
+	require.NoError(t, err)
+	_, err = a.Subscribe(ctx, "", "alice", "holidays", "holidays.ics")
+	require.NoError(t, err)
+
+	// Digests list events this way, so they include subscribed calendars.
+	events, err := a.ListEvents(ctx, "", "alice", day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "Spring Day", events[0].Title)
+	require.NotEmpty(t, events[0].SubscriptionID)
+	require.Equal(t, event.ID, events[1].ID)
+
+	events, err = a.ListEvents(ctx, "", "bob", day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
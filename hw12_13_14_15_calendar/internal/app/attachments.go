@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const maxAttachments = 20
+
+var ErrTooManyAttachments = errors.New("too many attachments")
+
+// AddAttachment uploads body and attaches it to the event. The limit is
+// checked before the upload too, so that a full event leaves no blob behind.
+// Detached attachments still count against it, see DeleteAttachment.
+func (a *App) AddAttachment(
+	ctx context.Context, orgID, userID, eventID, name, contentType string, body io.Reader,
+) (storage.Attachment, error) {
+	event, err := a.GetEvent(ctx, orgID, userID, eventID)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	if err := a.checkAttachmentCount(ctx, event.OrgID, event.ID, event.Attachments); err != nil {
+		return storage.Attachment{}, err
+	}
+
+	added, err := a.attachments.Upload(ctx, name, contentType, body)
+	if err != nil {
+		return storage.Attachment{}, err
+	}
+	add := func(attachments []storage.Attachment) ([]storage.Attachment, error) {
+		if err := a.checkAttachmentCount(ctx, orgID, eventID, attachments); err != nil {
+			return nil, err
+		}
+		return append(attachments, added), nil
+	}
+	if err := a.changeAttachments(ctx, orgID, userID, eventID, add); err != nil {
+		if err := a.attachments.Delete(ctx, added.ID); err != nil {
+			a.logger.Error(fmt.Sprintf("failed to delete attachment %s: %s", added.ID, err))
+		}
+		return storage.Attachment{}, err
+	}
+	return added, nil
+}
+
+// OpenAttachment returns the attachment of the event with its content,
+// the caller closes it.
+func (a *App) OpenAttachment(
+	ctx context.Context, orgID, userID, eventID, id string,
+) (storage.Attachment, io.ReadCloser, error) {
+	event, err := a.GetEvent(ctx, orgID, userID, eventID)
+	if err != nil {
+		return storage.Attachment{}, nil, err
+	}
+	i := slices.IndexFunc(event.Attachments, func(att storage.Attachment) bool { return att.ID == id })
+	if i < 0 {
+		return storage.Attachment{}, nil, attachment.ErrAttachmentNotFound
+	}
+
+	content, err := a.attachments.Open(ctx, id)
+	if err != nil {
+		return storage.Attachment{}, nil, fmt.Errorf("open attachment: %w", err)
+	}
+	return event.Attachments[i], content, nil
+}
+
+// DeleteAttachment detaches the attachment from the event. Its content is
+// kept while the event history refers to it, so that older versions can be
+// restored, and is removed by the cleanup together with the event. Until
+// then it takes up one of the event's attachment slots.
+func (a *App) DeleteAttachment(ctx context.Context, orgID, userID, eventID, id string) error {
+	remove := func(attachments []storage.Attachment) ([]storage.Attachment, error) {
+		i := slices.IndexFunc(attachments, func(att storage.Attachment) bool { return att.ID == id })
+		if i < 0 {
+			return nil, attachment.ErrAttachmentNotFound
+		}
+		return slices.Delete(attachments, i, i+1), nil
+	}
+	return a.changeAttachments(ctx, orgID, userID, eventID, remove)
+}
+
+// changeAttachments stores the event with attachments returned by edit,
+// which gets a copy of the current ones and is called with the event locked.
+func (a *App) changeAttachments(
+	ctx context.Context, orgID, userID, eventID string,
+	edit func([]storage.Attachment) ([]storage.Attachment, error),
+) error {
+	defer a.events.lock(orgID, eventID)()
+
+	before, err := a.GetEvent(ctx, orgID, userID, eventID)
+	if err != nil {
+		return err
+	}
+	attachments, err := edit(slices.Clone(before.Attachments))
+	if err != nil {
+		return err
+	}
+
+	after := before
+	after.Attachments = attachments
+	if err := a.storage.UpdateEvent(ctx, eventID, after); err != nil {
+		return fmt.Errorf("update event: %w", err)
+	}
+	a.record(ctx, storage.RevisionUpdated, userID, &before, &after)
+	a.publish(ctx, feed.ChangeUpdated, after)
+	return nil
+}
+
+// checkAttachmentCount fails when the event has no room for one more
+// attachment. Blobs kept for the event history count as well as the
+// current attachments, so that uploading and deleting cannot grow the
+// storage without bound.
+func (a *App) checkAttachmentCount(ctx context.Context, orgID, eventID string, current []storage.Attachment) error {
+	revisions, err := a.storage.ListRevisions(ctx, orgID, eventID)
+	if err != nil {
+		return fmt.Errorf("list revisions: %w", err)
+	}
+
+	kept := make(map[string]struct{}, len(current))
+	keep := func(attachments []storage.Attachment) {
+		for _, att := range attachments {
+			kept[att.ID] = struct{}{}
+		}
+	}
+	keep(current)
+	for _, revision := range revisions {
+		if revision.Before != nil {
+			keep(revision.Before.Attachments)
+		}
+		if revision.After != nil {
+			keep(revision.After.Attachments)
+		}
+	}
+
+	if len(kept) >= maxAttachments {
+		return fmt.Errorf("%w: at most %d per event, including deleted ones kept for its history",
+			ErrTooManyAttachments, maxAttachments)
+	}
+	return nil
+}
+
+func formatAttachments(attachments []storage.Attachment) string {
+	names := make([]string, 0, len(attachments))
+	for _, att := range attachments {
+		names = append(names, att.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowGet widens the window between reading an event and writing it back.
+type slowGet struct {
+	*memorystorage.Storage
+}
+
+func (s slowGet) GetEvent(ctx context.Context, orgID, id string) (storage.Event, error) {
+	event, err := s.Storage.GetEvent(ctx, orgID, id)
+	time.Sleep(time.Millisecond)
+	return event, err
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("concurrent updates keep uploads", func(t *testing.T) {
+		a := newTestApp(t, tenant.Config{})
+		event, err := a.CreateEvent(ctx, newEvent("", "alice", 10))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := a.AddAttachment(ctx, "", "alice", event.ID, fmt.Sprintf("%d.txt", i), "text/plain",
+					strings.NewReader("notes"))
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				update := event
+				for j := range 20 {
+					update.Title = fmt.Sprintf("event %d.%d", i, j)
+					_, err := a.UpdateEvent(ctx, event.ID, update)
+					assert.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		stored, err := a.GetEvent(ctx, "", "alice", event.ID)
+		require.NoError(t, err)
+		require.Len(t, stored.Attachments, 10)
+		require.Equal(t, 10, a.blobs.count())
+	})
+
+	t.Run("concurrent restores keep history consistent", func(t *testing.T) {
+		a := newTestApp(t, tenant.Config{})
+		a.App.storage = slowGet{a.storage}
+		event, err := a.CreateEvent(ctx, newEvent("", "alice", 10))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := a.AddAttachment(ctx, "", "alice", event.ID, fmt.Sprintf("%d.txt", i), "text/plain",
+					strings.NewReader("notes"))
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := a.RestoreEvent(ctx, "", "alice", event.ID, 1)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		revisions, err := a.EventHistory(ctx, "", "alice", event.ID)
+		require.NoError(t, err)
+		require.Len(t, revisions, 21)
+		for i := 1; i < len(revisions); i++ {
+			require.NotNil(t, revisions[i].Before, "revision %d", i+1)
+			require.Equal(t, revisions[i-1].After.Attachments, revisions[i].Before.Attachments, "revision %d", i+1)
+		}
+		stored, err := a.GetEvent(ctx, "", "alice", event.ID)
+		require.NoError(t, err)
+		require.Equal(t, revisions[len(revisions)-1].After.Attachments, stored.Attachments)
+	})
+
+	t.Run("full event leaves no blob", func(t *testing.T) {
+		a := newTestApp(t, tenant.Config{})
+		event, err := a.CreateEvent(ctx, newEvent("", "alice", 10))
+		require.NoError(t, err)
+		for range maxAttachments {
+			_, err := a.AddAttachment(ctx, "", "alice", event.ID, "a.txt", "text/plain", strings.NewReader("a"))
+			require.NoError(t, err)
+		}
+
+		_, err = a.AddAttachment(ctx, "", "alice", event.ID, "a.txt", "text/plain", strings.NewReader("a"))
+		require.ErrorIs(t, err, ErrTooManyAttachments)
+		require.Equal(t, maxAttachments, a.blobs.count())
+	})
+
+	t.Run("deleted attachments count until purged", func(t *testing.T) {
+		a := newTestApp(t, tenant.Config{})
+		event, err := a.CreateEvent(ctx, newEvent("", "alice", 10))
+		require.NoError(t, err)
+		for range maxAttachments {
+			added, err := a.AddAttachment(ctx, "", "alice", event.ID, "a.txt", "text/plain", strings.NewReader("a"))
+			require.NoError(t, err)
+			require.NoError(t, a.DeleteAttachment(ctx, "", "alice", event.ID, added.ID))
+		}
+
+		_, err = a.AddAttachment(ctx, "", "alice", event.ID, "a.txt", "text/plain", strings.NewReader("a"))
+		require.ErrorIs(t, err, ErrTooManyAttachments)
+		require.Equal(t, maxAttachments, a.blobs.count())
+
+		// Restoring a version brings back blobs that are kept anyway.
+		_, err = a.RestoreEvent(ctx, "", "alice", event.ID, 2)
+		require.NoError(t, err)
+		stored, err := a.GetEvent(ctx, "", "alice", event.ID)
+		require.NoError(t, err)
+		require.Len(t, stored.Attachments, 1)
+	})
+}
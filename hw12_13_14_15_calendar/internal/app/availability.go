@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+var ErrInvalidSlotDuration = errors.New("slot duration must be positive")
+
+// FreeSlot is a free period of the user long enough for the asked duration.
+type FreeSlot struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (a *App) Availability(ctx context.Context, orgID, userID string) (availability.Settings, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return availability.Settings{}, err
+	}
+	return a.availability.Get(ctx, orgID, userID)
+}
+
+func (a *App) SetAvailability(ctx context.Context, settings availability.Settings) (availability.Settings, error) {
+	if err := a.checkOwner(settings.OrgID, settings.UserID); err != nil {
+		return availability.Settings{}, err
+	}
+	return a.availability.Set(ctx, settings)
+}
+
+// FreeSlots returns free periods of at least duration within [from, to),
+// outside events, subscribed entries, off hours and absences.
+func (a *App) FreeSlots(
+	ctx context.Context, orgID, userID string, from, to time.Time, duration time.Duration,
+) ([]FreeSlot, error) {
+	if duration <= 0 {
+		return nil, ErrInvalidSlotDuration
+	}
+	busy, err := a.FreeBusy(ctx, orgID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]FreeSlot, 0)
+	cursor := from
+	for _, interval := range append(busy, BusyInterval{Start: to, End: to}) {
+		if interval.Start.Sub(cursor) >= duration {
+			slots = append(slots, FreeSlot{Start: cursor, End: interval.Start})
+		}
+		cursor = interval.End
+	}
+	return slots, nil
+}
+
+// checkAvailability rejects events of strict users outside working hours or
+// in an absence. Updates keeping the time of the event are not checked, so
+// events booked before the settings changed can still be edited.
+func (a *App) checkAvailability(ctx context.Context, event storage.Event, before *storage.Event) error {
+	if before != nil && before.StartAt.Equal(event.StartAt) && before.EndAt.Equal(event.EndAt) {
+		return nil
+	}
+	settings, err := a.availability.Get(ctx, event.OrgID, event.UserID)
+	if err != nil {
+		return fmt.Errorf("get availability: %w", err)
+	}
+	return settings.Check(event.StartAt, event.EndAt)
+}
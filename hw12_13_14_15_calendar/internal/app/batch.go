@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// MaxBatchSize limits the number of operations in one batch.
+const MaxBatchSize = 100
+
+var (
+	ErrEmptyBatch       = errors.New("batch has no operations")
+	ErrBatchTooLarge    = fmt.Errorf("batch has more than %d operations", MaxBatchSize)
+	ErrUnknownOperation = errors.New("unknown batch operation")
+	ErrBatchAborted     = errors.New("not applied, another operation of the batch failed")
+)
+
+type OperationKind string
+
+const (
+	OperationCreate OperationKind = "create"
+	OperationUpdate OperationKind = "update"
+	OperationDelete OperationKind = "delete"
+)
+
+// Operation is one mutation of a batch. ID is ignored for create, Event for delete.
+type Operation struct {
+	Kind  OperationKind
+	ID    string
+	Event storage.Event
+}
+
+// BatchResult is the outcome of the operation with the same index. Event is
+// the stored state, it is empty for deletes and failed operations.
+type BatchResult struct {
+	Event storage.Event
+	Err   error
+}
+
+// BatchMutate applies the operations of the user in order. An atomic batch
+// stops at the first failure and reverts the operations applied before it,
+// they fail with ErrBatchAborted. Otherwise every operation is tried and
+// failures are only reported. Revisions and feed entries are written once
+// the batch is done, so a reverted batch leaves no trace in either.
+func (a *App) BatchMutate(
+	ctx context.Context, orgID, userID string, ops []Operation, atomic bool,
+) ([]BatchResult, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(ops) == 0:
+		return nil, ErrEmptyBatch
+	case len(ops) > MaxBatchSize:
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(ops))
+	applied := make([]change, 0, len(ops))
+	for i, op := range ops {
+		c, err := a.apply(ctx, orgID, userID, op)
+		if err != nil {
+			results[i].Err = err
+			if atomic {
+				return results, a.abort(ctx, results, i, applied)
+			}
+			continue
+		}
+
+		applied = append(applied, c)
+		if c.action != storage.RevisionDeleted {
+			results[i].Event = *c.after
+		}
+	}
+
+	for _, c := range applied {
+		a.commit(ctx, c)
+	}
+	return results, nil
+}
+
+func (a *App) apply(ctx context.Context, orgID, userID string, op Operation) (change, error) {
+	op.Event.OrgID, op.Event.UserID = orgID, userID
+	switch op.Kind {
+	case OperationCreate:
+		return a.createEvent(ctx, op.Event)
+	case OperationUpdate:
+		return a.updateEvent(ctx, op.ID, op.Event)
+	case OperationDelete:
+		return a.deleteEvent(ctx, orgID, userID, op.ID)
+	default:
+		return change{}, fmt.Errorf("%w %q", ErrUnknownOperation, op.Kind)
+	}
+}
+
+// abort reverts the applied changes, newest first, and marks every
+// operation but the failed one as aborted.
+func (a *App) abort(ctx context.Context, results []BatchResult, failed int, applied []change) error {
+	var errs []error
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := a.undo(ctx, applied[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for i := range results {
+		if i != failed {
+			results[i] = BatchResult{Err: ErrBatchAborted}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("revert batch: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchMutate(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		atomic bool
+		// failing is the operation added last, it fails.
+		failing Operation
+		err     error
+	}{
+		"atomic, busy time": {
+			atomic:  true,
+			failing: Operation{Kind: OperationCreate, Event: newEvent("", "", 10)},
+			err:     storage.ErrDateBusy,
+		},
+		"atomic, unknown operation": {
+			atomic:  true,
+			failing: Operation{Kind: "move"},
+			err:     ErrUnknownOperation,
+		},
+		"atomic, missing event": {
+			atomic:  true,
+			failing: Operation{Kind: OperationDelete, ID: "missing"},
+			err:     storage.ErrEventNotFound,
+		},
+		"best effort": {
+			failing: Operation{Kind: OperationCreate, Event: newEvent("", "", 10)},
+			err:     storage.ErrDateBusy,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := newTestApp(t, tenant.Config{})
+			kept, err := a.CreateEvent(ctx, newEvent("", "alice", 9))
+			require.NoError(t, err)
+			removed, err := a.CreateEvent(ctx, newEvent("", "alice", 12))
+			require.NoError(t, err)
+			lastID := a.changes.LastID()
+
+			renamed := kept
+			renamed.Title = "renamed"
+			ops := []Operation{
+				{Kind: OperationCreate, Event: newEvent("", "", 10)},
+				{Kind: OperationUpdate, ID: kept.ID, Event: renamed},
+				{Kind: OperationDelete, ID: removed.ID},
+				tc.failing,
+			}
+			results, err := a.BatchMutate(ctx, "", "alice", ops, tc.atomic)
+			require.NoError(t, err)
+			require.Len(t, results, len(ops))
+			require.ErrorIs(t, results[3].Err, tc.err)
+
+			events, err := a.ListDay(ctx, "", "alice", day)
+			require.NoError(t, err)
+			history, err := a.EventHistory(ctx, "", "alice", kept.ID)
+			require.NoError(t, err)
+
+			if !tc.atomic {
+				for _, result := range results[:3] {
+					require.NoError(t, result.Err)
+				}
+				require.Len(t, events, 2, "created and renamed")
+				require.Len(t, history, 2)
+				require.Equal(t, lastID+3, a.changes.LastID())
+				return
+			}
+
+			for _, result := range results[:3] {
+				require.ErrorIs(t, result.Err, ErrBatchAborted)
+			}
+			require.Equal(t, []storage.Event{kept, removed}, events, "the batch is reverted")
+			require.Len(t, history, 1, "a reverted batch writes no revisions")
+			require.Equal(t, lastID, a.changes.LastID(), "a reverted batch publishes no changes")
+		})
+	}
+
+	t.Run("limits", func(t *testing.T) {
+		a := newTestApp(t, tenant.Config{})
+		_, err := a.BatchMutate(ctx, "", "alice", nil, true)
+		require.ErrorIs(t, err, ErrEmptyBatch)
+		_, err = a.BatchMutate(ctx, "", "alice", make([]Operation, MaxBatchSize+1), true)
+		require.ErrorIs(t, err, ErrBatchTooLarge)
+	})
+}
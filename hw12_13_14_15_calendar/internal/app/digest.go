@@ -0,0 +1,29 @@
+package app
+
+import (
+	"context"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+)
+
+func (a *App) Digest(ctx context.Context, orgID, userID string) (digest.Settings, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return digest.Settings{}, err
+	}
+	return a.digests.Get(ctx, orgID, userID)
+}
+
+// SetDigest enables the agenda digest of the user or changes its settings.
+func (a *App) SetDigest(ctx context.Context, settings digest.Settings) (digest.Settings, error) {
+	if err := a.checkOwner(settings.OrgID, settings.UserID); err != nil {
+		return digest.Settings{}, err
+	}
+	return a.digests.Set(ctx, settings)
+}
+
+func (a *App) DisableDigest(ctx context.Context, orgID, userID string) error {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return err
+	}
+	return a.digests.Delete(ctx, orgID, userID)
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+var ErrNothingToRestore = errors.New("revision has no event state to restore")
+
+// EventHistory returns all revisions of the event, deleted events included.
+func (a *App) EventHistory(ctx context.Context, orgID, userID, id string) ([]storage.Revision, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+
+	revisions, err := a.storage.ListRevisions(ctx, orgID, id)
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+	if len(revisions) == 0 {
+		return nil, storage.ErrEventNotFound
+	}
+	if owner(revisions[len(revisions)-1]) != userID {
+		return nil, ErrForeignEvent
+	}
+	return revisions, nil
+}
+
+// EventAt returns the event as it was at the given moment.
+func (a *App) EventAt(ctx context.Context, orgID, userID, id string, at time.Time) (storage.Event, error) {
+	revisions, err := a.EventHistory(ctx, orgID, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	var state *storage.Event
+	for _, revision := range revisions {
+		if revision.At.After(at) {
+			break
+		}
+		state = revision.After
+	}
+	if state == nil {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return *state, nil
+}
+
+// RestoreEvent brings the event back to the state saved in the given version.
+// A trashed event leaves the trash, a purged one is created again under the same ID.
+func (a *App) RestoreEvent(ctx context.Context, orgID, userID, id string, version int) (storage.Event, error) {
+	revisions, err := a.EventHistory(ctx, orgID, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if version < 1 || version > len(revisions) {
+		return storage.Event{}, storage.ErrRevisionNotFound
+	}
+	target := revisions[version-1].After
+	if target == nil {
+		return storage.Event{}, ErrNothingToRestore
+	}
+	event := *target
+
+	defer a.events.lock(orgID, id)()
+	current, err := a.storage.GetEvent(ctx, orgID, id)
+	purged := errors.Is(err, storage.ErrEventNotFound)
+	if err != nil && !purged {
+		return storage.Event{}, fmt.Errorf("get event: %w", err)
+	}
+	// An event coming back from the trash or the purge is checked as a new one.
+	var before *storage.Event
+	if !purged && !current.Deleted() {
+		before = &current
+	}
+	if err := a.checkAvailability(ctx, event, before); err != nil {
+		return storage.Event{}, err
+	}
+
+	switch {
+	case purged:
+		err := a.withinQuota(ctx, orgID, func() error {
+			return a.storage.CreateEvent(ctx, event)
+		})
+		if err != nil {
+			return storage.Event{}, fmt.Errorf("restore event: %w", err)
+		}
+		a.record(ctx, storage.RevisionRestored, userID, nil, &event)
+		a.publish(ctx, feed.ChangeCreated, event)
+	case current.Deleted():
+		err := a.withinQuota(ctx, orgID, func() error {
+			return a.storage.UpdateEvent(ctx, id, event)
+		})
+		if err != nil {
+			return storage.Event{}, fmt.Errorf("restore event: %w", err)
+		}
+		a.record(ctx, storage.RevisionRestored, userID, nil, &event)
+		a.publish(ctx, feed.ChangeCreated, event)
+	default:
+		if err := a.storage.UpdateEvent(ctx, id, event); err != nil {
+			return storage.Event{}, fmt.Errorf("restore event: %w", err)
+		}
+		a.record(ctx, storage.RevisionRestored, userID, &current, &event)
+		a.publish(ctx, feed.ChangeUpdated, event)
+	}
+	return event, nil
+}
+
+// record appends a revision to the event history. The change itself is
+// already stored, so a failure is logged rather than returned.
+func (a *App) record(ctx context.Context, action storage.RevisionAction, actor string, before, after *storage.Event) {
+	revision := storage.Revision{
+		Action:  action,
+		Actor:   actor,
+		At:      time.Now().UTC(),
+		Before:  before,
+		After:   after,
+		Changes: diff(before, after),
+	}
+	if after != nil {
+		revision.EventID, revision.OrgID = after.ID, after.OrgID
+	} else {
+		revision.EventID, revision.OrgID = before.ID, before.OrgID
+	}
+
+	if _, err := a.storage.AppendRevision(ctx, revision); err != nil {
+		a.logger.Error(fmt.Sprintf("failed to record %s of event %s: %s", action, revision.EventID, err))
+	}
+}
+
+func owner(revision storage.Revision) string {
+	if revision.After != nil {
+		return revision.After.UserID
+	}
+	return revision.Before.UserID
+}
+
+// diff lists changed fields, a missing side is rendered as empty values.
+func diff(before, after *storage.Event) []storage.FieldChange {
+	var b, a storage.Event
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"title", b.Title, a.Title},
+		{"startAt", formatTime(b.StartAt), formatTime(a.StartAt)},
+		{"endAt", formatTime(b.EndAt), formatTime(a.EndAt)},
+		{"description", b.Description, a.Description},
+		{"notifyBefore", formatDuration(b.NotifyBefore), formatDuration(a.NotifyBefore)},
+		{"reminders", formatReminders(b.Reminders), formatReminders(a.Reminders)},
+		{"attachments", formatAttachments(b.Attachments), formatAttachments(a.Attachments)},
+	}
+
+	var changes []storage.FieldChange
+	for _, f := range fields {
+		if f.before != f.after {
+			changes = append(changes, storage.FieldChange{Field: f.name, Before: f.before, After: f.after})
+		}
+	}
+	return changes
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatReminders(reminders []storage.Reminder) string {
+	parts := make([]string, 0, len(reminders))
+	for _, r := range reminders {
+		parts = append(parts, r.Before.String()+" via "+r.Channel)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreEvent(t *testing.T) {
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct {
+		quota tenant.Quota
+		// prepare changes the created event of alice and returns the
+		// version to restore.
+		prepare func(t *testing.T, a testApp, event storage.Event) int
+		err     error
+	}{
+		"live event": {
+			prepare: func(t *testing.T, a testApp, event storage.Event) int {
+				event.Title = "renamed"
+				_, err := a.UpdateEvent(ctx, event.ID, event)
+				require.NoError(t, err)
+				return 1
+			},
+		},
+		"trashed event": {
+			prepare: func(t *testing.T, a testApp, event storage.Event) int {
+				require.NoError(t, a.DeleteEvent(ctx, "", "alice", event.ID))
+				return 1
+			},
+		},
+		"purged event": {
+			prepare: func(t *testing.T, a testApp, event storage.Event) int {
+				require.NoError(t, a.storage.DeleteEvent(ctx, "", event.ID))
+				return 1
+			},
+		},
+		"purged event over quota": {
+			quota: tenant.Quota{MaxEvents: 1},
+			prepare: func(t *testing.T, a testApp, event storage.Event) int {
+				require.NoError(t, a.storage.DeleteEvent(ctx, "", event.ID))
+				_, err := a.CreateEvent(ctx, newEvent("", "alice", 14))
+				require.NoError(t, err)
+				return 1
+			},
+			err: tenant.ErrQuotaExceeded,
+		},
+		"purged with its history": {
+			prepare: func(t *testing.T, a testApp, event storage.Event) int {
+				require.NoError(t, a.DeleteEvent(ctx, "", "alice", event.ID))
+				_, err := a.storage.PurgeEvents(ctx, time.Now().Add(time.Hour), time.Time{})
+				require.NoError(t, err)
+				return 1
+			},
+			err: storage.ErrEventNotFound,
+		},
+		"deletion revision": {
+			prepare: func(t *testing.T, a testApp, event storage.Event) int {
+				require.NoError(t, a.DeleteEvent(ctx, "", "alice", event.ID))
+				return 2
+			},
+			err: ErrNothingToRestore,
+		},
+		"unknown version": {
+			prepare: func(*testing.T, testApp, storage.Event) int { return 2 },
+			err:     storage.ErrRevisionNotFound,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := newTestApp(t, tenant.Config{Default: tc.quota})
+			created, err := a.CreateEvent(ctx, newEvent("", "alice", 10))
+			require.NoError(t, err)
+			version := tc.prepare(t, a, created)
+
+			_, err = a.RestoreEvent(ctx, "", "bob", created.ID, version)
+			require.Error(t, err, "other users cannot restore the event")
+
+			restored, err := a.RestoreEvent(ctx, "", "alice", created.ID, version)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, created, restored)
+
+			stored, err := a.GetEvent(ctx, "", "alice", created.ID)
+			require.NoError(t, err)
+			require.Equal(t, created, stored)
+			revisions, err := a.EventHistory(ctx, "", "alice", created.ID)
+			require.NoError(t, err)
+			require.Equal(t, storage.RevisionRestored, revisions[len(revisions)-1].Action)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+)
+
+// MaxImportSize limits the number of events in one import.
+const MaxImportSize = 1000
+
+var (
+	ErrEmptyImport    = errors.New("import has no events")
+	ErrImportTooLarge = fmt.Errorf("import has more than %d events", MaxImportSize)
+)
+
+// ImportEvents creates the events for the user one by one, a failed event
+// is reported in its result and the rest are still created. A dry run
+// checks the events by the same rules, overlaps with stored events and
+// with each other included, and stores nothing.
+func (a *App) ImportEvents(
+	ctx context.Context, orgID, userID string, events []storage.Event, dryRun bool,
+) ([]BatchResult, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(events) == 0:
+		return nil, ErrEmptyImport
+	case len(events) > MaxImportSize:
+		return nil, ErrImportTooLarge
+	}
+
+	results := make([]BatchResult, len(events))
+	if dryRun {
+		return results, a.checkImport(ctx, orgID, userID, events, results)
+	}
+	for i, event := range events {
+		event.OrgID, event.UserID = orgID, userID
+		c, err := a.createEvent(ctx, event)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		a.commit(ctx, c)
+		results[i].Event = *c.after
+	}
+	return results, nil
+}
+
+// checkImport fills the results of a dry run, events that would be created
+// are returned without an ID.
+func (a *App) checkImport(
+	ctx context.Context, orgID, userID string, events []storage.Event, results []BatchResult,
+) error {
+	quota := a.tenants.Quota(orgID).MaxEvents
+	count := 0
+	if quota > 0 {
+		var err error
+		if count, err = a.storage.CountEvents(ctx, orgID); err != nil {
+			return fmt.Errorf("count events: %w", err)
+		}
+	}
+
+	planned := make([]storage.Event, 0, len(events))
+	for i, event := range events {
+		event.OrgID, event.UserID = orgID, userID
+		if err := a.checkCreate(ctx, event, planned); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if quota > 0 && count+len(planned) >= quota {
+			results[i].Err = fmt.Errorf("%w: at most %d events", tenant.ErrQuotaExceeded, quota)
+			continue
+		}
+		planned = append(planned, event)
+		results[i].Event = event
+	}
+	return nil
+}
+
+// checkCreate runs the checks of createEvent without storing the event,
+// planned are events to be created before it.
+func (a *App) checkCreate(ctx context.Context, event storage.Event, planned []storage.Event) error {
+	if err := validate(event); err != nil {
+		return err
+	}
+	if err := a.checkAvailability(ctx, event, nil); err != nil {
+		return err
+	}
+
+	stored, err := a.storage.ListEvents(ctx, event.OrgID, event.UserID, event.StartAt, event.EndAt)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	overlaps := func(other storage.Event) bool {
+		return event.StartAt.Before(other.EndAt) && event.EndAt.After(other.StartAt)
+	}
+	if len(stored) > 0 || slices.ContainsFunc(planned, overlaps) {
+		return storage.ErrDateBusy
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package app
+
+import "sync"
+
+type eventKey struct {
+	orgID string
+	id    string
+}
+
+// eventLocks serializes the writes of each event, writes of different
+// events do not wait for each other.
+type eventLocks struct {
+	mu    sync.Mutex
+	locks map[eventKey]*eventLock
+}
+
+type eventLock struct {
+	mu sync.Mutex
+	// refs counts the holder and the waiters, the lock is dropped at zero.
+	refs int
+}
+
+// lock locks the event and returns the function unlocking it.
+func (l *eventLocks) lock(orgID, id string) (unlock func()) {
+	key := eventKey{orgID: orgID, id: id}
+
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[eventKey]*eventLock)
+	}
+	el, ok := l.locks[key]
+	if !ok {
+		el = &eventLock{}
+		l.locks[key] = el
+	}
+	el.refs++
+	l.mu.Unlock()
+
+	el.mu.Lock()
+	return func() {
+		el.mu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		el.refs--
+		if el.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
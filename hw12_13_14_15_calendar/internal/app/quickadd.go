@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/quickadd"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+var (
+	ErrInvalidTimeZone = errors.New("unknown time zone")
+	ErrNotUnderstood   = errors.New("phrase is not understood")
+)
+
+// QuickAdd reads an event from a short phrase in the tz time zone, UTC when
+// empty. The event is validated but not stored: the caller shows the parts
+// to the user and creates the event once it is confirmed.
+func (a *App) QuickAdd(_ context.Context, orgID, userID, text, tz string) (storage.Event, []quickadd.Part, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return storage.Event{}, nil, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return storage.Event{}, nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, tz)
+	}
+
+	result, err := quickadd.Parse(text, time.Now(), loc)
+	if err != nil {
+		return storage.Event{}, nil, fmt.Errorf("%w: %w", ErrNotUnderstood, err)
+	}
+
+	event := storage.Event{
+		Title:   result.Title,
+		StartAt: result.StartAt,
+		EndAt:   result.EndAt,
+		OrgID:   orgID,
+		UserID:  userID,
+	}
+	for _, before := range result.Reminders {
+		event.Reminders = append(event.Reminders, storage.Reminder{Before: before, Channel: reminder.DefaultChannel})
+	}
+	if err := validate(event); err != nil {
+		return storage.Event{}, nil, err
+	}
+	return event, result.Parts, nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+)
+
+// maxFreeBusyRange bounds a free/busy query.
+const maxFreeBusyRange = 366 * 24 * time.Hour
+
+var ErrInvalidRange = errors.New("range must end after it starts and span at most a year")
+
+// BusyInterval is a period taken by events or subscribed entries.
+type BusyInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Subscribe adds a subscribed calendar of the user, they count towards the
+// calendar quota of the organization.
+func (a *App) Subscribe(ctx context.Context, orgID, userID, name, source string) (subscription.Subscription, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return subscription.Subscription{}, err
+	}
+
+	limit := a.tenants.Quota(orgID).MaxCalendars
+	sub, err := a.subscriptions.Subscribe(ctx, orgID, userID, name, source, limit)
+	if errors.Is(err, subscription.ErrLimitReached) {
+		return subscription.Subscription{}, fmt.Errorf("%w: at most %d calendars", tenant.ErrQuotaExceeded, limit)
+	}
+	return sub, err
+}
+
+func (a *App) ListSubscriptions(ctx context.Context, orgID, userID string) ([]subscription.Subscription, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+	return a.subscriptions.List(ctx, orgID, userID)
+}
+
+func (a *App) Unsubscribe(ctx context.Context, orgID, userID, id string) error {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return err
+	}
+	return a.subscriptions.Unsubscribe(ctx, orgID, userID, id)
+}
+
+// FreeBusy returns busy intervals of the user within [from, to): own live
+// events, subscribed entries, off hours and absences, overlapping ones merged.
+func (a *App) FreeBusy(ctx context.Context, orgID, userID string, from, to time.Time) ([]BusyInterval, error) {
+	if !to.After(from) || to.Sub(from) > maxFreeBusyRange {
+		return nil, ErrInvalidRange
+	}
+	events, err := a.ListEvents(ctx, orgID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := a.availability.Get(ctx, orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get availability: %w", err)
+	}
+	return busyIntervals(events, settings.Unavailable(from, to), from, to), nil
+}
+
+func busyIntervals(
+	events []storage.Event, unavailable []availability.Interval, from, to time.Time,
+) []BusyInterval {
+	intervals := make([]BusyInterval, 0, len(events)+len(unavailable))
+	for _, interval := range unavailable {
+		intervals = append(intervals, BusyInterval(interval))
+	}
+	for _, event := range events {
+		start, end := event.StartAt, event.EndAt
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		intervals = append(intervals, BusyInterval{Start: start, End: end})
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start.Before(intervals[j].Start)
+	})
+
+	merged := make([]BusyInterval, 0, len(intervals))
+	for _, interval := range intervals {
+		if last := len(merged) - 1; last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (a *App) ListTrash(ctx context.Context, orgID, userID string) ([]storage.Event, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.ListDeleted(ctx, orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	return events, nil
+}
+
+// RestoreDeleted takes the event out of the trash. It fails with
+// storage.ErrDateBusy if its time has been taken meanwhile, with
+// tenant.ErrQuotaExceeded if the organization has no room for it and with
+// availability errors if the user became unavailable at its time.
+func (a *App) RestoreDeleted(ctx context.Context, orgID, userID, id string) (storage.Event, error) {
+	if err := a.checkOwner(orgID, userID); err != nil {
+		return storage.Event{}, err
+	}
+
+	defer a.events.lock(orgID, id)()
+	trashed, err := a.storage.GetEvent(ctx, orgID, id)
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("get event: %w", err)
+	}
+	if trashed.UserID != userID {
+		return storage.Event{}, ErrForeignEvent
+	}
+	if !trashed.Deleted() {
+		return storage.Event{}, fmt.Errorf("get event: %w", storage.ErrEventNotFound)
+	}
+
+	event := trashed
+	event.DeletedAt = time.Time{}
+	if err := a.checkAvailability(ctx, event, nil); err != nil {
+		return storage.Event{}, err
+	}
+	err = a.withinQuota(ctx, orgID, func() error {
+		return a.storage.UpdateEvent(ctx, id, event)
+	})
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("restore event: %w", err)
+	}
+
+	a.record(ctx, storage.RevisionRestored, userID, nil, &event)
+	a.publish(ctx, feed.ChangeCreated, event)
+	return event, nil
+}
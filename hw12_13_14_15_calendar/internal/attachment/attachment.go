@@ -0,0 +1,185 @@
+// Package attachment keeps files attached to events. The event stores only
+// the metadata, the content lives in a BlobStore under the attachment ID.
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBlobNotFound       = errors.New("blob not found")
+	ErrInvalidKey         = errors.New("invalid blob key")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrInvalidName        = errors.New("attachment name is required")
+	ErrTooLarge           = errors.New("attachment is too large")
+	ErrTypeNotAllowed     = errors.New("attachment type is not allowed")
+)
+
+// sniffLen is how much content http.DetectContentType looks at.
+const sniffLen = 512
+
+// BlobStore keeps attachment contents by key.
+type BlobStore interface {
+	// Put stores the content read from r, an error of r is returned as is
+	// and leaves nothing stored.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get fails with ErrBlobNotFound for a missing key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob, a missing one is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+type Config struct {
+	// MaxSize limits a single attachment in bytes, zero means no limit.
+	MaxSize int64
+	// Types are allowed media types such as application/pdf or image/*,
+	// empty allows any type.
+	Types []string
+}
+
+// Store checks uploads against the limits and passes them to the blobs.
+type Store struct {
+	blobs BlobStore
+
+	mu     sync.RWMutex
+	config Config
+	now    func() time.Time
+}
+
+func NewStore(blobs BlobStore, config Config) *Store {
+	return &Store{
+		blobs:  blobs,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Reconfigure applies new limits to the next uploads.
+func (s *Store) Reconfigure(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+func (s *Store) currentConfig() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Upload stores body as a new attachment. An empty content type is
+// detected from the content.
+func (s *Store) Upload(ctx context.Context, name, contentType string, body io.Reader) (storage.Attachment, error) {
+	config := s.currentConfig()
+
+	name = filepath.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return storage.Attachment{}, ErrInvalidName
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return storage.Attachment{}, fmt.Errorf("read attachment: %w", err)
+	}
+	head = head[:n]
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return storage.Attachment{}, fmt.Errorf("%w: %q", ErrTypeNotAllowed, contentType)
+	}
+	if !allowed(config.Types, mediaType) {
+		return storage.Attachment{}, fmt.Errorf("%w: %s", ErrTypeNotAllowed, mediaType)
+	}
+
+	content := &limitedReader{r: io.MultiReader(bytes.NewReader(head), body), limit: config.MaxSize}
+	attachment := storage.Attachment{
+		ID:          uuid.NewString(),
+		Name:        name,
+		ContentType: contentType,
+		CreatedAt:   s.now().UTC(),
+	}
+	if err := s.blobs.Put(ctx, attachment.ID, content); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return storage.Attachment{}, fmt.Errorf("%w: at most %d bytes", ErrTooLarge, config.MaxSize)
+		}
+		return storage.Attachment{}, fmt.Errorf("store attachment: %w", err)
+	}
+	attachment.Size = content.read
+	return attachment, nil
+}
+
+func (s *Store) Open(ctx context.Context, id string) (io.ReadCloser, error) {
+	r, err := s.blobs.Get(ctx, id)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	return r, err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.blobs.Delete(ctx, id)
+}
+
+// allowed reports whether the media type matches one of types, a type
+// ending in /* matches the whole group.
+func allowed(types []string, mediaType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if group, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, group+"/") {
+				return true
+			}
+		} else if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader fails with ErrTooLarge once more than limit bytes are read.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.limit > 0 && l.read > l.limit {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// validKey accepts keys that are safe as file names, such as UUIDs.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
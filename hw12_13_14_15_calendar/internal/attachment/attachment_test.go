@@ -0,0 +1,68 @@
+package attachment
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs, err := NewFS(dir)
+	require.NoError(t, err)
+	store := NewStore(blobs, Config{MaxSize: 16, Types: []string{"text/plain", "image/*"}})
+
+	agenda, err := store.Upload(ctx, "../../agenda.txt", "", strings.NewReader("1. standup"))
+	require.NoError(t, err)
+	require.Equal(t, "agenda.txt", agenda.Name, "directories are dropped")
+	require.Equal(t, "text/plain; charset=utf-8", agenda.ContentType, "type is detected")
+	require.EqualValues(t, 10, agenda.Size)
+
+	r, err := store.Open(ctx, agenda.ID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "1. standup", string(data))
+
+	_, err = store.Upload(ctx, "photo.png", "image/png", strings.NewReader("png"))
+	require.NoError(t, err, "image/* allows any image")
+	_, err = store.Upload(ctx, "report.pdf", "application/pdf", strings.NewReader("%PDF"))
+	require.ErrorIs(t, err, ErrTypeNotAllowed)
+	_, err = store.Upload(ctx, " ", "text/plain", strings.NewReader("x"))
+	require.ErrorIs(t, err, ErrInvalidName)
+	_, err = store.Upload(ctx, "long.txt", "text/plain", strings.NewReader(strings.Repeat("x", 17)))
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2, "rejected uploads leave no files")
+
+	require.NoError(t, store.Delete(ctx, agenda.ID))
+	require.NoError(t, store.Delete(ctx, agenda.ID), "deleting twice is fine")
+	_, err = store.Open(ctx, agenda.ID)
+	require.ErrorIs(t, err, ErrAttachmentNotFound)
+
+	store.Reconfigure(Config{})
+	_, err = store.Upload(ctx, "report.pdf", "application/pdf", strings.NewReader(strings.Repeat("x", 100)))
+	require.NoError(t, err, "no limits")
+}
+
+func TestBlobKeys(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := NewFS(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "..", "../x", "a/b"} {
+		require.ErrorIs(t, blobs.Put(ctx, key, strings.NewReader("x")), ErrInvalidKey, key)
+		_, err := blobs.Get(ctx, key)
+		require.ErrorIs(t, err, ErrBlobNotFound, key)
+	}
+	_, err = NewMemory().Get(ctx, "missing")
+	require.ErrorIs(t, err, ErrBlobNotFound)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// UserIDHeader carries the user ID in the header mode.
+	UserIDHeader = "X-User-ID"
+	// OrgIDHeader carries the organization ID in the header mode.
+	OrgIDHeader = "X-Org-ID"
+)
+
+var (
+	ErrNoCredentials = errors.New("no credentials")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrTokenExpired  = errors.New("token expired")
+)
+
+// Identity is the authenticated user. OrgID is empty for users outside of
+// organizations, UserID is empty for anonymous requests.
+type Identity struct {
+	OrgID  string
+	UserID string
+}
+
+type (
+	userKey struct{}
+	orgKey  struct{}
+)
+
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserID returns the authenticated user ID or "" for anonymous requests.
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+func WithOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgID)
+}
+
+// OrgID returns the organization of the authenticated user, "" when there
+// is none.
+func OrgID(ctx context.Context) string {
+	orgID, _ := ctx.Value(orgKey{}).(string)
+	return orgID
+}
+
+// Header trusts the user and organization IDs passed by the client. For
+// development only.
+type Header struct{}
+
+func (Header) Authenticate(r *http.Request) (Identity, error) {
+	return Identity{OrgID: r.Header.Get(OrgIDHeader), UserID: r.Header.Get(UserIDHeader)}, nil
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	const prefix = "bearer "
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrNoCredentials
+	}
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", ErrInvalidToken
+	}
+	return strings.TrimSpace(header[len(prefix):]), nil
+}
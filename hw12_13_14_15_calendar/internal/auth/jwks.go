@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+type keySet struct {
+	rsa  map[string]*rsa.PublicKey
+	hmac map[string][]byte
+}
+
+// loadJWKS reads RSA and symmetric ("oct") keys from a JWKS file.
+// Keys meant for encryption are skipped.
+func loadJWKS(path string) (keySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keySet{}, err
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return keySet{}, fmt.Errorf("parse jwks %s: %w", path, err)
+	}
+
+	keys := keySet{rsa: make(map[string]*rsa.PublicKey), hmac: make(map[string][]byte)}
+	for i, key := range doc.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			pub, err := key.rsaPublicKey()
+			if err != nil {
+				return keySet{}, fmt.Errorf("jwks key %d: %w", i, err)
+			}
+			keys.rsa[key.Kid] = pub
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil {
+				return keySet{}, fmt.Errorf("jwks key %d: %w", i, err)
+			}
+			keys.hmac[key.Kid] = secret
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, fmt.Errorf("malformed rsa key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
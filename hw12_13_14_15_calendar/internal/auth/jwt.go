@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type JWTConfig struct {
+	// Secret verifies HS256 tokens without a key ID.
+	Secret string
+	// JWKSFile is a local JSON Web Key Set with RSA and "oct" keys.
+	JWKSFile string
+	// UserClaim names the claim holding the user ID, "sub" by default.
+	UserClaim string
+	// OrgClaim names the optional claim holding the organization ID, "org"
+	// by default.
+	OrgClaim string
+	// Issuer and Audience are checked when set.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew in exp and nbf checks.
+	Leeway time.Duration
+}
+
+// JWT authenticates requests by a bearer token signed with HS256 or RS256.
+type JWT struct {
+	config JWTConfig
+	keys   keySet
+	now    func() time.Time
+}
+
+func NewJWT(config JWTConfig) (*JWT, error) {
+	if config.UserClaim == "" {
+		config.UserClaim = "sub"
+	}
+	if config.OrgClaim == "" {
+		config.OrgClaim = "org"
+	}
+
+	keys := keySet{rsa: map[string]*rsa.PublicKey{}, hmac: map[string][]byte{}}
+	if config.JWKSFile != "" {
+		var err error
+		if keys, err = loadJWKS(config.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+	if config.Secret != "" {
+		keys.hmac[""] = []byte(config.Secret)
+	}
+	if len(keys.rsa) == 0 && len(keys.hmac) == 0 {
+		return nil, errors.New("jwt auth needs a secret or a jwks file with signing keys")
+	}
+
+	return &JWT{config: config, keys: keys, now: time.Now}, nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (Identity, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return Identity{}, err
+	}
+	return j.Verify(token)
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the token signature and registered claims and returns the
+// user and organization IDs.
+func (j *JWT) Verify(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, ErrInvalidToken
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+	if err := j.verifySignature(header, parts[0]+"."+parts[1], signature); err != nil {
+		return Identity{}, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, err
+	}
+	if err := j.verifyClaims(claims); err != nil {
+		return Identity{}, err
+	}
+
+	userID, _ := claims[j.config.UserClaim].(string)
+	if userID == "" {
+		return Identity{}, fmt.Errorf("%w: no %q claim", ErrInvalidToken, j.config.UserClaim)
+	}
+	orgID, ok := claims[j.config.OrgClaim].(string)
+	if !ok && claims[j.config.OrgClaim] != nil {
+		return Identity{}, fmt.Errorf("%w: %q claim is not a string", ErrInvalidToken, j.config.OrgClaim)
+	}
+	return Identity{OrgID: orgID, UserID: userID}, nil
+}
+
+func (j *JWT) verifySignature(header tokenHeader, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch header.Alg {
+	case "HS256":
+		secret, ok := pickKey(j.keys.hmac, header.Kid)
+		if !ok {
+			return fmt.Errorf("%w: unknown key", ErrInvalidToken)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case "RS256":
+		key, ok := pickKey(j.keys.rsa, header.Kid)
+		if !ok {
+			return fmt.Errorf("%w: unknown key", ErrInvalidToken)
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+	return nil
+}
+
+// pickKey finds the key by ID. Tokens without an ID match the only key of the type.
+func pickKey[K any](keys map[string]K, kid string) (K, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+func (j *JWT) verifyClaims(claims map[string]any) error {
+	now := j.now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(j.config.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(j.config.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+
+	if j.config.Issuer != "" && claims["iss"] != j.config.Issuer {
+		return fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	}
+	if j.config.Audience != "" && !hasAudience(claims["aud"], j.config.Audience) {
+		return fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+// hasAudience handles aud given both as a string and as a list.
+func hasAudience(aud any, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []any:
+		for _, a := range aud {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
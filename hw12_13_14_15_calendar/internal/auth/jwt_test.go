@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sign(t *testing.T, header, claims map[string]any, key any) string {
+	t.Helper()
+
+	h, err := json.Marshal(header)
+	require.NoError(t, err)
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+
+	doc := map[string]any{"keys": []map[string]any{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestJWT(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	exp := now.Add(time.Hour).Unix()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	j, err := NewJWT(JWTConfig{
+		Secret:    "top-secret",
+		JWKSFile:  writeJWKS(t, "main", &rsaKey.PublicKey),
+		UserClaim: "uid",
+		Issuer:    "https://id.example.com",
+		Audience:  "calendar",
+		Leeway:    time.Minute,
+	})
+	require.NoError(t, err)
+	j.now = func() time.Time { return now }
+
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"uid": "alice", "exp": exp, "iss": "https://id.example.com", "aud": []string{"calendar"}}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	rs256 := map[string]any{"alg": "RS256", "kid": "main"}
+
+	t.Run("valid tokens", func(t *testing.T) {
+		identity, err := j.Verify(sign(t, hs256, claims(nil), []byte("top-secret")))
+		require.NoError(t, err)
+		require.Equal(t, Identity{UserID: "alice"}, identity)
+
+		identity, err = j.Verify(sign(t, rs256, claims(map[string]any{"aud": "calendar", "org": "acme"}), rsaKey))
+		require.NoError(t, err)
+		require.Equal(t, Identity{OrgID: "acme", UserID: "alice"}, identity)
+
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer "+sign(t, rs256, claims(nil), rsaKey))
+		identity, err = j.Authenticate(r)
+		require.NoError(t, err)
+		require.Equal(t, Identity{UserID: "alice"}, identity)
+	})
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		later := now.Add(time.Hour).Unix()
+		tests := map[string]string{
+			"wrong secret":   sign(t, hs256, claims(nil), []byte("guess")),
+			"wrong rsa key":  sign(t, rs256, claims(nil), otherKey),
+			"unknown kid":    sign(t, map[string]any{"alg": "RS256", "kid": "old"}, claims(nil), rsaKey),
+			"alg none":       sign(t, map[string]any{"alg": "none"}, claims(nil), nil),
+			"wrong issuer":   sign(t, hs256, claims(map[string]any{"iss": "evil"}), []byte("top-secret")),
+			"wrong audience": sign(t, hs256, claims(map[string]any{"aud": "billing"}), []byte("top-secret")),
+			"no user claim":  sign(t, hs256, claims(map[string]any{"uid": ""}), []byte("top-secret")),
+			"numeric org":    sign(t, hs256, claims(map[string]any{"org": 42}), []byte("top-secret")),
+			"no exp":         sign(t, hs256, claims(map[string]any{"exp": nil}), []byte("top-secret")),
+			"not yet valid":  sign(t, hs256, claims(map[string]any{"nbf": later}), []byte("top-secret")),
+			"malformed":      "abc.def",
+		}
+		for name, token := range tests {
+			_, err := j.Verify(token)
+			require.ErrorIs(t, err, ErrInvalidToken, name)
+		}
+	})
+
+	t.Run("expiry with leeway", func(t *testing.T) {
+		token := sign(t, hs256, claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}), []byte("top-secret"))
+		_, err := j.Verify(token)
+		require.NoError(t, err)
+
+		token = sign(t, hs256, claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}), []byte("top-secret"))
+		_, err = j.Verify(token)
+		require.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("missing credentials", func(t *testing.T) {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		_, err = j.Authenticate(r)
+		require.ErrorIs(t, err, ErrNoCredentials)
+
+		r.Header.Set("Authorization", "Basic YWxpY2U6cHdk")
+		_, err = j.Authenticate(r)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		_, err := NewJWT(JWTConfig{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,272 @@
+// Package availability keeps when users can be booked: working hours per
+// weekday in the user time zone and out-of-office periods. Free/busy and
+// the slot finder treat time outside of them as busy, strict users cannot
+// book it at all, and the invitation policy answers invitations into an
+// absence.
+package availability
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxPeriods  = 10
+	maxAbsences = 100
+)
+
+var (
+	ErrInvalidSettings     = errors.New("invalid availability settings")
+	ErrSettingsExist       = errors.New("availability settings already exist")
+	ErrOutOfOffice         = errors.New("time falls into an out-of-office period")
+	ErrOutsideWorkingHours = errors.New("time is outside working hours")
+)
+
+// Policy tells how invitations into an out-of-office period are answered.
+type Policy string
+
+const (
+	// PolicyNone leaves such invitations to the user.
+	PolicyNone      Policy = ""
+	PolicyTentative Policy = "tentative"
+	PolicyDecline   Policy = "decline"
+)
+
+type Response string
+
+const (
+	// ResponseNone means the invitation waits for the user.
+	ResponseNone      Response = ""
+	ResponseTentative Response = "tentative"
+	ResponseDeclined  Response = "declined"
+)
+
+// Period is a part of a day, Start and End are offsets from midnight. End
+// may be 24h, periods never cross midnight.
+type Period struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+type Absence struct {
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+type Settings struct {
+	OrgID  string
+	UserID string
+	// TimeZone is the IANA zone of working hours, UTC when empty.
+	TimeZone string
+	// Hours lists working periods per weekday. Nil means no working hours
+	// are set and every hour is a working one, a weekday without periods
+	// is a day off.
+	Hours       map[time.Weekday][]Period
+	OutOfOffice []Absence
+	Policy      Policy
+	// Strict rejects own events outside working hours or in an absence.
+	Strict bool
+}
+
+// validate checks the settings and sorts their periods and absences.
+func (s *Settings) validate() error {
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidSettings, s.TimeZone)
+	}
+	switch s.Policy {
+	case PolicyNone, PolicyTentative, PolicyDecline:
+	default:
+		return fmt.Errorf("%w: unknown invitation policy %q", ErrInvalidSettings, s.Policy)
+	}
+
+	for weekday, periods := range s.Hours {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return fmt.Errorf("%w: unknown weekday %d", ErrInvalidSettings, weekday)
+		}
+		if len(periods) > maxPeriods {
+			return fmt.Errorf("%w: at most %d periods per day", ErrInvalidSettings, maxPeriods)
+		}
+		sort.Slice(periods, func(i, j int) bool { return periods[i].Start < periods[j].Start })
+		for i, p := range periods {
+			switch {
+			case p.Start < 0 || p.End > 24*time.Hour || p.End <= p.Start:
+				return fmt.Errorf("%w: %s period %s must end after it starts within the day",
+					ErrInvalidSettings, weekday, formatPeriod(p))
+			case p.Start%time.Minute != 0 || p.End%time.Minute != 0:
+				return fmt.Errorf("%w: %s period %s is not in whole minutes",
+					ErrInvalidSettings, weekday, formatPeriod(p))
+			case i > 0 && p.Start < periods[i-1].End:
+				return fmt.Errorf("%w: %s periods overlap", ErrInvalidSettings, weekday)
+			}
+		}
+	}
+
+	if len(s.OutOfOffice) > maxAbsences {
+		return fmt.Errorf("%w: at most %d out-of-office periods", ErrInvalidSettings, maxAbsences)
+	}
+	for _, a := range s.OutOfOffice {
+		if !a.End.After(a.Start) {
+			return fmt.Errorf("%w: out-of-office period must end after it starts", ErrInvalidSettings)
+		}
+	}
+	sort.Slice(s.OutOfOffice, func(i, j int) bool { return s.OutOfOffice[i].Start.Before(s.OutOfOffice[j].Start) })
+	return nil
+}
+
+func (s Settings) location() *time.Location {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Absent returns the first absence overlapping [start, end).
+func (s Settings) Absent(start, end time.Time) (Absence, bool) {
+	for _, a := range s.OutOfOffice {
+		if a.Start.Before(end) && a.End.After(start) {
+			return a, true
+		}
+	}
+	return Absence{}, false
+}
+
+// OffHours returns the parts of [from, to) outside working hours in order.
+func (s Settings) OffHours(from, to time.Time) []Interval {
+	if s.Hours == nil || !to.After(from) {
+		return nil
+	}
+
+	loc := s.location()
+	var result []Interval
+	cursor := from
+	for day := startOfDay(from.In(loc)); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, p := range s.Hours[day.Weekday()] {
+			start, end := at(day, p.Start), at(day, p.End)
+			if !cursor.Before(to) {
+				break
+			}
+			if !end.After(cursor) {
+				continue
+			}
+			if start.After(cursor) {
+				result = append(result, Interval{Start: cursor, End: minTime(start, to)})
+			}
+			cursor = end
+		}
+	}
+	if cursor.Before(to) {
+		result = append(result, Interval{Start: cursor, End: to})
+	}
+	return result
+}
+
+// Unavailable returns the parts of [from, to) outside working hours or in
+// an absence. The intervals are ordered by start and may overlap.
+func (s Settings) Unavailable(from, to time.Time) []Interval {
+	result := s.OffHours(from, to)
+	for _, a := range s.OutOfOffice {
+		if a.Start.Before(to) && a.End.After(from) {
+			result = append(result, Interval{Start: maxTime(a.Start, from), End: minTime(a.End, to)})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result
+}
+
+// Check rejects [start, end) for an own event of a strict user.
+func (s Settings) Check(start, end time.Time) error {
+	if !s.Strict {
+		return nil
+	}
+	if a, ok := s.Absent(start, end); ok {
+		if a.Reason != "" {
+			return fmt.Errorf("%w: %s", ErrOutOfOffice, a.Reason)
+		}
+		return ErrOutOfOffice
+	}
+	if len(s.OffHours(start, end)) > 0 {
+		return ErrOutsideWorkingHours
+	}
+	return nil
+}
+
+// Respond answers an invitation to [start, end) by the policy, invitations
+// outside of absences are left to the user.
+func (s Settings) Respond(start, end time.Time) Response {
+	if _, ok := s.Absent(start, end); !ok {
+		return ResponseNone
+	}
+	switch s.Policy {
+	case PolicyDecline:
+		return ResponseDeclined
+	case PolicyTentative:
+		return ResponseTentative
+	}
+	return ResponseNone
+}
+
+// ParseClock reads a time of day such as 09:30, 24:00 is the end of the day.
+func ParseClock(s string) (time.Duration, error) {
+	var hours, minutes int
+	if n, err := fmt.Sscanf(s, "%2d:%2d", &hours, &minutes); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("%w: time of day %q is not HH:MM", ErrInvalidSettings, s)
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if minutes > 59 || d > 24*time.Hour {
+		return 0, fmt.Errorf("%w: time of day %q is out of range", ErrInvalidSettings, s)
+	}
+	return d, nil
+}
+
+func FormatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// ParseWeekday reads an English weekday name in any case.
+func ParseWeekday(name string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(name, weekday.String()) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown weekday %q", ErrInvalidSettings, name)
+}
+
+func formatPeriod(p Period) string {
+	return FormatClock(p.Start) + "-" + FormatClock(p.End)
+}
+
+// at returns the time of day offset on day, keeping wall clock across DST.
+func at(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(),
+		int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
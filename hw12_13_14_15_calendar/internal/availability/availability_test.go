@@ -0,0 +1,129 @@
+package availability
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func weekdays(periods ...Period) map[time.Weekday][]Period {
+	hours := make(map[time.Weekday][]Period)
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		hours[weekday] = periods
+	}
+	return hours
+}
+
+func TestOffHours(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	settings := Settings{
+		TimeZone: "Europe/Moscow",
+		Hours: weekdays(
+			Period{Start: 9 * time.Hour, End: 13 * time.Hour},
+			Period{Start: 14 * time.Hour, End: 18 * time.Hour},
+		),
+	}
+	// Friday noon to Monday noon.
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, moscow)
+	to := time.Date(2024, 3, 4, 12, 0, 0, 0, moscow)
+	require.Equal(t, []Interval{
+		{Start: time.Date(2024, 3, 1, 13, 0, 0, 0, moscow), End: time.Date(2024, 3, 1, 14, 0, 0, 0, moscow)},
+		{Start: time.Date(2024, 3, 1, 18, 0, 0, 0, moscow), End: time.Date(2024, 3, 4, 9, 0, 0, 0, moscow)},
+	}, settings.OffHours(from, to))
+
+	require.Empty(t, settings.OffHours(from.Add(2*time.Hour), from.Add(3*time.Hour)))
+	require.Empty(t, Settings{}.OffHours(from, to), "no working hours")
+
+	// The range ends in the evening after the last period.
+	to = time.Date(2024, 3, 1, 20, 0, 0, 0, moscow)
+	require.Equal(t, Interval{Start: time.Date(2024, 3, 1, 18, 0, 0, 0, moscow), End: to},
+		settings.OffHours(from, to)[1])
+}
+
+func TestCheckAndRespond(t *testing.T) {
+	absence := Absence{
+		Start:  time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+		Reason: "vacation",
+	}
+	settings := Settings{
+		Hours:       weekdays(Period{Start: 9 * time.Hour, End: 18 * time.Hour}),
+		OutOfOffice: []Absence{absence},
+	}
+	working := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, 3, 1, 19, 0, 0, 0, time.UTC)
+	vacation := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, settings.Check(evening, evening.Add(time.Hour)), "not strict")
+
+	settings.Strict = true
+	require.NoError(t, settings.Check(working, working.Add(time.Hour)))
+	require.ErrorIs(t, settings.Check(evening, evening.Add(time.Hour)), ErrOutsideWorkingHours)
+	require.ErrorIs(t, settings.Check(vacation, vacation.Add(time.Hour)), ErrOutOfOffice)
+
+	require.Equal(t, ResponseNone, settings.Respond(vacation, vacation.Add(time.Hour)))
+	settings.Policy = PolicyTentative
+	require.Equal(t, ResponseTentative, settings.Respond(vacation, vacation.Add(time.Hour)))
+	settings.Policy = PolicyDecline
+	require.Equal(t, ResponseDeclined, settings.Respond(vacation, vacation.Add(time.Hour)))
+	require.Equal(t, ResponseNone, settings.Respond(working, working.Add(time.Hour)))
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+
+	settings, err := store.Get(ctx, "", "alice")
+	require.NoError(t, err)
+	require.Equal(t, Settings{UserID: "alice"}, settings)
+
+	for _, invalid := range []Settings{
+		{UserID: "alice", TimeZone: "Mars/Olympus"},
+		{UserID: "alice", Policy: "ignore"},
+		{UserID: "alice", Hours: map[time.Weekday][]Period{time.Monday: {{Start: 18 * time.Hour, End: 9 * time.Hour}}}},
+		{UserID: "alice", Hours: map[time.Weekday][]Period{time.Monday: {
+			{Start: 9 * time.Hour, End: 13 * time.Hour}, {Start: 12 * time.Hour, End: 18 * time.Hour},
+		}}},
+		{UserID: "alice", OutOfOffice: []Absence{{Start: time.Now(), End: time.Now().Add(-time.Hour)}}},
+	} {
+		_, err := store.Set(ctx, invalid)
+		require.ErrorIs(t, err, ErrInvalidSettings)
+	}
+
+	hours := map[time.Weekday][]Period{time.Monday: {
+		{Start: 14 * time.Hour, End: 18 * time.Hour}, {Start: 9 * time.Hour, End: 13 * time.Hour},
+	}}
+	saved, err := store.Set(ctx, Settings{UserID: "alice", TimeZone: "Europe/Moscow", Hours: hours})
+	require.NoError(t, err)
+	require.Equal(t, 9*time.Hour, saved.Hours[time.Monday][0].Start, "periods are sorted")
+	require.Equal(t, 14*time.Hour, hours[time.Monday][0].Start, "caller settings are not changed")
+	settings, err = store.Get(ctx, "acme", "alice")
+	require.NoError(t, err)
+	require.Equal(t, Settings{OrgID: "acme", UserID: "alice"}, settings, "same user ID in another organization")
+
+	var exported []Settings
+	require.NoError(t, store.ExportAvailability(ctx, func(s Settings) error {
+		exported = append(exported, s)
+		return nil
+	}))
+	require.Equal(t, []Settings{saved}, exported)
+
+	require.ErrorIs(t, store.ImportAvailability(ctx, saved), ErrSettingsExist)
+	require.NoError(t, NewStore().ImportAvailability(ctx, saved))
+}
+
+func TestParseClock(t *testing.T) {
+	d, err := ParseClock("09:30")
+	require.NoError(t, err)
+	require.Equal(t, 9*time.Hour+30*time.Minute, d)
+	require.Equal(t, "24:00", FormatClock(24*time.Hour))
+
+	for _, s := range []string{"9:30", "24:01", "10:60", "noon"} {
+		_, err := ParseClock(s)
+		require.ErrorIs(t, err, ErrInvalidSettings, s)
+	}
+}
@@ -0,0 +1,108 @@
+package availability
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// owner keys the settings, user IDs are unique within an organization.
+type owner struct {
+	orgID  string
+	userID string
+}
+
+func (s Settings) owner() owner {
+	return owner{orgID: s.OrgID, userID: s.UserID}
+}
+
+// Store keeps availability settings of all users in memory.
+type Store struct {
+	mu       sync.RWMutex
+	settings map[owner]Settings
+}
+
+func NewStore() *Store {
+	return &Store{settings: make(map[owner]Settings)}
+}
+
+// Get returns the user settings, zero settings with UTC and no working
+// hours when the user has none.
+func (s *Store) Get(_ context.Context, orgID, userID string) (Settings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, ok := s.settings[owner{orgID: orgID, userID: userID}]
+	if !ok {
+		return Settings{OrgID: orgID, UserID: userID}, nil
+	}
+	return settings, nil
+}
+
+// Set replaces the user settings.
+func (s *Store) Set(_ context.Context, settings Settings) (Settings, error) {
+	settings = clone(settings)
+	if err := settings.validate(); err != nil {
+		return Settings{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[settings.owner()] = settings
+	return settings, nil
+}
+
+// ExportAvailability calls fn for settings of every user ordered by
+// organization and user ID.
+func (s *Store) ExportAvailability(_ context.Context, fn func(Settings) error) error {
+	s.mu.RLock()
+	all := make([]Settings, 0, len(s.settings))
+	for _, settings := range s.settings {
+		all = append(all, settings)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].OrgID != all[j].OrgID {
+			return all[i].OrgID < all[j].OrgID
+		}
+		return all[i].UserID < all[j].UserID
+	})
+	for _, settings := range all {
+		if err := fn(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportAvailability stores the settings of a user who has none.
+func (s *Store) ImportAvailability(_ context.Context, settings Settings) error {
+	settings = clone(settings)
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.settings[settings.owner()]; ok {
+		return ErrSettingsExist
+	}
+	s.settings[settings.owner()] = settings
+	return nil
+}
+
+// clone copies the slices and the map so the caller cannot change stored
+// settings.
+func clone(settings Settings) Settings {
+	if settings.Hours != nil {
+		hours := make(map[time.Weekday][]Period, len(settings.Hours))
+		for weekday, periods := range settings.Hours {
+			hours[weekday] = append([]Period{}, periods...)
+		}
+		settings.Hours = hours
+	}
+	settings.OutOfOffice = append([]Absence(nil), settings.OutOfOffice...)
+	return settings
+}
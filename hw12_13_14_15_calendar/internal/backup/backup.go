@@ -0,0 +1,298 @@
+// Package backup dumps calendar data to a versioned JSON-lines archive and
+// loads it back, so data can move between storage backends.
+//
+// The first line of an archive is a header with the format version, then
+// events, revisions, webhooks, calendar subscriptions, availability and
+// digest settings follow one record per line. The last line holds the record
+// counts and tells a complete archive from a truncated one.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+const (
+	// Version is written to new archives. Older versions are read as long as
+	// their records can be converted. Version 2 added subscriptions,
+	// version 3 availability settings, version 4 digest settings,
+	// version 5 organization IDs, version 6 attachment metadata.
+	Version = 6
+
+	format = "calendar-backup"
+
+	// maxLineSize bounds a single record, a revision holds two events.
+	maxLineSize = 4 << 20
+)
+
+var (
+	ErrInvalidArchive     = errors.New("invalid backup archive")
+	ErrUnsupportedVersion = errors.New("unsupported backup archive version")
+)
+
+// Source exports everything a storage holds, trashed events included.
+type Source interface {
+	ExportEvents(ctx context.Context, fn func(storage.Event) error) error
+	ExportRevisions(ctx context.Context, fn func(storage.Revision) error) error
+}
+
+// Sink stores records as they are, keeping IDs and revision versions.
+type Sink interface {
+	ImportEvent(ctx context.Context, event storage.Event) error
+	ImportRevision(ctx context.Context, revision storage.Revision) error
+}
+
+type WebhookSource interface {
+	ExportWebhooks(ctx context.Context, fn func(webhook.Webhook) error) error
+}
+
+type WebhookSink interface {
+	ImportWebhook(ctx context.Context, hook webhook.Webhook) error
+}
+
+type SubscriptionSource interface {
+	ExportSubscriptions(ctx context.Context, fn func(subscription.Subscription) error) error
+}
+
+type SubscriptionSink interface {
+	ImportSubscription(ctx context.Context, sub subscription.Subscription) error
+}
+
+type AvailabilitySource interface {
+	ExportAvailability(ctx context.Context, fn func(availability.Settings) error) error
+}
+
+type AvailabilitySink interface {
+	ImportAvailability(ctx context.Context, settings availability.Settings) error
+}
+
+type DigestSource interface {
+	ExportDigests(ctx context.Context, fn func(digest.Settings) error) error
+}
+
+type DigestSink interface {
+	ImportDigest(ctx context.Context, settings digest.Settings) error
+}
+
+// Sources are what Dump reads from.
+type Sources struct {
+	Events        Source
+	Webhooks      WebhookSource
+	Subscriptions SubscriptionSource
+	Availability  AvailabilitySource
+	Digests       DigestSource
+}
+
+// Sinks are what Load writes to.
+type Sinks struct {
+	Events        Sink
+	Webhooks      WebhookSink
+	Subscriptions SubscriptionSink
+	Availability  AvailabilitySink
+	Digests       DigestSink
+}
+
+// Stats counts the records of an archive.
+type Stats struct {
+	Events        int `json:"events"`
+	Revisions     int `json:"revisions"`
+	Webhooks      int `json:"webhooks"`
+	Subscriptions int `json:"subscriptions"`
+	Availability  int `json:"availability"`
+	Digests       int `json:"digests"`
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d events, %d revisions, %d webhooks, %d subscriptions, %d availability settings, %d digests",
+		s.Events, s.Revisions, s.Webhooks, s.Subscriptions, s.Availability, s.Digests)
+}
+
+type recordKind string
+
+const (
+	kindHeader       recordKind = "header"
+	kindEvent        recordKind = "event"
+	kindRevision     recordKind = "revision"
+	kindWebhook      recordKind = "webhook"
+	kindSubscription recordKind = "subscription"
+	kindAvailability recordKind = "availability"
+	kindDigest       recordKind = "digest"
+	kindEnd          recordKind = "end"
+)
+
+type record struct {
+	Kind recordKind `json:"kind"`
+
+	Format    string     `json:"format,omitempty"`
+	Version   int        `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+
+	Event        *eventRecord        `json:"event,omitempty"`
+	Revision     *revisionRecord     `json:"revision,omitempty"`
+	Webhook      *webhookRecord      `json:"webhook,omitempty"`
+	Subscription *subscriptionRecord `json:"subscription,omitempty"`
+	Availability *availabilityRecord `json:"availability,omitempty"`
+	Digest       *digestRecord       `json:"digest,omitempty"`
+
+	Stats *Stats `json:"stats,omitempty"`
+}
+
+// Dump writes all data of the sources to w.
+func Dump(ctx context.Context, w io.Writer, src Sources) (Stats, error) {
+	var stats Stats
+	enc := json.NewEncoder(w)
+	write := func(r record) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return enc.Encode(r)
+	}
+
+	now := time.Now().UTC()
+	if err := write(record{Kind: kindHeader, Format: format, Version: Version, CreatedAt: &now}); err != nil {
+		return stats, fmt.Errorf("write header: %w", err)
+	}
+	err := src.Events.ExportEvents(ctx, func(event storage.Event) error {
+		stats.Events++
+		return write(record{Kind: kindEvent, Event: newEventRecord(event)})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("dump events: %w", err)
+	}
+	err = src.Events.ExportRevisions(ctx, func(revision storage.Revision) error {
+		stats.Revisions++
+		return write(record{Kind: kindRevision, Revision: newRevisionRecord(revision)})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("dump revisions: %w", err)
+	}
+	err = src.Webhooks.ExportWebhooks(ctx, func(hook webhook.Webhook) error {
+		stats.Webhooks++
+		return write(record{Kind: kindWebhook, Webhook: newWebhookRecord(hook)})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("dump webhooks: %w", err)
+	}
+	err = src.Subscriptions.ExportSubscriptions(ctx, func(sub subscription.Subscription) error {
+		stats.Subscriptions++
+		return write(record{Kind: kindSubscription, Subscription: newSubscriptionRecord(sub)})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("dump subscriptions: %w", err)
+	}
+	err = src.Availability.ExportAvailability(ctx, func(settings availability.Settings) error {
+		stats.Availability++
+		return write(record{Kind: kindAvailability, Availability: newAvailabilityRecord(settings)})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("dump availability: %w", err)
+	}
+	err = src.Digests.ExportDigests(ctx, func(settings digest.Settings) error {
+		stats.Digests++
+		return write(record{Kind: kindDigest, Digest: newDigestRecord(settings)})
+	})
+	if err != nil {
+		return stats, fmt.Errorf("dump digests: %w", err)
+	}
+
+	if err := write(record{Kind: kindEnd, Stats: &stats}); err != nil {
+		return stats, fmt.Errorf("write end: %w", err)
+	}
+	return stats, nil
+}
+
+// Load reads an archive from r into the sinks. Records are stored as they
+// are read, so a failed load leaves the sinks partly filled.
+func Load(ctx context.Context, r io.Reader, dst Sinks) (Stats, error) {
+	var stats Stats
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	line := 0
+	next := func() (record, bool, error) {
+		if !scanner.Scan() {
+			return record{}, false, scanner.Err()
+		}
+		line++
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return record{}, false, fmt.Errorf("%w: line %d: %s", ErrInvalidArchive, line, err)
+		}
+		return rec, true, nil
+	}
+
+	header, ok, err := next()
+	switch {
+	case err != nil:
+		return stats, err
+	case !ok || header.Kind != kindHeader || header.Format != format:
+		return stats, fmt.Errorf("%w: no header", ErrInvalidArchive)
+	case header.Version < 1 || header.Version > Version:
+		return stats, fmt.Errorf("%w %d, this build reads up to %d", ErrUnsupportedVersion, header.Version, Version)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		rec, ok, err := next()
+		if err != nil {
+			return stats, err
+		}
+		if !ok {
+			return stats, fmt.Errorf("%w: archive is truncated", ErrInvalidArchive)
+		}
+
+		switch {
+		case rec.Kind == kindEvent && rec.Event != nil:
+			if err := dst.Events.ImportEvent(ctx, rec.Event.toEvent()); err != nil {
+				return stats, fmt.Errorf("line %d: restore event %s: %w", line, rec.Event.ID, err)
+			}
+			stats.Events++
+		case rec.Kind == kindRevision && rec.Revision != nil:
+			if err := dst.Events.ImportRevision(ctx, rec.Revision.toRevision()); err != nil {
+				return stats, fmt.Errorf("line %d: restore revision %d of %s: %w",
+					line, rec.Revision.Version, rec.Revision.EventID, err)
+			}
+			stats.Revisions++
+		case rec.Kind == kindWebhook && rec.Webhook != nil:
+			if err := dst.Webhooks.ImportWebhook(ctx, rec.Webhook.toWebhook()); err != nil {
+				return stats, fmt.Errorf("line %d: restore webhook %s: %w", line, rec.Webhook.ID, err)
+			}
+			stats.Webhooks++
+		case rec.Kind == kindSubscription && rec.Subscription != nil:
+			if err := dst.Subscriptions.ImportSubscription(ctx, rec.Subscription.toSubscription()); err != nil {
+				return stats, fmt.Errorf("line %d: restore subscription %s: %w", line, rec.Subscription.ID, err)
+			}
+			stats.Subscriptions++
+		case rec.Kind == kindAvailability && rec.Availability != nil:
+			if err := dst.Availability.ImportAvailability(ctx, rec.Availability.toSettings()); err != nil {
+				return stats, fmt.Errorf("line %d: restore availability of %s: %w", line, rec.Availability.UserID, err)
+			}
+			stats.Availability++
+		case rec.Kind == kindDigest && rec.Digest != nil:
+			if err := dst.Digests.ImportDigest(ctx, rec.Digest.toSettings()); err != nil {
+				return stats, fmt.Errorf("line %d: restore digest of %s: %w", line, rec.Digest.UserID, err)
+			}
+			stats.Digests++
+		case rec.Kind == kindEnd && rec.Stats != nil:
+			if *rec.Stats != stats {
+				return stats, fmt.Errorf("%w: archive lists %+v, read %+v", ErrInvalidArchive, *rec.Stats, stats)
+			}
+			return stats, nil
+		default:
+			return stats, fmt.Errorf("%w: line %d: unexpected %q record", ErrInvalidArchive, line, rec.Kind)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func newDispatcher() *webhook.Dispatcher {
+	return webhook.NewDispatcher(logger.NewWithWriter("ERROR", io.Discard), webhook.Config{LogSize: 10})
+}
+
+func newSubscriptions() *subscription.Manager {
+	return subscription.NewManager(logger.NewWithWriter("ERROR", io.Discard), subscription.Config{})
+}
+
+func newSinks() Sinks {
+	return Sinks{
+		Events:        memorystorage.New(),
+		Webhooks:      newDispatcher(),
+		Subscriptions: newSubscriptions(),
+		Availability:  availability.NewStore(),
+		Digests:       digest.NewStore(),
+	}
+}
+
+func TestDumpLoad(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	src := memorystorage.New()
+	live := storage.Event{
+		ID:           "1",
+		OrgID:        "acme",
+		UserID:       "user",
+		Title:        "standup",
+		StartAt:      start,
+		EndAt:        start.Add(15 * time.Minute),
+		NotifyBefore: 5 * time.Minute,
+		Reminders:    []storage.Reminder{{Before: 24 * time.Hour, Channel: "log"}},
+		Attachments: []storage.Attachment{{
+			ID:          "a",
+			Name:        "agenda.pdf",
+			ContentType: "application/pdf",
+			Size:        1024,
+			CreatedAt:   start.UTC(),
+		}},
+	}
+	trashed := storage.Event{
+		ID:        "2",
+		OrgID:     "acme",
+		UserID:    "user",
+		Title:     "retro",
+		StartAt:   start,
+		EndAt:     start.Add(time.Hour),
+		DeletedAt: start.Add(-time.Hour).UTC(),
+	}
+	require.NoError(t, src.CreateEvent(ctx, live))
+	require.NoError(t, src.CreateEvent(ctx, trashed))
+	_, err := src.AppendRevision(ctx, storage.Revision{
+		EventID: "1",
+		OrgID:   "acme",
+		Action:  storage.RevisionCreated,
+		After:   &live,
+	})
+	require.NoError(t, err)
+	_, err = src.AppendRevision(ctx, storage.Revision{
+		EventID: "1",
+		OrgID:   "acme",
+		Action:  storage.RevisionUpdated,
+		Before:  &live,
+		After:   &live,
+		Changes: []storage.FieldChange{{Field: "title", Before: "sync", After: "standup"}},
+	})
+	require.NoError(t, err)
+
+	srcHooks := newDispatcher()
+	hook, err := srcHooks.Register(ctx, "acme", "user", "http://example.com/hook", "secret")
+	require.NoError(t, err)
+
+	srcSubs := newSubscriptions()
+	sub := subscription.Subscription{
+		ID:     "s",
+		OrgID:  "acme",
+		UserID: "user",
+		Name:   "holidays",
+		Source: "https://example.com/h.ics",
+	}
+	require.NoError(t, srcSubs.ImportSubscription(ctx, sub))
+
+	srcAvailability := availability.NewStore()
+	settings, err := srcAvailability.Set(ctx, availability.Settings{
+		OrgID:       "acme",
+		UserID:      "user",
+		TimeZone:    "Europe/Moscow",
+		Hours:       map[time.Weekday][]availability.Period{time.Monday: {{Start: 9 * time.Hour, End: 18 * time.Hour}}},
+		OutOfOffice: []availability.Absence{{Start: start, End: start.Add(24 * time.Hour), Reason: "vacation"}},
+		Policy:      availability.PolicyDecline,
+	})
+	require.NoError(t, err)
+
+	srcDigests := digest.NewStore()
+	digestSettings, err := srcDigests.Set(ctx, digest.Settings{
+		OrgID:   "acme",
+		UserID:  "user",
+		Period:  digest.PeriodWeekly,
+		Weekday: time.Sunday,
+		At:      20 * time.Hour,
+		Format:  digest.FormatHTML,
+	})
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	stats, err := Dump(ctx, &archive, Sources{
+		Events:        src,
+		Webhooks:      srcHooks,
+		Subscriptions: srcSubs,
+		Availability:  srcAvailability,
+		Digests:       srcDigests,
+	})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Events: 2, Revisions: 2, Webhooks: 1, Subscriptions: 1, Availability: 1, Digests: 1}, stats)
+
+	dst, dstHooks, dstSubs, dstAvailability, dstDigests := memorystorage.New(), newDispatcher(), newSubscriptions(),
+		availability.NewStore(), digest.NewStore()
+	sinks := Sinks{
+		Events:        dst,
+		Webhooks:      dstHooks,
+		Subscriptions: dstSubs,
+		Availability:  dstAvailability,
+		Digests:       dstDigests,
+	}
+	loaded, err := Load(ctx, bytes.NewReader(archive.Bytes()), sinks)
+	require.NoError(t, err)
+	require.Equal(t, stats, loaded)
+
+	for _, want := range []storage.Event{live, trashed} {
+		got, err := dst.GetEvent(ctx, "acme", want.ID)
+		require.NoError(t, err)
+		require.True(t, want.StartAt.Equal(got.StartAt))
+		got.StartAt, got.EndAt = want.StartAt, want.EndAt
+		require.True(t, want.DeletedAt.Equal(got.DeletedAt))
+		got.DeletedAt = want.DeletedAt
+		require.Equal(t, want, got)
+	}
+
+	srcRevisions, err := src.ListRevisions(ctx, "acme", "1")
+	require.NoError(t, err)
+	dstRevisions, err := dst.ListRevisions(ctx, "acme", "1")
+	require.NoError(t, err)
+	require.Len(t, dstRevisions, 2)
+	require.Equal(t, srcRevisions[1].Changes, dstRevisions[1].Changes)
+	require.Equal(t, srcRevisions[1].Version, dstRevisions[1].Version)
+
+	hooks, err := dstHooks.List(ctx, "acme", "user")
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	require.Equal(t, hook.ID, hooks[0].ID)
+	require.Equal(t, "secret", hooks[0].Secret)
+
+	subs, err := dstSubs.List(ctx, "acme", "user")
+	require.NoError(t, err)
+	require.Equal(t, []subscription.Subscription{sub}, subs)
+
+	restored, err := dstAvailability.Get(ctx, "acme", "user")
+	require.NoError(t, err)
+	require.True(t, settings.OutOfOffice[0].Start.Equal(restored.OutOfOffice[0].Start))
+	restored.OutOfOffice[0].Start = settings.OutOfOffice[0].Start
+	restored.OutOfOffice[0].End = settings.OutOfOffice[0].End
+	require.Equal(t, settings, restored)
+
+	restoredDigest, err := dstDigests.Get(ctx, "acme", "user")
+	require.NoError(t, err)
+	require.Equal(t, digestSettings, restoredDigest)
+
+	_, err = Load(ctx, bytes.NewReader(archive.Bytes()), sinks)
+	require.ErrorIs(t, err, storage.ErrEventExists, "restore does not overwrite")
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	var archive bytes.Buffer
+	_, err := Dump(ctx, &archive, Sources{
+		Events:        memorystorage.New(),
+		Webhooks:      newDispatcher(),
+		Subscriptions: newSubscriptions(),
+		Availability:  availability.NewStore(),
+		Digests:       digest.NewStore(),
+	})
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSpace(archive.String()), "\n")
+
+	for name, tc := range map[string]struct {
+		archive string
+		err     error
+	}{
+		"empty":     {"", ErrInvalidArchive},
+		"no header": {`{"kind":"event","event":{"id":"1"}}` + "\n", ErrInvalidArchive},
+		"future":    {`{"kind":"header","format":"calendar-backup","version":99}` + "\n", ErrUnsupportedVersion},
+		"truncated": {lines[0], ErrInvalidArchive},
+		"version 1": {
+			`{"kind":"header","format":"calendar-backup","version":1}` + "\n" +
+				`{"kind":"end","stats":{"events":0,"revisions":0,"webhooks":0}}` + "\n",
+			nil,
+		},
+		"miscounted": {
+			lines[0] + `{"kind":"end","stats":{"events":1,"revisions":0,"webhooks":0}}` + "\n",
+			ErrInvalidArchive,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(ctx, strings.NewReader(tc.archive), newSinks())
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
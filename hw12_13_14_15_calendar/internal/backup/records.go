@@ -0,0 +1,324 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+// Records mirror the storage types with explicit JSON names, so that a
+// renamed Go field does not change the archive format. Durations are
+// nanoseconds.
+
+type eventRecord struct {
+	ID           string           `json:"id"`
+	OrgID        string           `json:"orgId,omitempty"`
+	UserID       string           `json:"userId"`
+	Title        string           `json:"title"`
+	StartAt      time.Time        `json:"startAt"`
+	EndAt        time.Time        `json:"endAt"`
+	Description  string           `json:"description,omitempty"`
+	NotifyBefore time.Duration    `json:"notifyBefore,omitempty"`
+	Reminders    []reminderRecord `json:"reminders,omitempty"`
+	// Attachments hold metadata only, blobs are not archived.
+	Attachments []attachmentRecord `json:"attachments,omitempty"`
+	DeletedAt   *time.Time         `json:"deletedAt,omitempty"`
+}
+
+type reminderRecord struct {
+	Before  time.Duration `json:"before"`
+	Channel string        `json:"channel"`
+}
+
+type attachmentRecord struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type revisionRecord struct {
+	EventID string              `json:"eventId"`
+	OrgID   string              `json:"orgId,omitempty"`
+	Version int                 `json:"version"`
+	Action  string              `json:"action"`
+	Actor   string              `json:"actor"`
+	At      time.Time           `json:"at"`
+	Before  *eventRecord        `json:"before,omitempty"`
+	After   *eventRecord        `json:"after,omitempty"`
+	Changes []fieldChangeRecord `json:"changes,omitempty"`
+}
+
+type fieldChangeRecord struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type webhookRecord struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId,omitempty"`
+	UserID    string    `json:"userId"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newEventRecord(event storage.Event) *eventRecord {
+	r := &eventRecord{
+		ID:           event.ID,
+		OrgID:        event.OrgID,
+		UserID:       event.UserID,
+		Title:        event.Title,
+		StartAt:      event.StartAt,
+		EndAt:        event.EndAt,
+		Description:  event.Description,
+		NotifyBefore: event.NotifyBefore,
+	}
+	for _, reminder := range event.Reminders {
+		r.Reminders = append(r.Reminders, reminderRecord{Before: reminder.Before, Channel: reminder.Channel})
+	}
+	for _, a := range event.Attachments {
+		r.Attachments = append(r.Attachments, attachmentRecord(a))
+	}
+	if event.Deleted() {
+		r.DeletedAt = &event.DeletedAt
+	}
+	return r
+}
+
+func (r *eventRecord) toEvent() storage.Event {
+	event := storage.Event{
+		ID:           r.ID,
+		OrgID:        r.OrgID,
+		UserID:       r.UserID,
+		Title:        r.Title,
+		StartAt:      r.StartAt,
+		EndAt:        r.EndAt,
+		Description:  r.Description,
+		NotifyBefore: r.NotifyBefore,
+	}
+	for _, reminder := range r.Reminders {
+		event.Reminders = append(event.Reminders, storage.Reminder{Before: reminder.Before, Channel: reminder.Channel})
+	}
+	for _, a := range r.Attachments {
+		event.Attachments = append(event.Attachments, storage.Attachment(a))
+	}
+	if r.DeletedAt != nil {
+		event.DeletedAt = *r.DeletedAt
+	}
+	return event
+}
+
+func newRevisionRecord(revision storage.Revision) *revisionRecord {
+	r := &revisionRecord{
+		EventID: revision.EventID,
+		OrgID:   revision.OrgID,
+		Version: revision.Version,
+		Action:  string(revision.Action),
+		Actor:   revision.Actor,
+		At:      revision.At,
+	}
+	if revision.Before != nil {
+		r.Before = newEventRecord(*revision.Before)
+	}
+	if revision.After != nil {
+		r.After = newEventRecord(*revision.After)
+	}
+	for _, change := range revision.Changes {
+		r.Changes = append(r.Changes, fieldChangeRecord{
+			Field: change.Field, Before: change.Before, After: change.After,
+		})
+	}
+	return r
+}
+
+func (r *revisionRecord) toRevision() storage.Revision {
+	revision := storage.Revision{
+		EventID: r.EventID,
+		OrgID:   r.OrgID,
+		Version: r.Version,
+		Action:  storage.RevisionAction(r.Action),
+		Actor:   r.Actor,
+		At:      r.At,
+	}
+	if r.Before != nil {
+		before := r.Before.toEvent()
+		revision.Before = &before
+	}
+	if r.After != nil {
+		after := r.After.toEvent()
+		revision.After = &after
+	}
+	for _, change := range r.Changes {
+		revision.Changes = append(revision.Changes,
+			storage.FieldChange{Field: change.Field, Before: change.Before, After: change.After})
+	}
+	return revision
+}
+
+func newWebhookRecord(hook webhook.Webhook) *webhookRecord {
+	return &webhookRecord{
+		ID:        hook.ID,
+		OrgID:     hook.OrgID,
+		UserID:    hook.UserID,
+		URL:       hook.URL,
+		Secret:    hook.Secret,
+		CreatedAt: hook.CreatedAt,
+	}
+}
+
+func (r *webhookRecord) toWebhook() webhook.Webhook {
+	return webhook.Webhook{
+		ID:        r.ID,
+		OrgID:     r.OrgID,
+		UserID:    r.UserID,
+		URL:       r.URL,
+		Secret:    r.Secret,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+type subscriptionRecord struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId,omitempty"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newSubscriptionRecord(sub subscription.Subscription) *subscriptionRecord {
+	return &subscriptionRecord{
+		ID:        sub.ID,
+		OrgID:     sub.OrgID,
+		UserID:    sub.UserID,
+		Name:      sub.Name,
+		Source:    sub.Source,
+		CreatedAt: sub.CreatedAt,
+	}
+}
+
+func (r *subscriptionRecord) toSubscription() subscription.Subscription {
+	return subscription.Subscription{
+		ID:        r.ID,
+		OrgID:     r.OrgID,
+		UserID:    r.UserID,
+		Name:      r.Name,
+		Source:    r.Source,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+type availabilityRecord struct {
+	OrgID    string `json:"orgId,omitempty"`
+	UserID   string `json:"userId"`
+	TimeZone string `json:"timeZone,omitempty"`
+	// Hours is keyed by weekday number from Sunday as 0. Null keeps every
+	// hour a working one, so it is not omitted.
+	Hours       map[time.Weekday][]periodRecord `json:"hours"`
+	OutOfOffice []absenceRecord                 `json:"outOfOffice,omitempty"`
+	Policy      string                          `json:"policy,omitempty"`
+	Strict      bool                            `json:"strict,omitempty"`
+}
+
+type periodRecord struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+}
+
+type absenceRecord struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func newAvailabilityRecord(settings availability.Settings) *availabilityRecord {
+	r := &availabilityRecord{
+		OrgID:    settings.OrgID,
+		UserID:   settings.UserID,
+		TimeZone: settings.TimeZone,
+		Policy:   string(settings.Policy),
+		Strict:   settings.Strict,
+	}
+	if settings.Hours != nil {
+		r.Hours = make(map[time.Weekday][]periodRecord, len(settings.Hours))
+		for weekday, periods := range settings.Hours {
+			r.Hours[weekday] = make([]periodRecord, 0, len(periods))
+			for _, p := range periods {
+				r.Hours[weekday] = append(r.Hours[weekday], periodRecord{Start: p.Start, End: p.End})
+			}
+		}
+	}
+	for _, a := range settings.OutOfOffice {
+		r.OutOfOffice = append(r.OutOfOffice, absenceRecord{Start: a.Start, End: a.End, Reason: a.Reason})
+	}
+	return r
+}
+
+func (r *availabilityRecord) toSettings() availability.Settings {
+	settings := availability.Settings{
+		OrgID:    r.OrgID,
+		UserID:   r.UserID,
+		TimeZone: r.TimeZone,
+		Policy:   availability.Policy(r.Policy),
+		Strict:   r.Strict,
+	}
+	if r.Hours != nil {
+		settings.Hours = make(map[time.Weekday][]availability.Period, len(r.Hours))
+		for weekday, periods := range r.Hours {
+			hours := make([]availability.Period, 0, len(periods))
+			for _, p := range periods {
+				hours = append(hours, availability.Period{Start: p.Start, End: p.End})
+			}
+			settings.Hours[weekday] = hours
+		}
+	}
+	for _, a := range r.OutOfOffice {
+		settings.OutOfOffice = append(settings.OutOfOffice,
+			availability.Absence{Start: a.Start, End: a.End, Reason: a.Reason})
+	}
+	return settings
+}
+
+type digestRecord struct {
+	OrgID    string        `json:"orgId,omitempty"`
+	UserID   string        `json:"userId"`
+	Period   string        `json:"period"`
+	At       time.Duration `json:"at"`
+	Weekday  time.Weekday  `json:"weekday"`
+	TimeZone string        `json:"timeZone,omitempty"`
+	Format   string        `json:"format"`
+	Channel  string        `json:"channel"`
+}
+
+func newDigestRecord(settings digest.Settings) *digestRecord {
+	return &digestRecord{
+		OrgID:    settings.OrgID,
+		UserID:   settings.UserID,
+		Period:   string(settings.Period),
+		At:       settings.At,
+		Weekday:  settings.Weekday,
+		TimeZone: settings.TimeZone,
+		Format:   string(settings.Format),
+		Channel:  settings.Channel,
+	}
+}
+
+func (r *digestRecord) toSettings() digest.Settings {
+	return digest.Settings{
+		OrgID:    r.OrgID,
+		UserID:   r.UserID,
+		Period:   digest.Period(r.Period),
+		At:       r.At,
+		Weekday:  r.Weekday,
+		TimeZone: r.TimeZone,
+		Format:   digest.Format(r.Format),
+		Channel:  r.Channel,
+	}
+}
@@ -0,0 +1,267 @@
+// Package cache keeps recent event listings in front of the storage.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event) error
+	UpdateEvent(ctx context.Context, id string, event storage.Event) error
+	DeleteEvent(ctx context.Context, orgID, id string) error
+	GetEvent(ctx context.Context, orgID, id string) (storage.Event, error)
+	ListEvents(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error)
+	CountEvents(ctx context.Context, orgID string) (int, error)
+	ListStarting(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+	ListDeleted(ctx context.Context, orgID, userID string) ([]storage.Event, error)
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (storage.PurgeResult, error)
+	AppendRevision(ctx context.Context, revision storage.Revision) (storage.Revision, error)
+	ListRevisions(ctx context.Context, orgID, eventID string) ([]storage.Revision, error)
+}
+
+type Config struct {
+	// Size is the most listings kept, zero disables the cache.
+	Size int
+	// TTL bounds the age of a served listing, zero keeps it until evicted.
+	// It is what limits staleness when other processes write the storage.
+	TTL time.Duration
+}
+
+type owner struct {
+	orgID, userID string
+}
+
+type key struct {
+	owner
+	from, to int64
+}
+
+type entry struct {
+	key    key
+	from   time.Time
+	to     time.Time
+	events []storage.Event
+	stored time.Time
+}
+
+// CachedStorage serves ListEvents from an LRU cache. A write drops the
+// listings of the event owner whose range it overlaps, before or after
+// the change, other listings stay cached.
+type CachedStorage struct {
+	next Storage
+	now  func() time.Time
+
+	mu      sync.Mutex
+	config  Config
+	lru     *list.List
+	entries map[key]*list.Element
+	byOwner map[owner]map[*list.Element]struct{}
+	// writes counts invalidations, a listing read across one is not cached.
+	writes uint64
+}
+
+func NewCachedStorage(next Storage, config Config) *CachedStorage {
+	return &CachedStorage{
+		next:    next,
+		now:     time.Now,
+		config:  config,
+		lru:     list.New(),
+		entries: make(map[key]*list.Element),
+		byOwner: make(map[owner]map[*list.Element]struct{}),
+	}
+}
+
+// Reconfigure applies the new limits to the cached listings too.
+func (s *CachedStorage) Reconfigure(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+	s.trim()
+}
+
+func (s *CachedStorage) ListEvents(
+	ctx context.Context, orgID, userID string, from, to time.Time,
+) ([]storage.Event, error) {
+	k := key{owner: owner{orgID: orgID, userID: userID}, from: from.UnixNano(), to: to.UnixNano()}
+	s.mu.Lock()
+	if s.config.Size <= 0 {
+		s.mu.Unlock()
+		return s.next.ListEvents(ctx, orgID, userID, from, to)
+	}
+	if elem, ok := s.entries[k]; ok {
+		e := elem.Value.(*entry)
+		if s.config.TTL <= 0 || s.now().Sub(e.stored) < s.config.TTL {
+			s.lru.MoveToFront(elem)
+			events := slices.Clone(e.events)
+			s.mu.Unlock()
+			metrics.CacheRequests.WithLabelValues("hit").Inc()
+			return events, nil
+		}
+		s.remove(elem, "expired")
+	}
+	writes := s.writes
+	s.mu.Unlock()
+	metrics.CacheRequests.WithLabelValues("miss").Inc()
+
+	events, err := s.next.ListEvents(ctx, orgID, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writes == writes && s.config.Size > 0 {
+		s.add(&entry{
+			key:    k,
+			from:   from,
+			to:     to,
+			events: slices.Clone(events),
+			stored: s.now(),
+		})
+	}
+	return events, nil
+}
+
+func (s *CachedStorage) CreateEvent(ctx context.Context, event storage.Event) error {
+	if err := s.next.CreateEvent(ctx, event); err != nil {
+		return err
+	}
+	s.invalidate(event)
+	return nil
+}
+
+func (s *CachedStorage) UpdateEvent(ctx context.Context, id string, event storage.Event) error {
+	before, getErr := s.next.GetEvent(ctx, event.OrgID, id)
+	if err := s.next.UpdateEvent(ctx, id, event); err != nil {
+		return err
+	}
+	if getErr != nil {
+		s.invalidateOwner(owner{orgID: event.OrgID, userID: event.UserID})
+	} else {
+		s.invalidate(before)
+	}
+	s.invalidate(event)
+	return nil
+}
+
+func (s *CachedStorage) DeleteEvent(ctx context.Context, orgID, id string) error {
+	before, getErr := s.next.GetEvent(ctx, orgID, id)
+	if err := s.next.DeleteEvent(ctx, orgID, id); err != nil {
+		return err
+	}
+	if getErr != nil {
+		s.clear()
+	} else {
+		s.invalidate(before)
+	}
+	return nil
+}
+
+// PurgeEvents drops the whole cache when expired events were removed, the
+// result does not say which listings had them.
+func (s *CachedStorage) PurgeEvents(
+	ctx context.Context, deletedBefore, endedBefore time.Time,
+) (storage.PurgeResult, error) {
+	result, err := s.next.PurgeEvents(ctx, deletedBefore, endedBefore)
+	if err == nil && result.Expired > 0 {
+		s.clear()
+	}
+	return result, err
+}
+
+func (s *CachedStorage) GetEvent(ctx context.Context, orgID, id string) (storage.Event, error) {
+	return s.next.GetEvent(ctx, orgID, id)
+}
+
+func (s *CachedStorage) CountEvents(ctx context.Context, orgID string) (int, error) {
+	return s.next.CountEvents(ctx, orgID)
+}
+
+func (s *CachedStorage) ListStarting(ctx context.Context, from, to time.Time) ([]storage.Event, error) {
+	return s.next.ListStarting(ctx, from, to)
+}
+
+func (s *CachedStorage) ListDeleted(ctx context.Context, orgID, userID string) ([]storage.Event, error) {
+	return s.next.ListDeleted(ctx, orgID, userID)
+}
+
+func (s *CachedStorage) AppendRevision(ctx context.Context, revision storage.Revision) (storage.Revision, error) {
+	return s.next.AppendRevision(ctx, revision)
+}
+
+func (s *CachedStorage) ListRevisions(ctx context.Context, orgID, eventID string) ([]storage.Revision, error) {
+	return s.next.ListRevisions(ctx, orgID, eventID)
+}
+
+// invalidate drops listings of the event owner intersecting the event,
+// the same condition ListEvents selects events by.
+func (s *CachedStorage) invalidate(event storage.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	for elem := range s.byOwner[owner{orgID: event.OrgID, userID: event.UserID}] {
+		e := elem.Value.(*entry)
+		if event.StartAt.Before(e.to) && event.EndAt.After(e.from) {
+			s.remove(elem, "invalidated")
+		}
+	}
+}
+
+func (s *CachedStorage) invalidateOwner(o owner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	for elem := range s.byOwner[o] {
+		s.remove(elem, "invalidated")
+	}
+}
+
+func (s *CachedStorage) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	for s.lru.Len() > 0 {
+		s.remove(s.lru.Back(), "invalidated")
+	}
+}
+
+func (s *CachedStorage) add(e *entry) {
+	if elem, ok := s.entries[e.key]; ok {
+		s.remove(elem, "replaced")
+	}
+
+	elem := s.lru.PushFront(e)
+	s.entries[e.key] = elem
+	if s.byOwner[e.key.owner] == nil {
+		s.byOwner[e.key.owner] = make(map[*list.Element]struct{})
+	}
+	s.byOwner[e.key.owner][elem] = struct{}{}
+	s.trim()
+}
+
+// trim evicts the least recently used listings above the size.
+func (s *CachedStorage) trim() {
+	for s.lru.Len() > max(s.config.Size, 0) {
+		s.remove(s.lru.Back(), "capacity")
+	}
+}
+
+func (s *CachedStorage) remove(elem *list.Element, reason string) {
+	e := s.lru.Remove(elem).(*entry)
+	delete(s.entries, e.key)
+	delete(s.byOwner[e.key.owner], elem)
+	if len(s.byOwner[e.key.owner]) == 0 {
+		delete(s.byOwner, e.key.owner)
+	}
+	metrics.CacheEvictions.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage counts listings that reach the storage.
+type countingStorage struct {
+	*memorystorage.Storage
+	lists int
+}
+
+func (s *countingStorage) ListEvents(
+	ctx context.Context, orgID, userID string, from, to time.Time,
+) ([]storage.Event, error) {
+	s.lists++
+	return s.Storage.ListEvents(ctx, orgID, userID, from, to)
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	event := func(id, userID string, start time.Time) storage.Event {
+		return storage.Event{ID: id, Title: id, UserID: userID, StartAt: start, EndAt: start.Add(time.Hour)}
+	}
+	setup := func(t *testing.T, config Config) (*CachedStorage, *countingStorage) {
+		t.Helper()
+		next := &countingStorage{Storage: memorystorage.New()}
+		require.NoError(t, next.CreateEvent(ctx, event("standup", "alice", day.Add(9*time.Hour))))
+		require.NoError(t, next.CreateEvent(ctx, event("review", "alice", day.AddDate(0, 0, 1).Add(15*time.Hour))))
+		return NewCachedStorage(next, config), next
+	}
+	list := func(t *testing.T, s *CachedStorage, userID string, from time.Time, days int) []storage.Event {
+		t.Helper()
+		events, err := s.ListEvents(ctx, "", userID, from, from.AddDate(0, 0, days))
+		require.NoError(t, err)
+		return events
+	}
+
+	t.Run("read through", func(t *testing.T) {
+		hits := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("hit"))
+		misses := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("miss"))
+		s, next := setup(t, Config{Size: 10})
+
+		require.Len(t, list(t, s, "alice", day, 7), 2)
+		events := list(t, s, "alice", day, 7)
+		require.Len(t, events, 2)
+		require.Equal(t, 1, next.lists)
+		require.Equal(t, hits+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("hit")))
+		require.Equal(t, misses+1, testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("miss")))
+
+		events[0].Title = "changed"
+		_ = append(events[:1], storage.Event{ID: "appended"})
+		require.Equal(t, "standup", list(t, s, "alice", day, 7)[0].Title, "callers get a copy")
+		require.Len(t, list(t, s, "alice", day, 7), 2)
+	})
+
+	t.Run("writes invalidate overlapping ranges", func(t *testing.T) {
+		s, next := setup(t, Config{Size: 10})
+		list(t, s, "alice", day, 1)
+		list(t, s, "alice", day.AddDate(0, 0, 1), 1)
+		list(t, s, "bob", day, 1)
+		require.Equal(t, 3, next.lists)
+
+		lunch := event("lunch", "alice", day.Add(12*time.Hour))
+		require.NoError(t, s.CreateEvent(ctx, lunch))
+		require.Len(t, list(t, s, "alice", day, 1), 2)
+		list(t, s, "alice", day.AddDate(0, 0, 1), 1)
+		list(t, s, "bob", day, 1)
+		require.Equal(t, 4, next.lists, "only the listing of the event day is read again")
+
+		// Moving the event changes both the old and the new day.
+		lunch.StartAt, lunch.EndAt = lunch.StartAt.AddDate(0, 0, 1), lunch.EndAt.AddDate(0, 0, 1)
+		require.NoError(t, s.UpdateEvent(ctx, "lunch", lunch))
+		require.Len(t, list(t, s, "alice", day, 1), 1)
+		require.Len(t, list(t, s, "alice", day.AddDate(0, 0, 1), 1), 2)
+		require.Equal(t, 6, next.lists)
+
+		require.NoError(t, s.DeleteEvent(ctx, "", "lunch"))
+		require.Len(t, list(t, s, "alice", day, 1), 1)
+		require.Len(t, list(t, s, "alice", day.AddDate(0, 0, 1), 1), 1)
+		require.Equal(t, 7, next.lists)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		now := day
+		s, next := setup(t, Config{Size: 10, TTL: time.Minute})
+		s.now = func() time.Time { return now }
+
+		list(t, s, "alice", day, 7)
+		now = now.Add(59 * time.Second)
+		list(t, s, "alice", day, 7)
+		require.Equal(t, 1, next.lists)
+		now = now.Add(time.Second)
+		list(t, s, "alice", day, 7)
+		require.Equal(t, 2, next.lists)
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		s, next := setup(t, Config{Size: 2})
+		list(t, s, "alice", day, 1)
+		list(t, s, "alice", day, 2)
+		list(t, s, "alice", day, 1)
+		list(t, s, "alice", day, 3)
+		require.Equal(t, 3, next.lists)
+
+		list(t, s, "alice", day, 1)
+		require.Equal(t, 3, next.lists, "recently used listing is kept")
+		list(t, s, "alice", day, 2)
+		require.Equal(t, 4, next.lists, "least recently used listing is evicted")
+
+		s.Reconfigure(Config{})
+		list(t, s, "alice", day, 1)
+		list(t, s, "alice", day, 1)
+		require.Equal(t, 6, next.lists, "zero size disables the cache")
+	})
+}
@@ -0,0 +1,121 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const defaultInterval = time.Hour
+
+type Config struct {
+	Interval time.Duration
+	// TrashRetention is how long deleted events stay restorable.
+	TrashRetention time.Duration
+	// EventRetention removes events that ended that long ago, zero keeps them forever.
+	EventRetention time.Duration
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type Purger interface {
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (storage.PurgeResult, error)
+}
+
+// Blobs deletes attachment contents.
+type Blobs interface {
+	Delete(ctx context.Context, id string) error
+}
+
+// Cleaner periodically purges the trash and events past retention together
+// with their attachments.
+type Cleaner struct {
+	logger Logger
+	purger Purger
+	blobs  Blobs
+
+	mu     sync.RWMutex
+	config Config
+	now    func() time.Time
+}
+
+func New(logger Logger, purger Purger, blobs Blobs, config Config) *Cleaner {
+	return &Cleaner{
+		logger: logger,
+		purger: purger,
+		blobs:  blobs,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Reconfigure applies new settings, a new interval counts from the next run.
+func (c *Cleaner) Reconfigure(config Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
+
+func (c *Cleaner) currentConfig() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// Run purges on start and then every interval until ctx is done.
+func (c *Cleaner) Run(ctx context.Context) {
+	for {
+		if _, err := c.Purge(ctx); err != nil {
+			c.logger.Error("cleanup failed: " + err.Error())
+		}
+
+		interval := c.currentConfig().Interval
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Cleaner) Purge(ctx context.Context) (storage.PurgeResult, error) {
+	config := c.currentConfig()
+	now := c.now()
+
+	var endedBefore time.Time
+	if config.EventRetention > 0 {
+		endedBefore = now.Add(-config.EventRetention)
+	}
+
+	result, err := c.purger.PurgeEvents(ctx, now.Add(-config.TrashRetention), endedBefore)
+	if err != nil {
+		return storage.PurgeResult{}, err
+	}
+
+	metrics.PurgedEvents.WithLabelValues("trashed").Add(float64(result.Trashed))
+	metrics.PurgedEvents.WithLabelValues("expired").Add(float64(result.Expired))
+
+	// The events are gone already, a blob failing to delete stays orphaned
+	// rather than failing the purge.
+	for _, id := range result.Attachments {
+		if err := c.blobs.Delete(ctx, id); err != nil {
+			c.logger.Error(fmt.Sprintf("cleanup failed to delete attachment %s: %s", id, err))
+		}
+	}
+	c.logger.Info(fmt.Sprintf("cleanup purged %d trashed and %d expired events, %d attachments",
+		result.Trashed, result.Expired, len(result.Attachments)))
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package cleanup
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleaner(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s := memorystorage.New()
+	events := []storage.Event{
+		{ID: "live", UserID: "u", StartAt: now.Add(-time.Hour), EndAt: now},
+		{ID: "old", UserID: "u", StartAt: now.AddDate(-2, 0, 0), EndAt: now.AddDate(-2, 0, 0).Add(time.Hour)},
+		{ID: "fresh-trash", UserID: "u", StartAt: now, EndAt: now.Add(time.Hour), DeletedAt: now.AddDate(0, 0, -1)},
+		{ID: "old-trash", UserID: "u", StartAt: now, EndAt: now.Add(time.Hour), DeletedAt: now.AddDate(0, 0, -31)},
+	}
+	blobs := attachment.NewMemory()
+	for i := range events {
+		id := events[i].ID + "-agenda"
+		require.NoError(t, blobs.Put(ctx, id, strings.NewReader("agenda")))
+		events[i].Attachments = []storage.Attachment{{ID: id, Name: "agenda.txt"}}
+		require.NoError(t, s.CreateEvent(ctx, events[i]))
+	}
+	// A detached attachment is purged through the event history.
+	require.NoError(t, blobs.Put(ctx, "old-draft", strings.NewReader("draft")))
+	draft := events[1]
+	draft.Attachments = []storage.Attachment{{ID: "old-draft", Name: "draft.txt"}}
+	_, err := s.AppendRevision(ctx, storage.Revision{EventID: "old", Action: storage.RevisionCreated, After: &draft})
+	require.NoError(t, err)
+
+	c := New(logger.NewWithWriter("ERROR", io.Discard), s, blobs, Config{
+		TrashRetention: 30 * 24 * time.Hour,
+		EventRetention: 365 * 24 * time.Hour,
+	})
+	c.now = func() time.Time { return now }
+
+	result, err := c.Purge(ctx)
+	require.NoError(t, err)
+	require.Equal(t, storage.PurgeResult{
+		Trashed:     1,
+		Expired:     1,
+		Attachments: []string{"old-agenda", "old-draft", "old-trash-agenda"},
+	}, result)
+	for id, exists := range map[string]bool{"live-agenda": true, "old-agenda": false, "old-draft": false} {
+		_, err := blobs.Get(ctx, id)
+		if exists {
+			require.NoError(t, err, id)
+		} else {
+			require.ErrorIs(t, err, attachment.ErrBlobNotFound, id)
+		}
+	}
+
+	for id, exists := range map[string]bool{"live": true, "fresh-trash": true, "old": false, "old-trash": false} {
+		_, err := s.GetEvent(ctx, "", id)
+		if exists {
+			require.NoError(t, err, id)
+		} else {
+			require.ErrorIs(t, err, storage.ErrEventNotFound, id)
+		}
+	}
+
+	c.Reconfigure(Config{TrashRetention: 0})
+	result, err = c.Purge(ctx)
+	require.NoError(t, err)
+	require.Equal(t, storage.PurgeResult{Trashed: 1, Attachments: []string{"fresh-trash-agenda"}}, result,
+		"zero event retention keeps old events")
+}
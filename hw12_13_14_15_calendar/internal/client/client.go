@@ -0,0 +1,308 @@
+// Package client talks to the calendar HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type Config struct {
+	// BaseURL of the API, e.g. http://localhost:8888.
+	BaseURL string
+	// UserID is sent in X-User-ID for servers in the header auth mode.
+	UserID string
+	// OrgID is sent in X-Org-ID for servers in the header auth mode.
+	OrgID string
+	// Token is sent as a Bearer token for servers in the jwt auth mode.
+	Token   string
+	Timeout time.Duration
+}
+
+type Event struct {
+	ID           string
+	Title        string
+	StartAt      time.Time
+	EndAt        time.Time
+	Description  string
+	UserID       string
+	NotifyBefore time.Duration
+	Reminders    []Reminder
+}
+
+type Reminder struct {
+	Before  time.Duration
+	Channel string
+}
+
+// APIError is a non-2xx answer of the server.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 answer.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+func New(config Config) *Client {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &Client{
+		config: config,
+		http:   &http.Client{Timeout: config.Timeout},
+	}
+}
+
+type eventDTO struct {
+	ID           string        `json:"id,omitempty"`
+	Title        string        `json:"title"`
+	StartAt      time.Time     `json:"startAt"`
+	EndAt        time.Time     `json:"endAt"`
+	Description  string        `json:"description,omitempty"`
+	UserID       string        `json:"userId,omitempty"`
+	NotifyBefore string        `json:"notifyBefore,omitempty"`
+	Reminders    []reminderDTO `json:"reminders,omitempty"`
+}
+
+type reminderDTO struct {
+	Before  string `json:"before"`
+	Channel string `json:"channel"`
+}
+
+func toDTO(event Event) eventDTO {
+	dto := eventDTO{
+		Title:       event.Title,
+		StartAt:     event.StartAt,
+		EndAt:       event.EndAt,
+		Description: event.Description,
+	}
+	if event.NotifyBefore > 0 {
+		dto.NotifyBefore = event.NotifyBefore.String()
+	}
+	for _, reminder := range event.Reminders {
+		dto.Reminders = append(dto.Reminders, reminderDTO{Before: reminder.Before.String(), Channel: reminder.Channel})
+	}
+	return dto
+}
+
+func (dto eventDTO) toEvent() (Event, error) {
+	event := Event{
+		ID:          dto.ID,
+		Title:       dto.Title,
+		StartAt:     dto.StartAt,
+		EndAt:       dto.EndAt,
+		Description: dto.Description,
+		UserID:      dto.UserID,
+	}
+	if dto.NotifyBefore != "" {
+		d, err := time.ParseDuration(dto.NotifyBefore)
+		if err != nil {
+			return Event{}, fmt.Errorf("event %s: %w", dto.ID, err)
+		}
+		event.NotifyBefore = d
+	}
+	for _, reminder := range dto.Reminders {
+		d, err := time.ParseDuration(reminder.Before)
+		if err != nil {
+			return Event{}, fmt.Errorf("event %s: reminder: %w", dto.ID, err)
+		}
+		event.Reminders = append(event.Reminders, Reminder{Before: d, Channel: reminder.Channel})
+	}
+	return event, nil
+}
+
+func (c *Client) Create(ctx context.Context, event Event) (Event, error) {
+	var created eventDTO
+	if err := c.do(ctx, http.MethodPost, "/events", toDTO(event), &created); err != nil {
+		return Event{}, err
+	}
+	return created.toEvent()
+}
+
+func (c *Client) Update(ctx context.Context, id string, event Event) (Event, error) {
+	var updated eventDTO
+	if err := c.do(ctx, http.MethodPut, "/events/"+url.PathEscape(id), toDTO(event), &updated); err != nil {
+		return Event{}, err
+	}
+	return updated.toEvent()
+}
+
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/events/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) Get(ctx context.Context, id string) (Event, error) {
+	var event eventDTO
+	if err := c.do(ctx, http.MethodGet, "/events/"+url.PathEscape(id), nil, &event); err != nil {
+		return Event{}, err
+	}
+	return event.toEvent()
+}
+
+// Operation is one item of a batch, Op is create, update or delete.
+type Operation struct {
+	Op    string
+	ID    string
+	Event Event
+}
+
+// BatchResult is the outcome of the operation with the same index, Err is an *APIError.
+type BatchResult struct {
+	Event Event
+	Err   error
+}
+
+type batchOperationDTO struct {
+	Op    string    `json:"op"`
+	ID    string    `json:"id,omitempty"`
+	Event *eventDTO `json:"event,omitempty"`
+}
+
+type batchItemDTO struct {
+	Status int       `json:"status"`
+	Event  *eventDTO `json:"event"`
+	Error  string    `json:"error"`
+}
+
+// Batch applies the operations in one request. An atomic batch applies all
+// of them or none, otherwise each operation succeeds or fails on its own.
+func (c *Client) Batch(ctx context.Context, ops []Operation, atomic bool) ([]BatchResult, error) {
+	req := struct {
+		Mode       string              `json:"mode"`
+		Operations []batchOperationDTO `json:"operations"`
+	}{Mode: "best_effort"}
+	if atomic {
+		req.Mode = "atomic"
+	}
+	for _, op := range ops {
+		dto := batchOperationDTO{Op: op.Op, ID: op.ID}
+		if op.Op != "delete" {
+			event := toDTO(op.Event)
+			dto.Event = &event
+		}
+		req.Operations = append(req.Operations, dto)
+	}
+
+	var resp struct {
+		Results []batchItemDTO `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/events:batch", req, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, 0, len(resp.Results))
+	for _, item := range resp.Results {
+		var result BatchResult
+		switch {
+		case item.Status >= http.StatusBadRequest:
+			result.Err = &APIError{Status: item.Status, Message: item.Error}
+		case item.Event != nil:
+			event, err := item.Event.toEvent()
+			if err != nil {
+				return nil, err
+			}
+			result.Event = event
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (c *Client) Day(ctx context.Context, date time.Time) ([]Event, error) {
+	return c.list(ctx, "day", date)
+}
+
+func (c *Client) Week(ctx context.Context, weekStart time.Time) ([]Event, error) {
+	return c.list(ctx, "week", weekStart)
+}
+
+func (c *Client) Month(ctx context.Context, monthStart time.Time) ([]Event, error) {
+	return c.list(ctx, "month", monthStart)
+}
+
+func (c *Client) list(ctx context.Context, period string, date time.Time) ([]Event, error) {
+	var dtos []eventDTO
+	path := "/events/" + period + "?date=" + date.Format(dateLayout)
+	if err := c.do(ctx, http.MethodGet, path, nil, &dtos); err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(dtos))
+	for _, dto := range dtos {
+		event, err := dto.toEvent()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.UserID != "" {
+		req.Header.Set("X-User-ID", c.config.UserID)
+	}
+	if c.config.OrgID != "" {
+		req.Header.Set("X-Org-ID", c.config.OrgID)
+	}
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return &APIError{Status: resp.StatusCode, Message: apiErr.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+	webhooks := webhook.NewDispatcher(logg, webhook.Config{LogSize: 10})
+	calendar := app.New(logg, memorystorage.New(), feed.NewBroker(10, 10), webhooks,
+		subscription.NewManager(logg, subscription.Config{}), availability.NewStore(),
+		digest.NewStore(), attachment.NewStore(attachment.NewMemory(), attachment.Config{}), tenant.Config{})
+	server := internalhttp.NewServer(logg, calendar, health.NewChecker(health.Version{}, time.Second),
+		auth.Header{}, ratelimit.New(ratelimit.Config{}), internalhttp.Config{})
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	c := New(Config{BaseURL: ts.URL + "/", UserID: "alice", Timeout: time.Second})
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	created, err := c.Create(ctx, Event{
+		Title:        "standup",
+		StartAt:      start,
+		EndAt:        start.Add(15 * time.Minute),
+		NotifyBefore: 10 * time.Minute,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.ID)
+	require.Equal(t, "alice", created.UserID)
+	require.Equal(t, 10*time.Minute, created.NotifyBefore)
+
+	created.Title = "retro"
+	updated, err := c.Update(ctx, created.ID, created)
+	require.NoError(t, err)
+	require.Equal(t, created, updated)
+
+	got, err := c.Get(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, updated, got)
+
+	for _, list := range []func(context.Context, time.Time) ([]Event, error){c.Day, c.Week, c.Month} {
+		events, err := list(ctx, start)
+		require.NoError(t, err)
+		require.Equal(t, []Event{updated}, events)
+	}
+
+	require.NoError(t, c.Delete(ctx, created.ID))
+	_, err = c.Get(ctx, created.ID)
+	require.True(t, IsNotFound(err))
+
+	_, err = New(Config{BaseURL: ts.URL}).Day(ctx, start)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.Status)
+	require.Equal(t, app.ErrEmptyUserID.Error(), apiErr.Message)
+}
@@ -0,0 +1,217 @@
+// Package digest sends users an opt-in agenda of the next day or week at a
+// local time of their choice. Digests are rendered from Go templates as
+// text or HTML and go out over the reminder channels.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+)
+
+var (
+	ErrDigestNotFound  = errors.New("digest is not enabled")
+	ErrDigestExists    = errors.New("digest settings already exist")
+	ErrInvalidSettings = errors.New("invalid digest settings")
+)
+
+type Period string
+
+const (
+	// PeriodDaily covers the next day.
+	PeriodDaily Period = "daily"
+	// PeriodWeekly covers the seven days from the next one.
+	PeriodWeekly Period = "weekly"
+)
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatHTML Format = "html"
+)
+
+type Settings struct {
+	OrgID  string
+	UserID string
+	Period Period
+	// At is the local time of day to send at as an offset from midnight.
+	At time.Duration
+	// Weekday is the day weekly digests are sent on.
+	Weekday time.Weekday
+	// TimeZone is an IANA zone name, UTC when empty.
+	TimeZone string
+	Format   Format
+	// Channel is a reminder channel, the default one when empty.
+	Channel string
+}
+
+// validate checks the settings and fills the defaults.
+func (s *Settings) validate() error {
+	if s.Format == "" {
+		s.Format = FormatText
+	}
+	if s.Channel == "" {
+		s.Channel = reminder.DefaultChannel
+	}
+
+	switch {
+	case s.Period != PeriodDaily && s.Period != PeriodWeekly:
+		return fmt.Errorf("%w: period must be %s or %s", ErrInvalidSettings, PeriodDaily, PeriodWeekly)
+	case s.At < 0 || s.At >= 24*time.Hour || s.At%time.Minute != 0:
+		return fmt.Errorf("%w: time of day must be whole minutes within the day", ErrInvalidSettings)
+	case s.Weekday < time.Sunday || s.Weekday > time.Saturday:
+		return fmt.Errorf("%w: unknown weekday %d", ErrInvalidSettings, s.Weekday)
+	case s.Format != FormatText && s.Format != FormatHTML:
+		return fmt.Errorf("%w: format must be %s or %s", ErrInvalidSettings, FormatText, FormatHTML)
+	case !reminder.Known(s.Channel):
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidSettings, s.Channel)
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidSettings, s.TimeZone)
+	}
+	return nil
+}
+
+func (s Settings) location() *time.Location {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// LastDue returns the latest time not after now the digest was due at.
+func (s Settings) LastDue(now time.Time) time.Time {
+	today := startOfDay(now.In(s.location()))
+	for i := 0; i <= 7; i++ {
+		day := today.AddDate(0, 0, -i)
+		if s.Period == PeriodWeekly && day.Weekday() != s.Weekday {
+			continue
+		}
+		due := time.Date(day.Year(), day.Month(), day.Day(),
+			int(s.At/time.Hour), int(s.At%time.Hour/time.Minute), 0, 0, day.Location())
+		if !due.After(now) {
+			return due
+		}
+	}
+	return time.Time{}
+}
+
+// Window returns the local period summarized by the digest sent at due.
+func (s Settings) Window(due time.Time) (from, to time.Time) {
+	from = startOfDay(due.In(s.location())).AddDate(0, 0, 1)
+	if s.Period == PeriodWeekly {
+		return from, from.AddDate(0, 0, 7)
+	}
+	return from, from.AddDate(0, 0, 1)
+}
+
+// owner keys the settings, user IDs are unique within an organization.
+type owner struct {
+	orgID  string
+	userID string
+}
+
+func (s Settings) owner() owner {
+	return owner{orgID: s.OrgID, userID: s.UserID}
+}
+
+// Store keeps digest settings of all users in memory.
+type Store struct {
+	mu       sync.RWMutex
+	settings map[owner]Settings
+}
+
+func NewStore() *Store {
+	return &Store{settings: make(map[owner]Settings)}
+}
+
+func (s *Store) Get(_ context.Context, orgID, userID string) (Settings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, ok := s.settings[owner{orgID: orgID, userID: userID}]
+	if !ok {
+		return Settings{}, ErrDigestNotFound
+	}
+	return settings, nil
+}
+
+// Set enables the digest of the user or replaces its settings.
+func (s *Store) Set(_ context.Context, settings Settings) (Settings, error) {
+	if err := settings.validate(); err != nil {
+		return Settings{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[settings.owner()] = settings
+	return settings, nil
+}
+
+// Delete disables the digest of the user.
+func (s *Store) Delete(_ context.Context, orgID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := owner{orgID: orgID, userID: userID}
+	if _, ok := s.settings[key]; !ok {
+		return ErrDigestNotFound
+	}
+	delete(s.settings, key)
+	return nil
+}
+
+// ExportDigests calls fn for settings of every user ordered by
+// organization and user ID.
+func (s *Store) ExportDigests(_ context.Context, fn func(Settings) error) error {
+	for _, settings := range s.all() {
+		if err := fn(settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportDigest stores the settings of a user who has none.
+func (s *Store) ImportDigest(_ context.Context, settings Settings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.settings[settings.owner()]; ok {
+		return ErrDigestExists
+	}
+	s.settings[settings.owner()] = settings
+	return nil
+}
+
+func (s *Store) all() []Settings {
+	s.mu.RLock()
+	all := make([]Settings, 0, len(s.settings))
+	for _, settings := range s.settings {
+		all = append(all, settings)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].OrgID != all[j].OrgID {
+			return all[i].OrgID < all[j].OrgID
+		}
+		return all[i].UserID < all[j].UserID
+	})
+	return all
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,186 @@
+package digest
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+var moscow = mustLoad("Europe/Moscow")
+
+func mustLoad(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+func TestLastDue(t *testing.T) {
+	daily := Settings{Period: PeriodDaily, At: 18 * time.Hour, TimeZone: "Europe/Moscow"}
+	// 2024-03-01 is a Friday.
+	now := time.Date(2024, 3, 1, 17, 0, 0, 0, moscow)
+	require.Equal(t, time.Date(2024, 2, 29, 18, 0, 0, 0, moscow), daily.LastDue(now))
+	now = time.Date(2024, 3, 1, 18, 0, 0, 0, moscow)
+	require.Equal(t, now, daily.LastDue(now))
+
+	from, to := daily.Window(now)
+	require.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, moscow), from)
+	require.Equal(t, time.Date(2024, 3, 3, 0, 0, 0, 0, moscow), to)
+
+	weekly := Settings{Period: PeriodWeekly, Weekday: time.Sunday, At: 20 * time.Hour}
+	require.Equal(t, time.Date(2024, 2, 25, 20, 0, 0, 0, time.UTC), weekly.LastDue(now))
+	from, to = weekly.Window(weekly.LastDue(now))
+	require.Equal(t, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), from)
+	require.Equal(t, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), to)
+}
+
+func TestRender(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+
+	settings := Settings{UserID: "alice", Period: PeriodWeekly, TimeZone: "Europe/Moscow", Format: FormatText}
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, moscow)
+	events := []storage.Event{
+		{
+			Title:   "standup",
+			StartAt: time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC),
+			EndAt:   time.Date(2024, 3, 4, 7, 15, 0, 0, time.UTC),
+		},
+		{
+			Title:   "review",
+			StartAt: time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC),
+			EndAt:   time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:   "Q&A",
+			StartAt: time.Date(2024, 3, 6, 13, 0, 0, 0, time.UTC),
+			EndAt:   time.Date(2024, 3, 6, 14, 0, 0, 0, time.UTC),
+		},
+	}
+
+	d, err := renderer.Render(settings, from, from.AddDate(0, 0, 7), events)
+	require.NoError(t, err)
+	require.Equal(t, "Your week from Monday, 4 March", d.Subject)
+	require.Equal(t, 3, d.Events)
+	require.Equal(t, "Your week from Monday, 4 March\n"+
+		"\nMonday, 4 March\n"+
+		"  10:00-10:15  standup\n"+
+		"  14:00-15:00  review\n"+
+		"\nWednesday, 6 March\n"+
+		"  16:00-17:00  Q&A\n", d.Body)
+
+	settings.Format = FormatHTML
+	d, err = renderer.Render(settings, from, from.AddDate(0, 0, 7), events)
+	require.NoError(t, err)
+	require.Contains(t, d.Body, "<li>16:00&ndash;17:00 Q&amp;A</li>")
+
+	settings.Period, settings.Format = PeriodDaily, FormatText
+	d, err = renderer.Render(settings, from, from.AddDate(0, 0, 1), nil)
+	require.NoError(t, err)
+	require.Equal(t, "Your agenda for Monday, 4 March\n\nNothing planned.\n", d.Body)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, textTemplate),
+		[]byte(`{{define "subject"}}agenda{{end}}{{define "body"}}{{len .Days}} days{{end}}`), 0o600))
+	_, err = NewRenderer(dir)
+	require.Error(t, err, "the html template is missing")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, htmlTemplate), []byte(`{{define "body"}}<p>{{end}}`), 0o600))
+	custom, err := NewRenderer(dir)
+	require.NoError(t, err)
+	d, err = custom.Render(settings, from, from.AddDate(0, 0, 1), events[:1])
+	require.NoError(t, err)
+	require.Equal(t, "agenda", d.Subject)
+	require.Equal(t, "1 days", d.Body)
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+
+	_, err := store.Get(ctx, "", "alice")
+	require.ErrorIs(t, err, ErrDigestNotFound)
+
+	for _, invalid := range []Settings{
+		{UserID: "alice", Period: "hourly"},
+		{UserID: "alice", Period: PeriodDaily, At: 24 * time.Hour},
+		{UserID: "alice", Period: PeriodDaily, Format: "pdf"},
+		{UserID: "alice", Period: PeriodDaily, Channel: "email"},
+		{UserID: "alice", Period: PeriodDaily, TimeZone: "Mars/Olympus"},
+	} {
+		_, err := store.Set(ctx, invalid)
+		require.ErrorIs(t, err, ErrInvalidSettings)
+	}
+
+	saved, err := store.Set(ctx, Settings{UserID: "alice", Period: PeriodDaily, At: 18 * time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, FormatText, saved.Format)
+	require.Equal(t, "webhook", saved.Channel)
+	_, err = store.Get(ctx, "acme", "alice")
+	require.ErrorIs(t, err, ErrDigestNotFound, "same user ID in another organization")
+
+	require.ErrorIs(t, store.ImportDigest(ctx, saved), ErrDigestExists)
+	require.NoError(t, store.Delete(ctx, "", "alice"))
+	require.ErrorIs(t, store.Delete(ctx, "", "alice"), ErrDigestNotFound)
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	events := memorystorage.New()
+	require.NoError(t, events.CreateEvent(ctx, storage.Event{
+		ID:      "1",
+		UserID:  "alice",
+		Title:   "standup",
+		StartAt: time.Date(2024, 3, 2, 7, 0, 0, 0, time.UTC),
+		EndAt:   time.Date(2024, 3, 2, 7, 15, 0, 0, time.UTC),
+	}))
+
+	store := NewStore()
+	_, err := store.Set(ctx, Settings{
+		UserID: "alice", Period: PeriodDaily, At: 18 * time.Hour, TimeZone: "Europe/Moscow",
+	})
+	require.NoError(t, err)
+	_, err = store.Set(ctx, Settings{UserID: "bob", Period: PeriodWeekly, Weekday: time.Sunday, Channel: "log"})
+	require.NoError(t, err)
+
+	var sent []Digest
+	record := ChannelFunc(func(_ context.Context, d Digest) error {
+		sent = append(sent, d)
+		return nil
+	})
+	worker, err := NewWorker(logger.NewWithWriter("ERROR", io.Discard), store, events,
+		map[string]Channel{"webhook": record, "log": record}, Config{})
+	require.NoError(t, err)
+
+	now := time.Date(2024, 3, 1, 17, 59, 0, 0, moscow)
+	worker.now = func() time.Time { return now }
+	worker.Check(ctx)
+	require.Empty(t, sent, "the first check only sets the watermark")
+
+	now = now.Add(time.Minute)
+	worker.Check(ctx)
+	require.Len(t, sent, 1)
+	require.Equal(t, "alice", sent[0].UserID)
+	require.Equal(t, 1, sent[0].Events)
+	require.Contains(t, sent[0].Body, "10:00-10:15  standup")
+
+	now = now.Add(time.Minute)
+	worker.Check(ctx)
+	require.Len(t, sent, 1, "a digest is sent once")
+
+	// Sunday midnight UTC is due for bob.
+	now = time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	worker.Check(ctx)
+	require.Len(t, sent, 3, "alice's Saturday digest and bob's weekly one")
+	require.Equal(t, "alice", sent[1].UserID)
+	require.Equal(t, "bob", sent[2].UserID)
+	require.Equal(t, PeriodWeekly, sent[2].Period)
+}
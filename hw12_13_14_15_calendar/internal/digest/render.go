@@ -0,0 +1,146 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	textTemplate = "digest.txt.tmpl"
+	htmlTemplate = "digest.html.tmpl"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Digest is a rendered agenda ready to be sent.
+type Digest struct {
+	OrgID   string
+	UserID  string
+	Period  Period
+	Format  Format
+	From    time.Time
+	To      time.Time
+	Events  int
+	Subject string
+	Body    string
+}
+
+// View is what templates render. Times are in the user zone.
+type View struct {
+	UserID string
+	Period Period
+	From   time.Time
+	To     time.Time
+	Days   []Day
+}
+
+// Day lists events starting on Date, events running into the period from
+// before are listed on its first day.
+type Day struct {
+	Date   time.Time
+	Events []storage.Event
+}
+
+// Renderer turns agendas into digests. The text template defines "subject"
+// and "body", the HTML one defines "body", the subject is always text.
+type Renderer struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// NewRenderer parses digest.txt.tmpl and digest.html.tmpl from dir, or the
+// built-in templates when dir is empty.
+func NewRenderer(dir string) (*Renderer, error) {
+	read := func(name string) (string, error) {
+		var data []byte
+		var err error
+		if dir == "" {
+			data, err = defaultTemplates.ReadFile("templates/" + name)
+		} else {
+			data, err = os.ReadFile(filepath.Join(dir, name))
+		}
+		if err != nil {
+			return "", fmt.Errorf("read digest template: %w", err)
+		}
+		return string(data), nil
+	}
+
+	text, err := read(textTemplate)
+	if err != nil {
+		return nil, err
+	}
+	html, err := read(htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{}
+	if r.text, err = texttemplate.New(textTemplate).Parse(text); err != nil {
+		return nil, fmt.Errorf("parse digest template: %w", err)
+	}
+	if r.html, err = htmltemplate.New(htmlTemplate).Parse(html); err != nil {
+		return nil, fmt.Errorf("parse digest template: %w", err)
+	}
+	return r, nil
+}
+
+// Render renders the events of [from, to) for the settings.
+func (r *Renderer) Render(settings Settings, from, to time.Time, events []storage.Event) (Digest, error) {
+	view := newView(settings, from, to, events)
+	d := Digest{
+		OrgID:  settings.OrgID,
+		UserID: settings.UserID,
+		Period: settings.Period,
+		Format: settings.Format,
+		From:   view.From,
+		To:     view.To,
+		Events: len(events),
+	}
+
+	var subject, body bytes.Buffer
+	if err := r.text.ExecuteTemplate(&subject, "subject", view); err != nil {
+		return Digest{}, fmt.Errorf("render digest subject: %w", err)
+	}
+	var err error
+	if settings.Format == FormatHTML {
+		err = r.html.ExecuteTemplate(&body, "body", view)
+	} else {
+		err = r.text.ExecuteTemplate(&body, "body", view)
+	}
+	if err != nil {
+		return Digest{}, fmt.Errorf("render digest body: %w", err)
+	}
+
+	d.Subject = strings.TrimSpace(subject.String())
+	d.Body = body.String()
+	return d, nil
+}
+
+func newView(settings Settings, from, to time.Time, events []storage.Event) View {
+	loc := settings.location()
+	view := View{UserID: settings.UserID, Period: settings.Period, From: from.In(loc), To: to.In(loc)}
+
+	for _, event := range events {
+		event.StartAt, event.EndAt = event.StartAt.In(loc), event.EndAt.In(loc)
+		date := startOfDay(event.StartAt)
+		if date.Before(view.From) {
+			date = view.From
+		}
+		if n := len(view.Days); n > 0 && view.Days[n-1].Date.Equal(date) {
+			view.Days[n-1].Events = append(view.Days[n-1].Events, event)
+			continue
+		}
+		view.Days = append(view.Days, Day{Date: date, Events: []storage.Event{event}})
+	}
+	return view
+}
@@ -0,0 +1,158 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const defaultInterval = time.Minute
+
+type Config struct {
+	Interval time.Duration
+	// Templates is a directory with digest.txt.tmpl and digest.html.tmpl,
+	// the built-in templates are used when empty.
+	Templates string
+}
+
+type Logger interface {
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+type Events interface {
+	ListEvents(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error)
+}
+
+// Channel delivers digests of one kind, the names are those of reminder
+// channels.
+type Channel interface {
+	Send(ctx context.Context, d Digest) error
+}
+
+type ChannelFunc func(ctx context.Context, d Digest) error
+
+func (f ChannelFunc) Send(ctx context.Context, d Digest) error {
+	return f(ctx, d)
+}
+
+// Worker sends digests that became due since the previous check. Digests
+// due while the process was down are not sent.
+type Worker struct {
+	logger   Logger
+	store    *Store
+	events   Events
+	channels map[string]Channel
+	renderer *Renderer
+
+	mu     sync.RWMutex
+	config Config
+	now    func() time.Time
+
+	// checkMu serializes checks, they may also be run on demand.
+	checkMu sync.Mutex
+	last    time.Time
+}
+
+// NewWorker parses the templates, they are fixed at construction.
+func NewWorker(
+	logger Logger, store *Store, events Events, channels map[string]Channel, config Config,
+) (*Worker, error) {
+	renderer, err := NewRenderer(config.Templates)
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{
+		logger:   logger,
+		store:    store,
+		events:   events,
+		channels: channels,
+		renderer: renderer,
+		config:   config,
+		now:      time.Now,
+	}, nil
+}
+
+// Reconfigure applies a new interval from the next check.
+func (w *Worker) Reconfigure(config Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.config.Interval = config.Interval
+}
+
+func (w *Worker) currentConfig() Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.config
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	w.checkMu.Lock()
+	w.last = w.now()
+	w.checkMu.Unlock()
+	for {
+		interval := w.currentConfig().Interval
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		w.Check(ctx)
+	}
+}
+
+// Check sends digests due since the previous check, failures are logged
+// and skipped.
+func (w *Worker) Check(ctx context.Context) {
+	w.checkMu.Lock()
+	defer w.checkMu.Unlock()
+
+	now := w.now()
+	if w.last.IsZero() {
+		w.last = now
+	}
+
+	for _, settings := range w.store.all() {
+		due := settings.LastDue(now)
+		if !due.After(w.last) {
+			continue
+		}
+		if err := w.send(ctx, settings, due); err != nil {
+			metrics.Digests.WithLabelValues(string(settings.Period), "failed").Inc()
+			w.logger.Warn(fmt.Sprintf("digests: %s digest of %s: %s", settings.Period, settings.UserID, err))
+			continue
+		}
+		metrics.Digests.WithLabelValues(string(settings.Period), "sent").Inc()
+	}
+	w.last = now
+}
+
+func (w *Worker) send(ctx context.Context, settings Settings, due time.Time) error {
+	channel, ok := w.channels[settings.Channel]
+	if !ok {
+		return fmt.Errorf("unknown channel %q", settings.Channel)
+	}
+
+	from, to := settings.Window(due)
+	events, err := w.events.ListEvents(ctx, settings.OrgID, settings.UserID, from, to)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	d, err := w.renderer.Render(settings, from, to, events)
+	if err != nil {
+		return err
+	}
+	return channel.Send(ctx, d)
+}
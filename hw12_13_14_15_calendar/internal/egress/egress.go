@@ -0,0 +1,91 @@
+// Package egress makes HTTP requests to URLs supplied by users, such as
+// webhook targets and subscribed calendars. Connections to loopback,
+// private, link-local and other internal addresses are refused, so users
+// cannot reach the internal network through the service.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const maxRedirects = 10
+
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// internal are ranges netip does not classify: "this network" and the
+// carrier-grade NAT shared address space.
+var internal = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// Allowed reports whether addr is a public unicast address.
+func Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range internal {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient returns a client refusing internal addresses unless
+// allowPrivate reports true. The address is checked on every dial, after
+// DNS resolution, so redirects and names resolving to internal addresses
+// are caught as well.
+func NewClient(allowPrivate func() bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if allowPrivate() {
+				return nil
+			}
+			return check(address)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect to the target on our behalf, past the check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: otelhttp.NewTransport(transport),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %s: %w", req.URL.Scheme, ErrForbiddenAddress)
+			}
+			return nil
+		},
+	}
+}
+
+func check(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !Allowed(addr) {
+		return fmt.Errorf("%s: %w", addr, ErrForbiddenAddress)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package egress
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	for addr, allowed := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		require.Equal(t, allowed, Allowed(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	get := func(client *http.Client, url string) error {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	require.ErrorIs(t, get(NewClient(func() bool { return false }), ts.URL), ErrForbiddenAddress)
+	require.NoError(t, get(NewClient(func() bool { return true }), ts.URL))
+
+	// The first dial reaches the test server, the redirect is refused.
+	dials := 0
+	client := NewClient(func() bool {
+		dials++
+		return dials == 1
+	})
+	require.ErrorIs(t, get(client, ts.URL+"/redirect"), ErrForbiddenAddress)
+}
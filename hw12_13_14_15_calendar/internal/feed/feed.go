@@ -0,0 +1,171 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Latest as the last ID subscribes to the changes published after the call,
+// with no backlog. Clients that have not seen any change yet start there.
+const Latest uint64 = math.MaxUint64
+
+// ErrChangeExpired means the requested change ID has already been evicted
+// from the buffer, or was issued before a restart reset the IDs, and the
+// client has to reload its state from scratch.
+var ErrChangeExpired = errors.New("change is no longer available")
+
+type Change struct {
+	ID     uint64
+	Type   ChangeType
+	OrgID  string
+	UserID string
+	At     time.Time
+	Event  storage.Event
+	// Trace carries the trace context of the write that made the change.
+	Trace map[string]string
+}
+
+type subscriber struct {
+	orgID  string
+	userID string
+	ch     chan Change
+}
+
+// wants reports whether the change belongs to the subscribed user, an
+// empty user ID subscribes to changes of all organizations and users.
+func (s *subscriber) wants(change Change) bool {
+	return s.userID == "" || (s.orgID == change.OrgID && s.userID == change.UserID)
+}
+
+// Broker keeps the last changes in a ring buffer and fans them out to subscribers.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	buffer      []Change
+	next        int
+	size        int
+	subscribers map[*subscriber]struct{}
+	sendBuffer  int
+}
+
+func NewBroker(bufferSize, subscriberBuffer int) *Broker {
+	return &Broker{
+		buffer:      make([]Change, bufferSize),
+		subscribers: make(map[*subscriber]struct{}),
+		sendBuffer:  subscriberBuffer,
+	}
+}
+
+// Publish assigns the next ID to change and delivers it to subscribers.
+// Subscribers that can't keep up are disconnected and have to resume.
+func (b *Broker) Publish(change Change) Change {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	change.ID = b.lastID
+	if change.At.IsZero() {
+		change.At = time.Now()
+	}
+
+	if len(b.buffer) > 0 {
+		b.buffer[b.next] = change
+		b.next = (b.next + 1) % len(b.buffer)
+		if b.size < len(b.buffer) {
+			b.size++
+		}
+	}
+
+	for sub := range b.subscribers {
+		if !sub.wants(change) {
+			continue
+		}
+		select {
+		case sub.ch <- change:
+		default:
+			b.drop(sub)
+		}
+	}
+	return change
+}
+
+// Subscribe returns buffered changes after lastID and a channel with the
+// following ones. Empty userID subscribes to changes of all organizations
+// and users. The channel is closed when ctx is done or the subscriber lags
+// behind.
+func (b *Broker) Subscribe(
+	ctx context.Context, orgID, userID string, lastID uint64,
+) ([]Change, <-chan Change, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &subscriber{orgID: orgID, userID: userID, ch: make(chan Change, b.sendBuffer)}
+	backlog, err := b.since(sub, lastID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b.subscribers[sub] = struct{}{}
+
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.drop(sub)
+	})
+
+	return backlog, sub.ch, nil
+}
+
+// LastID returns the ID of the most recently published change.
+func (b *Broker) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// since must be called with mu held.
+func (b *Broker) since(sub *subscriber, lastID uint64) ([]Change, error) {
+	if lastID == Latest || lastID == b.lastID {
+		return nil, nil
+	}
+	if lastID > b.lastID {
+		return nil, ErrChangeExpired
+	}
+	oldest := b.lastID - uint64(b.size) + 1
+	if lastID+1 < oldest {
+		return nil, ErrChangeExpired
+	}
+
+	result := make([]Change, 0, b.lastID-lastID)
+	start := (b.next - b.size + len(b.buffer)) % len(b.buffer)
+	for i := 0; i < b.size; i++ {
+		change := b.buffer[(start+i)%len(b.buffer)]
+		if change.ID <= lastID || !sub.wants(change) {
+			continue
+		}
+		result = append(result, change)
+	}
+	return result, nil
+}
+
+// drop must be called with mu held.
+func (b *Broker) drop(sub *subscriber) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,124 @@
+package feed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func publish(b *Broker, userID string, n int) {
+	for i := 0; i < n; i++ {
+		b.Publish(Change{Type: ChangeCreated, UserID: userID, Event: storage.Event{UserID: userID}})
+	}
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("live changes of own user", func(t *testing.T) {
+		b := NewBroker(10, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		backlog, ch, err := b.Subscribe(ctx, "", "user", 0)
+		require.NoError(t, err)
+		require.Empty(t, backlog)
+
+		publish(b, "other", 1)
+		publish(b, "user", 1)
+
+		change := <-ch
+		require.Equal(t, uint64(2), change.ID)
+		require.Equal(t, "user", change.UserID)
+		require.False(t, change.At.IsZero())
+	})
+
+	t.Run("resume from last id", func(t *testing.T) {
+		b := NewBroker(10, 10)
+		publish(b, "user", 3)
+		publish(b, "other", 1)
+		publish(b, "user", 1)
+
+		backlog, _, err := b.Subscribe(context.Background(), "", "user", 2)
+		require.NoError(t, err)
+		require.Len(t, backlog, 2)
+		require.Equal(t, uint64(3), backlog[0].ID)
+		require.Equal(t, uint64(5), backlog[1].ID)
+
+		backlog, _, err = b.Subscribe(context.Background(), "", "", 2)
+		require.NoError(t, err)
+		require.Len(t, backlog, 3)
+	})
+
+	t.Run("same user in another organization", func(t *testing.T) {
+		b := NewBroker(10, 10)
+		b.Publish(Change{Type: ChangeCreated, OrgID: "acme", UserID: "user"})
+		publish(b, "user", 1)
+
+		backlog, _, err := b.Subscribe(context.Background(), "acme", "user", 0)
+		require.NoError(t, err)
+		require.Len(t, backlog, 1)
+		require.Equal(t, "acme", backlog[0].OrgID)
+	})
+
+	t.Run("evicted change", func(t *testing.T) {
+		b := NewBroker(3, 10)
+		publish(b, "user", 5)
+
+		_, _, err := b.Subscribe(context.Background(), "", "user", 1)
+		require.ErrorIs(t, err, ErrChangeExpired)
+
+		backlog, _, err := b.Subscribe(context.Background(), "", "user", 2)
+		require.NoError(t, err)
+		require.Len(t, backlog, 3)
+	})
+
+	t.Run("change from before a restart", func(t *testing.T) {
+		b := NewBroker(3, 10)
+		publish(b, "user", 2)
+
+		_, _, err := b.Subscribe(context.Background(), "", "user", 7)
+		require.ErrorIs(t, err, ErrChangeExpired)
+
+		backlog, _, err := b.Subscribe(context.Background(), "", "user", 2)
+		require.NoError(t, err)
+		require.Empty(t, backlog)
+	})
+
+	t.Run("new subscriber after the buffer wrapped", func(t *testing.T) {
+		b := NewBroker(3, 10)
+		publish(b, "user", 5)
+
+		backlog, ch, err := b.Subscribe(context.Background(), "", "user", Latest)
+		require.NoError(t, err)
+		require.Empty(t, backlog)
+
+		publish(b, "user", 1)
+		change := <-ch
+		require.Equal(t, uint64(6), change.ID)
+	})
+
+	t.Run("unsubscribe on context cancel", func(t *testing.T) {
+		b := NewBroker(10, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		_, ch, err := b.Subscribe(ctx, "", "user", 0)
+		require.NoError(t, err)
+		cancel()
+
+		_, ok := <-ch
+		require.False(t, ok)
+	})
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		b := NewBroker(10, 1)
+		_, ch, err := b.Subscribe(context.Background(), "", "user", 0)
+		require.NoError(t, err)
+
+		publish(b, "user", 2)
+
+		<-ch
+		_, ok := <-ch
+		require.False(t, ok)
+	})
+}
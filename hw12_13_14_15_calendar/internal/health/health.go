@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+type Check func(ctx context.Context) error
+
+type Version struct {
+	Release   string `json:"release"`
+	BuildDate string `json:"buildDate"`
+	GitHash   string `json:"gitHash"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs readiness checks of the process dependencies.
+type Checker struct {
+	mu      sync.RWMutex
+	version Version
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+func NewChecker(version Version, timeout time.Duration) *Checker {
+	return &Checker{
+		version: version,
+		timeout: timeout,
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+func (c *Checker) Version() Version {
+	return c.version
+}
+
+// Ready runs all checks concurrently, each one limited by the checker timeout.
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]string, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = StatusOK
+			if err := check(ctx); err != nil {
+				results[i] = err.Error()
+			}
+		}(i, nc.check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]string, len(checks))}
+	for i, nc := range checks {
+		report.Checks[nc.name] = results[i]
+		if results[i] != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
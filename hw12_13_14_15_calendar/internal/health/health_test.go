@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	t.Run("all checks pass", func(t *testing.T) {
+		c := NewChecker(Version{Release: "develop"}, time.Second)
+		c.Add("storage", func(context.Context) error { return nil })
+
+		report := c.Ready(context.Background())
+		require.Equal(t, StatusOK, report.Status)
+		require.Equal(t, map[string]string{"storage": StatusOK}, report.Checks)
+		require.Equal(t, "develop", c.Version().Release)
+	})
+
+	t.Run("failed and slow checks", func(t *testing.T) {
+		c := NewChecker(Version{}, 50*time.Millisecond)
+		c.Add("storage", func(context.Context) error { return nil })
+		c.Add("queue", func(context.Context) error { return errors.New("connection refused") })
+		c.Add("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		report := c.Ready(context.Background())
+		require.Equal(t, StatusFail, report.Status)
+		require.Equal(t, StatusOK, report.Checks["storage"])
+		require.Equal(t, "connection refused", report.Checks["queue"])
+		require.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"])
+	})
+}
@@ -0,0 +1,79 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDuration parses RFC 5545 durations like -PT15M, P1D or P1W.
+func parseDuration(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var (
+		total  time.Duration
+		number string
+		inTime bool
+	)
+	units := map[bool]map[byte]time.Duration{
+		false: {'W': 7 * day, 'D': day},
+		true:  {'H': time.Hour, 'M': time.Minute, 'S': time.Second},
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			unit, ok := units[inTime][c]
+			if !ok || number == "" {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, err
+			}
+			total += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return sign * total, nil
+}
+
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	b.WriteString("P")
+	if days := d / day; days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * day
+	}
+	if d == 0 {
+		return b.String()
+	}
+	b.WriteString("T")
+	for _, unit := range []struct {
+		d      time.Duration
+		suffix string
+	}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+		if n := d / unit.d; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			d -= n * unit.d
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,292 @@
+// Package ical reads and writes VEVENT components of iCalendar (RFC 5545).
+// Only the properties the calendar stores are supported.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	utcLayout     = "20060102T150405Z"
+	localLayout   = "20060102T150405"
+	dateLayout    = "20060102"
+	maxLineOctets = 75
+	productID     = "-//calendar//calendar//EN"
+	day           = 24 * time.Hour
+)
+
+var ErrMalformed = errors.New("malformed icalendar")
+
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	// AllDay is set for events with DATE values, Start and End are midnights.
+	AllDay bool
+	// Alarm is how long before Start the first display alarm fires, zero if none.
+	Alarm time.Duration
+}
+
+// Encode writes events as a VCALENDAR with times in UTC.
+func Encode(w io.Writer, events []Event) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", productID)
+	stamp := time.Now().UTC().Format(utcLayout)
+	for _, event := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(event.UID))
+		line("DTSTAMP", stamp)
+		if event.AllDay {
+			line("DTSTART;VALUE=DATE", event.Start.Format(dateLayout))
+			line("DTEND;VALUE=DATE", event.End.Format(dateLayout))
+		} else {
+			line("DTSTART", event.Start.UTC().Format(utcLayout))
+			line("DTEND", event.End.UTC().Format(utcLayout))
+		}
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if event.Alarm > 0 {
+			line("BEGIN", "VALARM")
+			line("ACTION", "DISPLAY")
+			line("DESCRIPTION", escape(event.Summary))
+			line("TRIGGER", "-"+formatDuration(event.Alarm))
+			line("END", "VALARM")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeFolded splits lines longer than 75 octets without breaking UTF-8 sequences.
+func writeFolded(w *bufio.Writer, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(s + "\r\n")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Decode reads all VEVENTs of the stream. Recurrence rules are ignored,
+// only the first occurrence is returned.
+func Decode(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events  []Event
+		current *Event
+		depth   []string
+	)
+	for n, raw := range lines {
+		prop, err := parseLine(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrMalformed, n+1, err)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			depth = append(depth, strings.ToUpper(prop.value))
+			if strings.EqualFold(prop.value, "VEVENT") {
+				current = &Event{}
+			}
+			continue
+		case "END":
+			if len(depth) == 0 || !strings.EqualFold(depth[len(depth)-1], prop.value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrMalformed, n+1, prop.value)
+			}
+			depth = depth[:len(depth)-1]
+			if strings.EqualFold(prop.value, "VEVENT") && current != nil {
+				if err := finish(current); err != nil {
+					return nil, fmt.Errorf("%w: event %q: %w", ErrMalformed, current.UID, err)
+				}
+				events = append(events, *current)
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil || len(depth) == 0 {
+			continue
+		}
+		if err := current.apply(depth[len(depth)-1], prop); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrMalformed, n+1, err)
+		}
+	}
+	if len(depth) != 0 {
+		return nil, fmt.Errorf("%w: %s is not closed", ErrMalformed, depth[len(depth)-1])
+	}
+	return events, nil
+}
+
+func (e *Event) apply(component string, prop property) error {
+	if component == "VALARM" {
+		// Absolute and end-related triggers are not supported and skipped.
+		if prop.name == "TRIGGER" && e.Alarm == 0 && prop.params["RELATED"] != "END" {
+			if d, err := parseDuration(prop.value); err == nil && d < 0 {
+				e.Alarm = -d
+			}
+		}
+		return nil
+	}
+	if component != "VEVENT" {
+		return nil
+	}
+
+	var err error
+	switch prop.name {
+	case "UID":
+		e.UID = prop.value
+	case "SUMMARY":
+		e.Summary = unescape(prop.value)
+	case "DESCRIPTION":
+		e.Description = unescape(prop.value)
+	case "DTSTART":
+		e.Start, e.AllDay, err = parseTime(prop)
+	case "DTEND":
+		e.End, _, err = parseTime(prop)
+	case "DURATION":
+		var d time.Duration
+		if d, err = parseDuration(prop.value); err == nil && !e.Start.IsZero() && e.End.IsZero() {
+			e.End = e.Start.Add(d)
+		}
+	}
+	return err
+}
+
+func finish(e *Event) error {
+	if e.Start.IsZero() {
+		return errors.New("no DTSTART")
+	}
+	if e.End.IsZero() {
+		if e.AllDay {
+			e.End = e.Start.Add(day)
+		} else {
+			e.End = e.Start
+		}
+	}
+	return nil
+}
+
+func parseTime(prop property) (time.Time, bool, error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcLayout, value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(strings.Trim(tzid, `"`)); err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	t, err := time.ParseInLocation(localLayout, value, loc)
+	return t, false, err
+}
+
+func parseLine(line string) (property, error) {
+	colon := -1
+	quoted := false
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("no value in %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := property{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = value
+	}
+	return prop, nil
+}
+
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
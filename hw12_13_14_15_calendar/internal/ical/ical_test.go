@@ -0,0 +1,96 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	events := []Event{
+		{
+			UID:         "1@calendar",
+			Summary:     "Планёрка; weekly, " + strings.Repeat("long ", 20),
+			Description: "line one\nline two \\ done",
+			Start:       time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			End:         time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+			Alarm:       15 * time.Minute,
+		},
+		{
+			UID:     "2@calendar",
+			Summary: "Holiday",
+			Start:   time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC),
+			End:     time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+			AllDay:  true,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events))
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		require.LessOrEqual(t, len(line), 75)
+	}
+
+	decoded, err := Decode(&buf)
+	require.NoError(t, err)
+	require.Equal(t, events, decoded)
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("time zones and durations", func(t *testing.T) {
+		data := "BEGIN:VCALENDAR\r\n" +
+			"BEGIN:VTIMEZONE\r\nTZID:Europe/Moscow\r\nEND:VTIMEZONE\r\n" +
+			"BEGIN:VEVENT\r\nUID:a\r\nSUMMARY:Standup\r\n" +
+			"DTSTART;TZID=Europe/Moscow:20240301T100000\r\nDURATION:PT1H30M\r\n" +
+			"BEGIN:VALARM\r\nTRIGGER:-P1DT2H\r\nEND:VALARM\r\n" +
+			"END:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nUID:b\r\nDTSTART;VALUE=DATE:20240308\r\nSUMMARY:Wom\r\n en's day\r\nEND:VEVENT\r\n" +
+			"END:VCALENDAR\r\n"
+
+		events, err := Decode(strings.NewReader(data))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+
+		require.Equal(t, time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC), events[0].Start.UTC())
+		require.Equal(t, 90*time.Minute, events[0].End.Sub(events[0].Start))
+		require.Equal(t, 26*time.Hour, events[0].Alarm)
+
+		require.Equal(t, "Women's day", events[1].Summary)
+		require.True(t, events[1].AllDay)
+		require.Equal(t, 24*time.Hour, events[1].End.Sub(events[1].Start))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, data := range []string{
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:x\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20240301T100000Z\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nno colon here\r\nEND:VCALENDAR\r\n",
+		} {
+			_, err := Decode(strings.NewReader(data))
+			require.ErrorIs(t, err, ErrMalformed, data)
+		}
+	})
+}
+
+func TestDuration(t *testing.T) {
+	for s, d := range map[string]time.Duration{
+		"PT15M":      15 * time.Minute,
+		"-PT1H":      -time.Hour,
+		"P1W":        7 * 24 * time.Hour,
+		"P1DT2H3M4S": 26*time.Hour + 3*time.Minute + 4*time.Second,
+	} {
+		got, err := parseDuration(s)
+		require.NoError(t, err, s)
+		require.Equal(t, d, got, s)
+	}
+	require.Equal(t, "P1DT2H3M4S", formatDuration(26*time.Hour+3*time.Minute+4*time.Second))
+
+	for _, s := range []string{"", "PT", "15M", "PT15", "PT1D"} {
+		_, err := parseDuration(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,174 @@
+// Package lifecycle runs the servers and background workers of the process
+// as one unit and shuts them down in order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultStopTimeout = 10 * time.Second
+
+var ErrStopTimeout = errors.New("did not stop in time")
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+// Server serves until Stop is called. Start returns nil once stopped and
+// Stop waits for in-flight requests until its context is done.
+type Server interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+type Config struct {
+	// StopTimeout bounds each shutdown step: draining the servers, waiting
+	// for the workers and running the closers.
+	StopTimeout time.Duration
+}
+
+type component struct {
+	name  string
+	run   func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+	close func(ctx context.Context) error
+}
+
+// Group runs its components until the context is done or any of them
+// fails, then shuts all of them down:
+//
+//  1. servers stop accepting requests and drain the in-flight ones;
+//  2. workers are cancelled, so they see the changes of drained requests;
+//  3. closers run in reverse order of registration.
+type Group struct {
+	logger Logger
+	config Config
+
+	servers []component
+	workers []component
+	closers []component
+}
+
+func New(logger Logger, config Config) *Group {
+	if config.StopTimeout <= 0 {
+		config.StopTimeout = defaultStopTimeout
+	}
+	return &Group{logger: logger, config: config}
+}
+
+func (g *Group) AddServer(name string, server Server) {
+	g.servers = append(g.servers, component{name: name, run: server.Start, stop: server.Stop})
+}
+
+// AddWorker adds a function running until its context is done. A worker
+// returning earlier without an error is done, with an error it fails the group.
+func (g *Group) AddWorker(name string, run func(ctx context.Context) error) {
+	g.workers = append(g.workers, component{name: name, run: run})
+}
+
+// AddCloser adds a function releasing a resource, such as a storage
+// connection, once servers and workers are stopped. Closers are added in
+// the order resources are opened.
+func (g *Group) AddCloser(name string, fn func(ctx context.Context) error) {
+	g.closers = append(g.closers, component{name: name, close: fn})
+}
+
+// Run blocks until ctx is done or a component fails and everything is
+// shut down. It returns the first failure, nil after a plain stop.
+func (g *Group) Run(ctx context.Context) error {
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	workerCtx, cancelWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWorkers()
+
+	var (
+		failOnce sync.Once
+		failure  error
+	)
+	fail := func(name string, err error) {
+		failOnce.Do(func() {
+			failure = fmt.Errorf("%s: %w", name, err)
+			g.logger.Error(failure.Error() + ", stopping")
+		})
+		cancelRun()
+	}
+	start := func(ctx context.Context, wg *sync.WaitGroup, c component) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.run(ctx); err != nil {
+				fail(c.name, err)
+			}
+		}()
+	}
+
+	var servers, workers sync.WaitGroup
+	for _, c := range g.workers {
+		start(workerCtx, &workers, c)
+	}
+	for _, c := range g.servers {
+		start(runCtx, &servers, c)
+	}
+
+	<-runCtx.Done()
+	g.logger.Info("shutting down")
+
+	g.step("servers", func(ctx context.Context) {
+		var stops sync.WaitGroup
+		for _, c := range g.servers {
+			stops.Add(1)
+			go func() {
+				defer stops.Done()
+				if err := c.stop(ctx); err != nil {
+					g.logger.Error(fmt.Sprintf("failed to stop %s: %s", c.name, err))
+				}
+			}()
+		}
+		stops.Wait()
+		wait(ctx, &servers)
+	})
+
+	cancelWorkers()
+	g.step("workers", func(ctx context.Context) {
+		wait(ctx, &workers)
+	})
+
+	for i := len(g.closers) - 1; i >= 0; i-- {
+		c := g.closers[i]
+		g.step(c.name, func(ctx context.Context) {
+			if err := c.close(ctx); err != nil {
+				g.logger.Error(fmt.Sprintf("failed to close %s: %s", c.name, err))
+			}
+		})
+	}
+	return failure
+}
+
+// step runs fn with the stop timeout and reports when it took longer.
+func (g *Group) step(name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.config.StopTimeout)
+	defer cancel()
+
+	fn(ctx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		g.logger.Error(fmt.Sprintf("%s: %s after %s", name, ErrStopTimeout, g.config.StopTimeout))
+	}
+}
+
+// wait returns when wg is done or ctx is, whichever is first.
+func wait(ctx context.Context, wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,129 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type journal struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (j *journal) add(entry string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+}
+
+func (j *journal) list() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.entries...)
+}
+
+func (j *journal) Info(string) {}
+
+func (j *journal) Error(msg string) {
+	j.add("error " + msg)
+}
+
+type server struct {
+	name    string
+	journal *journal
+	stopped chan struct{}
+	drain   time.Duration
+}
+
+func newServer(name string, j *journal) *server {
+	return &server{name: name, journal: j, stopped: make(chan struct{})}
+}
+
+func (s *server) Start(context.Context) error {
+	<-s.stopped
+	return nil
+}
+
+func (s *server) Stop(ctx context.Context) error {
+	select {
+	case <-time.After(s.drain):
+	case <-ctx.Done():
+	}
+	s.journal.add("stop " + s.name)
+	close(s.stopped)
+	return nil
+}
+
+func worker(name string, j *journal) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		j.add("stop " + name)
+		return nil
+	}
+}
+
+func closer(name string, j *journal) func(ctx context.Context) error {
+	return func(context.Context) error {
+		j.add("close " + name)
+		return nil
+	}
+}
+
+func TestGroup(t *testing.T) {
+	t.Run("shutdown order", func(t *testing.T) {
+		j := &journal{}
+		g := New(j, Config{})
+		g.AddCloser("storage", closer("storage", j))
+		g.AddCloser("tracing", closer("tracing", j))
+		g.AddServer("http", newServer("http", j))
+		g.AddWorker("webhooks", worker("webhooks", j))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		require.NoError(t, g.Run(ctx))
+		require.Equal(t, []string{"stop http", "stop webhooks", "close tracing", "close storage"}, j.list())
+	})
+
+	t.Run("failure stops the rest", func(t *testing.T) {
+		j := &journal{}
+		g := New(j, Config{})
+		g.AddServer("http", newServer("http", j))
+		g.AddWorker("webhooks", worker("webhooks", j))
+		errListen := errors.New("address already in use")
+		g.AddWorker("metrics", func(context.Context) error {
+			return errListen
+		})
+
+		err := g.Run(context.Background())
+		require.ErrorIs(t, err, errListen)
+		require.ErrorContains(t, err, "metrics")
+		require.Equal(t, []string{
+			"error metrics: address already in use, stopping",
+			"stop http",
+			"stop webhooks",
+		}, j.list())
+	})
+
+	t.Run("stop timeout", func(t *testing.T) {
+		j := &journal{}
+		g := New(j, Config{StopTimeout: 20 * time.Millisecond})
+		slow := newServer("http", j)
+		slow.drain = time.Hour
+		g.AddServer("http", slow)
+		g.AddCloser("storage", closer("storage", j))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.NoError(t, g.Run(ctx))
+		require.Equal(t, []string{
+			"stop http",
+			"error servers: did not stop in time after 20ms",
+			"close storage",
+		}, j.list())
+	})
+}
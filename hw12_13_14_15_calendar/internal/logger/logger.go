@@ -1,20 +1,100 @@
 package logger
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
 
-type Logger struct { // TODO
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+func ParseLevel(level string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.EqualFold(level, name) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", level)
 }
 
+type Logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+// New creates a logger writing to STDOUT. Unknown levels fall back to INFO.
 func New(level string) *Logger {
-	return &Logger{}
+	return NewWithWriter(level, os.Stdout)
 }
 
-func (l Logger) Info(msg string) {
-	fmt.Println(msg)
+func NewWithWriter(level string, out io.Writer) *Logger {
+	l, _ := ParseLevel(level)
+	return &Logger{out: out, level: l}
+}
+
+// SetLevel changes the level at runtime, e.g. on config reload.
+func (l *Logger) SetLevel(level string) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = parsed
+	return nil
 }
 
-func (l Logger) Error(msg string) {
-	// TODO
+func (l *Logger) Level() Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level
 }
 
-// TODO
+func (l *Logger) Debug(msg string) {
+	l.log(LevelDebug, msg)
+}
+
+func (l *Logger) Info(msg string) {
+	l.log(LevelInfo, msg)
+}
+
+func (l *Logger) Warn(msg string) {
+	l.log(LevelWarn, msg)
+}
+
+func (l *Logger) Error(msg string) {
+	l.log(LevelError, msg)
+}
+
+func (l *Logger) log(level Level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level < l.level {
+		return
+	}
+	fmt.Fprintf(l.out, "%s [%s] %s\n", time.Now().Format(time.RFC3339), level, msg)
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	route := "GET /test/{id}"
+	handler := Middleware(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/test/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	for _, path := range []string{"/test/1", "/test/2", "/test/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	require.Equal(t, 2.0, testutil.ToFloat64(HTTPRequests.WithLabelValues(route, http.MethodGet, "200")))
+	require.Equal(t, 1.0, testutil.ToFloat64(HTTPRequests.WithLabelValues(route, http.MethodGet, "404")))
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body),
+		`calendar_http_request_duration_seconds_count{method="GET",route="GET /test/{id}"} 3`)
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	ctx := context.Background()
+	s := NewInstrumentedStorage(memorystorage.New())
+	start := time.Now()
+
+	event := storage.Event{ID: "1", UserID: "user", StartAt: start, EndAt: start.Add(time.Hour)}
+	require.NoError(t, s.CreateEvent(ctx, event))
+	require.ErrorIs(t, s.CreateEvent(ctx, event), storage.ErrEventExists)
+	_, err := s.ListEvents(ctx, "", "user", start, start.Add(time.Hour))
+	require.NoError(t, err)
+
+	// create/ok, create/error and list/ok series.
+	require.Equal(t, 3, testutil.CollectAndCount(StorageDuration))
+}
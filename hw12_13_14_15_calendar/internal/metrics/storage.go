@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event) error
+	UpdateEvent(ctx context.Context, id string, event storage.Event) error
+	DeleteEvent(ctx context.Context, orgID, id string) error
+	GetEvent(ctx context.Context, orgID, id string) (storage.Event, error)
+	ListEvents(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error)
+	CountEvents(ctx context.Context, orgID string) (int, error)
+	ListStarting(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+	ListDeleted(ctx context.Context, orgID, userID string) ([]storage.Event, error)
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (storage.PurgeResult, error)
+	AppendRevision(ctx context.Context, revision storage.Revision) (storage.Revision, error)
+	ListRevisions(ctx context.Context, orgID, eventID string) ([]storage.Revision, error)
+}
+
+// InstrumentedStorage records latency of every call to the wrapped storage.
+type InstrumentedStorage struct {
+	next Storage
+}
+
+func NewInstrumentedStorage(next Storage) *InstrumentedStorage {
+	return &InstrumentedStorage{next: next}
+}
+
+func (s *InstrumentedStorage) CreateEvent(ctx context.Context, event storage.Event) (err error) {
+	defer observe("create", time.Now(), &err)
+	return s.next.CreateEvent(ctx, event)
+}
+
+func (s *InstrumentedStorage) UpdateEvent(ctx context.Context, id string, event storage.Event) (err error) {
+	defer observe("update", time.Now(), &err)
+	return s.next.UpdateEvent(ctx, id, event)
+}
+
+func (s *InstrumentedStorage) DeleteEvent(ctx context.Context, orgID, id string) (err error) {
+	defer observe("delete", time.Now(), &err)
+	return s.next.DeleteEvent(ctx, orgID, id)
+}
+
+func (s *InstrumentedStorage) GetEvent(ctx context.Context, orgID, id string) (_ storage.Event, err error) {
+	defer observe("get", time.Now(), &err)
+	return s.next.GetEvent(ctx, orgID, id)
+}
+
+func (s *InstrumentedStorage) ListEvents(
+	ctx context.Context, orgID, userID string, from, to time.Time,
+) (_ []storage.Event, err error) {
+	defer observe("list", time.Now(), &err)
+	return s.next.ListEvents(ctx, orgID, userID, from, to)
+}
+
+func (s *InstrumentedStorage) CountEvents(ctx context.Context, orgID string) (_ int, err error) {
+	defer observe("count", time.Now(), &err)
+	return s.next.CountEvents(ctx, orgID)
+}
+
+func (s *InstrumentedStorage) ListStarting(ctx context.Context, from, to time.Time) (_ []storage.Event, err error) {
+	defer observe("list_starting", time.Now(), &err)
+	return s.next.ListStarting(ctx, from, to)
+}
+
+func (s *InstrumentedStorage) ListDeleted(
+	ctx context.Context, orgID, userID string,
+) (_ []storage.Event, err error) {
+	defer observe("list_deleted", time.Now(), &err)
+	return s.next.ListDeleted(ctx, orgID, userID)
+}
+
+func (s *InstrumentedStorage) PurgeEvents(
+	ctx context.Context, deletedBefore, endedBefore time.Time,
+) (_ storage.PurgeResult, err error) {
+	defer observe("purge", time.Now(), &err)
+	return s.next.PurgeEvents(ctx, deletedBefore, endedBefore)
+}
+
+func (s *InstrumentedStorage) AppendRevision(
+	ctx context.Context, revision storage.Revision,
+) (_ storage.Revision, err error) {
+	defer observe("append_revision", time.Now(), &err)
+	return s.next.AppendRevision(ctx, revision)
+}
+
+func (s *InstrumentedStorage) ListRevisions(
+	ctx context.Context, orgID, eventID string,
+) (_ []storage.Revision, err error) {
+	defer observe("list_revisions", time.Now(), &err)
+	return s.next.ListRevisions(ctx, orgID, eventID)
+}
+
+func observe(operation string, start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	StorageDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
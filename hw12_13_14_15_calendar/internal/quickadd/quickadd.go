@@ -0,0 +1,415 @@
+// Package quickadd turns short English or Russian phrases such as
+// "standup tomorrow 10:00 for 15m remind 5m before" or
+// "созвон завтра в 10 на полчаса напомнить за 5 минут" into an event.
+//
+// The phrase is read word by word. Recognised dates, times, durations and
+// reminders are taken out, the remaining words make the title.
+package quickadd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDuration is used when the phrase names neither a duration nor an end.
+const DefaultDuration = time.Hour
+
+var (
+	ErrNoTitle = errors.New("phrase has no title")
+	ErrNoTime  = errors.New("phrase has no start time")
+)
+
+type PartKind string
+
+const (
+	PartTitle    PartKind = "title"
+	PartDate     PartKind = "date"
+	PartTime     PartKind = "time"
+	PartEnd      PartKind = "end"
+	PartDuration PartKind = "duration"
+	PartReminder PartKind = "reminder"
+)
+
+// Part is a piece of the phrase and what it was read as.
+type Part struct {
+	Kind PartKind
+	// Text is the piece of the phrase as written.
+	Text string
+	// Value is the normalised reading, e.g. a date or a duration.
+	Value string
+}
+
+type Result struct {
+	Title   string
+	StartAt time.Time
+	EndAt   time.Time
+	// Reminders are lead times of the reminders asked for.
+	Reminders []time.Duration
+	Parts     []Part
+}
+
+// Parse reads the phrase relative to now in loc. Without a date the event
+// is today, without a duration or an end it lasts DefaultDuration.
+func Parse(text string, now time.Time, loc *time.Location) (Result, error) {
+	now = now.In(loc)
+	p := parser{words: strings.Fields(text), now: now, date: startOfDay(now)}
+	p.run()
+
+	if p.hour < 0 {
+		return Result{}, ErrNoTime
+	}
+	if len(p.title) == 0 {
+		return Result{}, ErrNoTitle
+	}
+
+	start := time.Date(p.date.Year(), p.date.Month(), p.date.Day(), p.hour, p.minute, 0, 0, loc)
+	end := start.Add(DefaultDuration)
+	switch {
+	case p.duration > 0:
+		end = start.Add(p.duration)
+	case p.endHour >= 0:
+		end = time.Date(start.Year(), start.Month(), start.Day(), p.endHour, p.endMinute, 0, 0, loc)
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+	}
+
+	title := strings.Join(p.title, " ")
+	parts := append([]Part{{Kind: PartTitle, Text: title, Value: title}}, p.parts...)
+	return Result{Title: title, StartAt: start, EndAt: end, Reminders: p.reminders, Parts: parts}, nil
+}
+
+type parser struct {
+	words []string
+	now   time.Time
+
+	date               time.Time
+	hour, minute       int
+	endHour, endMinute int
+	duration           time.Duration
+	reminders          []time.Duration
+	title              []string
+	parts              []Part
+}
+
+func (p *parser) run() {
+	p.hour, p.endHour = -1, -1
+	for i := 0; i < len(p.words); {
+		n := p.match(i)
+		if n == 0 {
+			p.title = append(p.title, p.words[i])
+			n = 1
+		}
+		i += n
+	}
+}
+
+// match reads a known construction at word i and returns the number of
+// words it took, zero if there is none.
+func (p *parser) match(i int) int {
+	matchers := []func(i int) int{p.matchReminder, p.matchDuration, p.matchEnd, p.matchTime, p.matchDate}
+	added := len(p.parts)
+	for _, m := range matchers {
+		if n := m(i); n > 0 {
+			for j := added; j < len(p.parts); j++ {
+				p.parts[j].Text = strings.Join(p.words[i:i+n], " ")
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func (p *parser) word(i int) string {
+	if i >= len(p.words) {
+		return ""
+	}
+	return strings.ToLower(strings.Trim(p.words[i], ",;!?"))
+}
+
+func (p *parser) add(kind PartKind, value string) {
+	p.parts = append(p.parts, Part{Kind: kind, Value: value})
+}
+
+// matchReminder reads "remind [me] 5m [before]" and "напомнить [мне] за 5 минут".
+func (p *parser) matchReminder(i int) int {
+	if !remindWords[p.word(i)] {
+		return 0
+	}
+	n := 1
+	if w := p.word(i + n); w == "me" || w == "мне" {
+		n++
+	}
+	if p.word(i+n) == "за" {
+		n++
+	}
+	d, m := p.readDuration(i + n)
+	if m == 0 {
+		return 0
+	}
+	n += m
+	if w := p.word(i + n); w == "before" || w == "earlier" || w == "ahead" || w == "заранее" || w == "до" {
+		n++
+	}
+
+	p.reminders = append(p.reminders, d)
+	p.add(PartReminder, d.String())
+	return n
+}
+
+// matchDuration reads "for 15m" and "на 15 минут".
+func (p *parser) matchDuration(i int) int {
+	if w := p.word(i); w != "for" && w != "на" {
+		return 0
+	}
+	d, n := p.readDuration(i + 1)
+	if n == 0 {
+		return 0
+	}
+	p.duration = d
+	p.add(PartDuration, d.String())
+	return n + 1
+}
+
+// matchEnd reads "until 11:00" and "до 11".
+func (p *parser) matchEnd(i int) int {
+	if w := p.word(i); w != "until" && w != "till" && w != "до" {
+		return 0
+	}
+	hour, minute, n := p.readTime(i+1, true)
+	if n == 0 {
+		return 0
+	}
+	p.endHour, p.endMinute = hour, minute
+	p.add(PartEnd, fmt.Sprintf("%02d:%02d", hour, minute))
+	return n + 1
+}
+
+// matchTime reads "10:00", "at 10", "10am", "в 10" and ranges like "10:00-11:30".
+func (p *parser) matchTime(i int) int {
+	n := 0
+	if w := p.word(i); w == "at" || w == "@" || w == "в" || w == "во" {
+		n = 1
+	}
+	if m := rangeRe.FindStringSubmatch(p.word(i + n)); m != nil {
+		p.hour, p.minute = atoi(m[1]), atoi(m[2])
+		p.endHour, p.endMinute = atoi(m[3]), atoi(m[4])
+		if p.hour > 23 || p.minute > 59 || p.endHour > 23 || p.endMinute > 59 {
+			p.hour, p.endHour = -1, -1
+			return 0
+		}
+		p.add(PartTime, fmt.Sprintf("%02d:%02d", p.hour, p.minute))
+		p.add(PartEnd, fmt.Sprintf("%02d:%02d", p.endHour, p.endMinute))
+		return n + 1
+	}
+
+	hour, minute, m := p.readTime(i+n, n > 0)
+	if m == 0 {
+		return 0
+	}
+	p.hour, p.minute = hour, minute
+	p.add(PartTime, fmt.Sprintf("%02d:%02d", hour, minute))
+	return n + m
+}
+
+// matchDate reads relative days, weekdays and explicit dates.
+func (p *parser) matchDate(i int) int {
+	n := 0
+	switch p.word(i) {
+	case "on", "в", "во", "next", "следующий", "следующую", "следующее":
+		n = 1
+	}
+
+	w := p.word(i + n)
+	today := startOfDay(p.now)
+	var date time.Time
+	switch {
+	case w == "today" || w == "сегодня":
+		date = today
+	case w == "tomorrow" || w == "завтра":
+		date = today.AddDate(0, 0, 1)
+	case w == "послезавтра":
+		date = today.AddDate(0, 0, 2)
+	case w == "day" && p.word(i+n+1) == "after" && p.word(i+n+2) == "tomorrow":
+		date = today.AddDate(0, 0, 2)
+		n += 2
+	default:
+		if weekday, ok := weekdays[w]; ok {
+			days := (int(weekday) - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			date = today.AddDate(0, 0, days)
+			break
+		}
+		var ok bool
+		if date, ok = p.readDate(w); !ok {
+			return 0
+		}
+	}
+
+	p.date = date
+	p.add(PartDate, date.Format("2006-01-02"))
+	return n + 1
+}
+
+// readDate reads 2024-03-08, 08.03.2024 and 08.03, the latter in the
+// nearest year that does not put it in the past.
+func (p *parser) readDate(w string) (time.Time, bool) {
+	loc := p.now.Location()
+	if t, err := time.ParseInLocation("2006-01-02", w, loc); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("02.01.2006", w, loc); err == nil {
+		return t, true
+	}
+	m := shortDateRe.FindStringSubmatch(w)
+	if m == nil {
+		return time.Time{}, false
+	}
+	day, month := atoi(m[1]), atoi(m[2])
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	date := time.Date(p.now.Year(), time.Month(month), day, 0, 0, 0, 0, loc)
+	if date.Day() != day {
+		return time.Time{}, false
+	}
+	if date.Before(startOfDay(p.now)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
+}
+
+// readTime reads a time of day at word i. A bare hour is accepted only
+// after a preposition, so that numbers in titles stay there.
+func (p *parser) readTime(i int, bareHour bool) (hour, minute, n int) {
+	w := p.word(i)
+	if m := clockRe.FindStringSubmatch(w); m != nil {
+		hour, minute = atoi(m[1]), atoi(m[2])
+		n = 1
+		if suffix := p.word(i + 1); m[3] == "" && (suffix == "am" || suffix == "pm") {
+			m[3] = suffix
+			n = 2
+		}
+		if hour, ok := meridiem(hour, m[3]); ok && minute < 60 {
+			return hour, minute, n
+		}
+		return 0, 0, 0
+	}
+	if m := hourRe.FindStringSubmatch(w); m != nil {
+		hour = atoi(m[1])
+		suffix := m[2]
+		n = 1
+		if next := p.word(i + 1); suffix == "" && (next == "am" || next == "pm") {
+			suffix = next
+			n = 2
+		}
+		if suffix == "" && !bareHour {
+			return 0, 0, 0
+		}
+		if hour, ok := meridiem(hour, suffix); ok {
+			return hour, 0, n
+		}
+	}
+	return 0, 0, 0
+}
+
+// readDuration reads "15m", "1h30m", "2 hours", "15 минут", "час" and "полчаса".
+func (p *parser) readDuration(i int) (time.Duration, int) {
+	w := p.word(i)
+	switch {
+	case w == "полчаса" || w == "half-hour":
+		return 30 * time.Minute, 1
+	case unitOf(w) == time.Hour:
+		return time.Hour, 1
+	}
+
+	if d, err := time.ParseDuration(w); err == nil && d > 0 {
+		return d, 1
+	}
+	if m := compactDurationRe.FindStringSubmatch(w); m != nil {
+		if d, ok := scale(atoi(m[1]), unitOf(m[2])); ok {
+			return d, 1
+		}
+	}
+	if number, err := strconv.Atoi(w); err == nil {
+		if d, ok := scale(number, unitOf(p.word(i+1))); ok {
+			return d, 2
+		}
+	}
+	return 0, 0
+}
+
+// scale returns n units, or false when either is not positive or the
+// result does not fit in a time.Duration.
+func scale(n int, unit time.Duration) (time.Duration, bool) {
+	if n <= 0 || unit <= 0 || time.Duration(n) > math.MaxInt64/unit {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+func unitOf(w string) time.Duration {
+	switch w {
+	case "m", "min", "mins", "minute", "minutes", "м", "мин", "минута", "минуты", "минут", "минуту":
+		return time.Minute
+	case "h", "hr", "hrs", "hour", "hours", "ч", "час", "часа", "часов":
+		return time.Hour
+	case "d", "day", "days", "день", "дня", "дней":
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func meridiem(hour int, suffix string) (int, bool) {
+	switch suffix {
+	case "":
+		return hour, hour < 24
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		return hour % 12, true
+	default:
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		return hour%12 + 12, true
+	}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+var (
+	clockRe           = regexp.MustCompile(`^(\d{1,2}):(\d{2})(am|pm)?$`)
+	hourRe            = regexp.MustCompile(`^(\d{1,2})(am|pm)?$`)
+	rangeRe           = regexp.MustCompile(`^(\d{1,2}):(\d{2})[-–](\d{1,2}):(\d{2})$`)
+	shortDateRe       = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})$`)
+	compactDurationRe = regexp.MustCompile(`^(\d+)([a-zа-я]+)$`)
+
+	remindWords = map[string]bool{
+		"remind": true, "reminder": true, "напомнить": true, "напомни": true, "напоминание": true,
+	}
+
+	weekdays = map[string]time.Weekday{
+		"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+		"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+		"понедельник": time.Monday, "вторник": time.Tuesday, "среда": time.Wednesday, "среду": time.Wednesday,
+		"четверг": time.Thursday, "пятница": time.Friday, "пятницу": time.Friday,
+		"суббота": time.Saturday, "субботу": time.Saturday, "воскресенье": time.Sunday,
+	}
+)
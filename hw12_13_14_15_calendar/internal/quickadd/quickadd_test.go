@@ -0,0 +1,125 @@
+package quickadd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// now is a Friday.
+var now = time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	tests := []struct {
+		text      string
+		loc       *time.Location
+		title     string
+		start     time.Time
+		end       time.Time
+		reminders []time.Duration
+	}{
+		{
+			text:      "standup tomorrow 10:00 for 15m remind 5m before",
+			loc:       time.UTC,
+			title:     "standup",
+			start:     time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC),
+			end:       time.Date(2024, 3, 2, 10, 15, 0, 0, time.UTC),
+			reminders: []time.Duration{5 * time.Minute},
+		},
+		{
+			text:  "Lunch with Bob on monday at 1pm",
+			loc:   time.UTC,
+			title: "Lunch with Bob",
+			start: time.Date(2024, 3, 4, 13, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 4, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			text:  "review 14:00-15:30",
+			loc:   time.UTC,
+			title: "review",
+			start: time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 1, 15, 30, 0, 0, time.UTC),
+		},
+		{
+			text:  "release 08.03 at 9 until 11",
+			loc:   time.UTC,
+			title: "release",
+			start: time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 8, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			text:      "Созвон завтра в 10 на полчаса напомнить за 1 час",
+			loc:       moscow,
+			title:     "Созвон",
+			start:     time.Date(2024, 3, 2, 10, 0, 0, 0, moscow),
+			end:       time.Date(2024, 3, 2, 10, 30, 0, 0, moscow),
+			reminders: []time.Duration{time.Hour},
+		},
+		{
+			text:  "планёрка в пятницу в 9:30 на 2 часа",
+			loc:   moscow,
+			title: "планёрка",
+			start: time.Date(2024, 3, 8, 9, 30, 0, 0, moscow),
+			end:   time.Date(2024, 3, 8, 11, 30, 0, 0, moscow),
+		},
+		{
+			text:  "buy 5 apples for team 18:00",
+			loc:   time.UTC,
+			title: "buy 5 apples for team",
+			start: time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 1, 19, 0, 0, 0, time.UTC),
+		},
+		{
+			text:  "a at 10 for 99999999999999h",
+			loc:   time.UTC,
+			title: "a for 99999999999999h",
+			start: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			text:  "a at 10 for 99999999999999 hours",
+			loc:   time.UTC,
+			title: "a for 99999999999999 hours",
+			start: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			result, err := Parse(tt.text, now, tt.loc)
+			require.NoError(t, err)
+			require.Equal(t, tt.title, result.Title)
+			require.True(t, tt.start.Equal(result.StartAt), result.StartAt)
+			require.True(t, tt.end.Equal(result.EndAt), result.EndAt)
+			require.Equal(t, tt.reminders, result.Reminders)
+		})
+	}
+}
+
+func TestParseParts(t *testing.T) {
+	result, err := Parse("standup tomorrow 10:00 for 15m remind 5m before", now, time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, []Part{
+		{Kind: PartTitle, Text: "standup", Value: "standup"},
+		{Kind: PartDate, Text: "tomorrow", Value: "2024-03-02"},
+		{Kind: PartTime, Text: "10:00", Value: "10:00"},
+		{Kind: PartDuration, Text: "for 15m", Value: "15m0s"},
+		{Kind: PartReminder, Text: "remind 5m before", Value: "5m0s"},
+	}, result.Parts)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("standup tomorrow", now, time.UTC)
+	require.ErrorIs(t, err, ErrNoTime)
+
+	_, err = Parse("tomorrow at 10", now, time.UTC)
+	require.ErrorIs(t, err, ErrNoTitle)
+
+	_, err = Parse("standup 25:00", now, time.UTC)
+	require.ErrorIs(t, err, ErrNoTime)
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Config of a token bucket: Rate tokens are added per second up to Burst.
+// Zero Rate disables limiting.
+type Config struct {
+	Rate  float64
+	Burst int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key, usually a user ID.
+type Limiter struct {
+	mu        sync.Mutex
+	config    Config
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+const sweepInterval = time.Minute
+
+func New(config Config) *Limiter {
+	return &Limiter{
+		config:  normalize(config),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func normalize(config Config) Config {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	return config
+}
+
+// Reconfigure changes the limits, existing buckets keep their tokens.
+func (l *Limiter) Reconfigure(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = normalize(config)
+}
+
+// Allow takes a token from the key bucket. When the bucket is empty it returns
+// false and how long to wait until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.take(key, 1)
+}
+
+// Check reports what Allow would without taking a token.
+func (l *Limiter) Check(key string) (bool, time.Duration) {
+	return l.take(key, 0)
+}
+
+func (l *Limiter) take(key string, tokens float64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.Rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	burst := float64(l.config.Burst)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.config.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens -= tokens
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.config.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep forgets buckets that have refilled completely, they are equal to new ones.
+// Must be called with mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(float64(l.config.Burst) / l.config.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	l := New(Config{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	t.Run("burst then retry after", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("alice")
+			require.True(t, ok)
+		}
+		ok, wait := l.Allow("alice")
+		require.False(t, ok)
+		require.Equal(t, 500*time.Millisecond, wait)
+		ok, wait = l.Check("alice")
+		require.False(t, ok)
+		require.Equal(t, 500*time.Millisecond, wait)
+
+		for i := 0; i < 5; i++ {
+			ok, _ = l.Check("bob")
+			require.True(t, ok, "checks take no tokens")
+		}
+		ok, _ = l.Allow("bob")
+		require.True(t, ok, "buckets are per key")
+
+		now = now.Add(wait)
+		ok, _ = l.Allow("alice")
+		require.True(t, ok)
+	})
+
+	t.Run("idle buckets are swept", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		l.Allow("carol")
+		require.Len(t, l.buckets, 1)
+	})
+
+	t.Run("zero rate disables limiting", func(t *testing.T) {
+		l.Reconfigure(Config{})
+		for i := 0; i < 100; i++ {
+			ok, _ := l.Allow("alice")
+			require.True(t, ok)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+// Package reminder fans events out into notifications, one per reminder,
+// and routes them to the channel each reminder targets.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+
+	// DefaultChannel receives the notification of the legacy notifyBefore field.
+	DefaultChannel = ChannelWebhook
+
+	// MaxBefore limits how early a reminder may fire, it bounds the lookahead.
+	MaxBefore = 30 * 24 * time.Hour
+)
+
+var ErrUnknownChannel = errors.New("unknown reminder channel")
+
+// Known reports whether reminders may target the channel.
+func Known(channel string) bool {
+	return channel == ChannelWebhook || channel == ChannelLog
+}
+
+type Notification struct {
+	Event    storage.Event
+	Reminder storage.Reminder
+	// At is when the notification is due: the event start minus Reminder.Before.
+	At time.Time
+}
+
+// Of returns all reminders of the event, notifyBefore included as a
+// reminder on the default channel unless an equal one is listed.
+func Of(event storage.Event) []storage.Reminder {
+	reminders := append([]storage.Reminder{}, event.Reminders...)
+	if event.NotifyBefore > 0 {
+		legacy := storage.Reminder{Before: event.NotifyBefore, Channel: DefaultChannel}
+		for _, r := range reminders {
+			if r == legacy {
+				return reminders
+			}
+		}
+		reminders = append(reminders, legacy)
+	}
+	return reminders
+}
+
+// Due returns notifications of the events due in (from, to], oldest first.
+func Due(events []storage.Event, from, to time.Time) []Notification {
+	var due []Notification
+	for _, event := range events {
+		for _, r := range Of(event) {
+			at := event.StartAt.Add(-r.Before)
+			if at.After(from) && !at.After(to) {
+				due = append(due, Notification{Event: event, Reminder: r, At: at})
+			}
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].At.Before(due[j].At)
+	})
+	return due
+}
+
+// Channel delivers notifications of one kind.
+type Channel interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type ChannelFunc func(ctx context.Context, n Notification) error
+
+func (f ChannelFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// Router sends every notification to the channel of its reminder.
+type Router struct {
+	channels map[string]Channel
+}
+
+func NewRouter(channels map[string]Channel) *Router {
+	return &Router{channels: channels}
+}
+
+// Has reports whether the channel can be targeted by reminders.
+func (r *Router) Has(channel string) bool {
+	_, ok := r.channels[channel]
+	return ok
+}
+
+func (r *Router) Route(ctx context.Context, n Notification) error {
+	channel, ok := r.channels[n.Reminder.Channel]
+	if !ok {
+		metrics.Deliveries.WithLabelValues("unknown", "unroutable").Inc()
+		return fmt.Errorf("%w %q", ErrUnknownChannel, n.Reminder.Channel)
+	}
+	return channel.Notify(ctx, n)
+}
@@ -0,0 +1,91 @@
+package reminder
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func TestOf(t *testing.T) {
+	event := storage.Event{
+		NotifyBefore: 10 * time.Minute,
+		Reminders:    []storage.Reminder{{Before: 24 * time.Hour, Channel: ChannelLog}},
+	}
+	require.Equal(t, []storage.Reminder{
+		{Before: 24 * time.Hour, Channel: ChannelLog},
+		{Before: 10 * time.Minute, Channel: DefaultChannel},
+	}, Of(event))
+
+	event.Reminders = append(event.Reminders, storage.Reminder{Before: 10 * time.Minute, Channel: DefaultChannel})
+	require.Len(t, Of(event), 2, "notifyBefore equal to a listed reminder is not doubled")
+}
+
+func TestDue(t *testing.T) {
+	events := []storage.Event{{
+		ID:      "1",
+		StartAt: start,
+		Reminders: []storage.Reminder{
+			{Before: 24 * time.Hour, Channel: ChannelLog},
+			{Before: 10 * time.Minute, Channel: ChannelWebhook},
+			{Before: 0, Channel: ChannelLog},
+		},
+	}}
+
+	due := Due(events, start.Add(-time.Hour), start)
+	require.Len(t, due, 2)
+	require.Equal(t, start.Add(-10*time.Minute), due[0].At)
+	require.Equal(t, ChannelWebhook, due[0].Reminder.Channel)
+	require.Equal(t, start, due[1].At)
+
+	require.Empty(t, Due(events, start, start.Add(time.Hour)), "the window excludes its start")
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	s := memorystorage.New()
+	require.NoError(t, s.CreateEvent(ctx, storage.Event{
+		ID:      "1",
+		UserID:  "user",
+		StartAt: start,
+		EndAt:   start.Add(time.Hour),
+		Reminders: []storage.Reminder{
+			{Before: 24 * time.Hour, Channel: ChannelLog},
+			{Before: 10 * time.Minute, Channel: ChannelWebhook},
+			{Before: 5 * time.Minute, Channel: "pigeon"},
+		},
+	}))
+	trashed := storage.Event{ID: "2", UserID: "user", StartAt: start, EndAt: start.Add(time.Hour), DeletedAt: start}
+	trashed.Reminders = []storage.Reminder{{Before: time.Minute, Channel: ChannelLog}}
+	require.NoError(t, s.CreateEvent(ctx, trashed))
+
+	sent := map[string][]time.Duration{}
+	record := func(channel string) Channel {
+		return ChannelFunc(func(_ context.Context, n Notification) error {
+			sent[channel] = append(sent[channel], n.Reminder.Before)
+			return nil
+		})
+	}
+	router := NewRouter(map[string]Channel{ChannelLog: record(ChannelLog), ChannelWebhook: record(ChannelWebhook)})
+
+	now := start.Add(-48 * time.Hour)
+	w := NewWorker(logger.NewWithWriter("ERROR", io.Discard), s, router, Config{})
+	w.now = func() time.Time { return now }
+	require.NoError(t, w.Check(ctx), "the first check only sets the watermark")
+
+	for _, step := range []time.Duration{24 * time.Hour, 23 * time.Hour, 50 * time.Minute, 5 * time.Minute, time.Hour} {
+		now = now.Add(step)
+		require.NoError(t, w.Check(ctx))
+	}
+	require.Equal(t, map[string][]time.Duration{
+		ChannelLog:     {24 * time.Hour},
+		ChannelWebhook: {10 * time.Minute},
+	}, sent, "one notification per reminder, unknown channels and trashed events are skipped")
+}
@@ -0,0 +1,116 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const defaultInterval = 10 * time.Second
+
+type Config struct {
+	Interval time.Duration
+}
+
+type Logger interface {
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+type Events interface {
+	ListStarting(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+}
+
+// Worker checks upcoming events every interval and routes notifications
+// that became due since the previous check. Reminders due while the
+// process was down are not sent.
+type Worker struct {
+	logger Logger
+	events Events
+	router *Router
+
+	mu     sync.RWMutex
+	config Config
+	now    func() time.Time
+
+	// checkMu serializes checks, they may also be run on demand.
+	checkMu sync.Mutex
+	last    time.Time
+}
+
+func NewWorker(logger Logger, events Events, router *Router, config Config) *Worker {
+	return &Worker{
+		logger: logger,
+		events: events,
+		router: router,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Reconfigure applies a new interval from the next check.
+func (w *Worker) Reconfigure(config Config) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.config = config
+}
+
+func (w *Worker) currentConfig() Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.config
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	w.checkMu.Lock()
+	w.last = w.now()
+	w.checkMu.Unlock()
+	for {
+		interval := w.currentConfig().Interval
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := w.Check(ctx); err != nil {
+			w.logger.Error("reminders: " + err.Error())
+		}
+	}
+}
+
+// Check routes notifications due since the previous check. Only a failed
+// listing is returned, failed notifications are logged and skipped.
+func (w *Worker) Check(ctx context.Context) error {
+	w.checkMu.Lock()
+	defer w.checkMu.Unlock()
+
+	now := w.now()
+	if w.last.IsZero() {
+		w.last = now
+	}
+
+	events, err := w.events.ListStarting(ctx, w.last, now.Add(MaxBefore))
+	if err != nil {
+		return fmt.Errorf("list upcoming events: %w", err)
+	}
+
+	for _, n := range Due(events, w.last, now) {
+		if err := w.router.Route(ctx, n); err != nil {
+			w.logger.Warn(fmt.Sprintf("reminders: event %s, %s before via %s: %s",
+				n.Event.ID, n.Reminder.Before, n.Reminder.Channel, err))
+		}
+	}
+	w.last = now
+	return nil
+}
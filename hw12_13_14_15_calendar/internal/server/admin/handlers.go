@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type userResponse struct {
+	OrgID   string `json:"orgId,omitempty"`
+	UserID  string `json:"userId"`
+	Events  int    `json:"events"`
+	Trashed int    `json:"trashed"`
+}
+
+type queuesResponse struct {
+	Feed     feedResponse     `json:"feed"`
+	Webhooks webhooksResponse `json:"webhooks"`
+}
+
+type feedResponse struct {
+	LastID uint64 `json:"lastId"`
+}
+
+type webhooksResponse struct {
+	Webhooks int `json:"webhooks"`
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	Pending  int `json:"pending"`
+	Failed   int `json:"failed"`
+}
+
+type jobResponse struct {
+	Job      string `json:"job"`
+	Duration string `json:"duration"`
+	// Purged is set for the cleanup job.
+	Purged *purgedResponse `json:"purged,omitempty"`
+}
+
+type purgedResponse struct {
+	Trashed     int `json:"trashed"`
+	Expired     int `json:"expired"`
+	Attachments int `json:"attachments"`
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// listUsers lists users having events, the org query parameter keeps
+// the users of one organization.
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.targets.Users.CountUserEvents(r.Context())
+	if err != nil {
+		s.writeError(w, fmt.Errorf("count events: %w", err))
+		return
+	}
+
+	query := r.URL.Query()
+	resp := make([]userResponse, 0, len(counts))
+	for _, count := range counts {
+		if query.Has("org") && count.OrgID != query.Get("org") {
+			continue
+		}
+		resp = append(resp, userResponse{
+			OrgID:   count.OrgID,
+			UserID:  count.UserID,
+			Events:  count.Events,
+			Trashed: count.Trashed,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) queues(w http.ResponseWriter, _ *http.Request) {
+	stats := s.targets.Webhooks.QueueStats()
+	s.writeJSON(w, http.StatusOK, queuesResponse{
+		Feed: feedResponse{LastID: s.targets.Feed.LastID()},
+		Webhooks: webhooksResponse{
+			Webhooks: stats.Webhooks,
+			Depth:    stats.Depth,
+			Capacity: stats.Capacity,
+			Pending:  stats.Pending,
+			Failed:   stats.Failed,
+		},
+	})
+}
+
+// runJob runs a background job now and answers when it is done. The job
+// keeps its own schedule.
+func (s *Server) runJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	resp := jobResponse{Job: name}
+	start := time.Now()
+
+	switch name {
+	case "reminders":
+		if err := s.targets.Reminders.Check(r.Context()); err != nil {
+			s.writeError(w, fmt.Errorf("reminders: %w", err))
+			return
+		}
+	case "cleanup":
+		result, err := s.targets.Cleaner.Purge(r.Context())
+		if err != nil {
+			s.writeError(w, fmt.Errorf("cleanup: %w", err))
+			return
+		}
+		resp.Purged = &purgedResponse{
+			Trashed:     result.Trashed,
+			Expired:     result.Expired,
+			Attachments: len(result.Attachments),
+		}
+	case "digests":
+		s.targets.Digests.Check(r.Context())
+	default:
+		s.writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("unknown job %q", name)})
+		return
+	}
+
+	resp.Duration = time.Since(start).String()
+	s.logger.Info(fmt.Sprintf("admin: ran %s job in %s", name, resp.Duration))
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getLogLevel(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, logLevelRequest{Level: s.targets.LogLevel.Level().String()})
+}
+
+// setLogLevel changes the level until the next config reload or restart.
+func (s *Server) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+	if err := s.targets.LogLevel.SetLevel(req.Level); err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	level := s.targets.LogLevel.Level().String()
+	s.logger.Info("admin: log level set to " + level)
+	s.writeJSON(w, http.StatusOK, logLevelRequest{Level: level})
+}
+
+// backup streams an archive of all data, the one `calendar restore` reads.
+// A failure after the first bytes can only be logged, the archive is then
+// missing its closing record and restore rejects it.
+func (s *Server) backup(w http.ResponseWriter, r *http.Request) {
+	name := "calendar-" + time.Now().UTC().Format("20060102T150405Z") + ".jsonl"
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if err := s.targets.Backup(r.Context(), w); err != nil {
+		s.logger.Error("admin: backup failed: " + err.Error())
+		return
+	}
+	s.logger.Info("admin: backup " + name + " sent")
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	s.logger.Error("admin: " + err.Error())
+	s.writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("failed to write response: " + err.Error())
+	}
+}
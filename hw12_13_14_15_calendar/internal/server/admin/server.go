@@ -0,0 +1,146 @@
+// Package admin serves the operator API on its own listener, apart from the
+// public one: user statistics, queue state, on-demand runs of background
+// jobs, the log level, backups and metrics. Every request needs the admin
+// token.
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+var ErrNoToken = errors.New("admin token is required")
+
+type Config struct {
+	Host string
+	Port string
+	// Token is expected as a bearer token in the Authorization header.
+	Token string
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type Users interface {
+	CountUserEvents(ctx context.Context) ([]storage.UserEvents, error)
+}
+
+type LogLevel interface {
+	Level() logger.Level
+	SetLevel(level string) error
+}
+
+type Feed interface {
+	LastID() uint64
+}
+
+type Webhooks interface {
+	QueueStats() webhook.QueueStats
+}
+
+type Reminders interface {
+	Check(ctx context.Context) error
+}
+
+type Cleaner interface {
+	Purge(ctx context.Context) (storage.PurgeResult, error)
+}
+
+type Digests interface {
+	Check(ctx context.Context)
+}
+
+// Targets are what the admin API inspects and runs.
+type Targets struct {
+	Users     Users
+	LogLevel  LogLevel
+	Feed      Feed
+	Webhooks  Webhooks
+	Reminders Reminders
+	Cleaner   Cleaner
+	Digests   Digests
+	// Backup writes an archive of all data to w.
+	Backup func(ctx context.Context, w io.Writer) error
+}
+
+type Server struct {
+	logger  Logger
+	targets Targets
+	token   [sha256.Size]byte
+	server  *http.Server
+}
+
+func NewServer(logger Logger, targets Targets, config Config) (*Server, error) {
+	if config.Token == "" {
+		return nil, ErrNoToken
+	}
+	s := &Server{
+		logger:  logger,
+		targets: targets,
+		token:   sha256.Sum256([]byte(config.Token)),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users", s.listUsers)
+	mux.HandleFunc("GET /queues", s.queues)
+	mux.HandleFunc("POST /jobs/{name}", s.runJob)
+	mux.HandleFunc("GET /loglevel", s.getLogLevel)
+	mux.HandleFunc("PUT /loglevel", s.setLogLevel)
+	mux.HandleFunc("POST /backup", s.backup)
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	s.server = &http.Server{
+		Addr:              net.JoinHostPort(config.Host, config.Port),
+		Handler:           s.authMiddleware(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Start serves requests until Stop. Requests get the values of ctx but not
+// its cancellation, so those drained by Stop, a backup for one, complete.
+func (s *Server) Start(ctx context.Context) error {
+	s.server.BaseContext = func(net.Listener) context.Context { return context.WithoutCancel(ctx) }
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// authMiddleware compares digests of the tokens, so the time taken does
+// not depend on the token length.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(token))
+		if !ok || subtle.ConstantTimeCompare(sum[:], s.token[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid admin token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
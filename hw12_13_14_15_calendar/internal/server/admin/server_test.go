@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "s3cret"
+
+type jobs struct {
+	runs []string
+}
+
+func (j *jobs) Check(context.Context) error {
+	j.runs = append(j.runs, "reminders")
+	return nil
+}
+
+func (j *jobs) Purge(context.Context) (storage.PurgeResult, error) {
+	j.runs = append(j.runs, "cleanup")
+	return storage.PurgeResult{Trashed: 2, Attachments: []string{"a"}}, nil
+}
+
+type digests struct {
+	*jobs
+}
+
+func (d digests) Check(context.Context) {
+	d.runs = append(d.runs, "digests")
+}
+
+func doRequest(t *testing.T, method, url, token, body string) (int, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := memorystorage.New()
+	for i, userID := range []string{"alice", "alice", "bob"} {
+		event := storage.Event{
+			ID:      string(rune('1' + i)),
+			Title:   "event",
+			UserID:  userID,
+			StartAt: start.Add(time.Duration(i) * time.Hour),
+			EndAt:   start.Add(time.Duration(i)*time.Hour + time.Minute),
+		}
+		if i == 2 {
+			event.OrgID = "acme"
+		}
+		require.NoError(t, events.CreateEvent(ctx, event))
+	}
+
+	logg := logger.NewWithWriter("INFO", io.Discard)
+	changes := feed.NewBroker(10, 10)
+	changes.Publish(feed.Change{Type: feed.ChangeCreated, UserID: "alice"})
+	ran := &jobs{}
+	_, err := NewServer(logg, Targets{}, Config{})
+	require.ErrorIs(t, err, ErrNoToken)
+	s, err := NewServer(logg, Targets{
+		Users:     events,
+		LogLevel:  logg,
+		Feed:      changes,
+		Webhooks:  webhook.NewDispatcher(logg, webhook.Config{QueueSize: 5}),
+		Reminders: ran,
+		Cleaner:   ran,
+		Digests:   digests{ran},
+		Backup: func(_ context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, "archive\n")
+			return err
+		},
+	}, Config{Token: token})
+	require.NoError(t, err)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+
+	t.Run("token", func(t *testing.T) {
+		for _, bad := range []string{"", "s3cre", "s3cret2"} {
+			status, _ := doRequest(t, http.MethodGet, ts.URL+"/users", bad, "")
+			require.Equal(t, http.StatusUnauthorized, status, bad)
+		}
+	})
+
+	t.Run("users", func(t *testing.T) {
+		status, data := doRequest(t, http.MethodGet, ts.URL+"/users", token, "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `[
+			{"userId":"alice","events":2,"trashed":0},
+			{"orgId":"acme","userId":"bob","events":1,"trashed":0}
+		]`, string(data))
+
+		_, data = doRequest(t, http.MethodGet, ts.URL+"/users?org=acme", token, "")
+		require.JSONEq(t, `[{"orgId":"acme","userId":"bob","events":1,"trashed":0}]`, string(data))
+	})
+
+	t.Run("queues", func(t *testing.T) {
+		status, data := doRequest(t, http.MethodGet, ts.URL+"/queues", token, "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{
+			"feed":{"lastId":1},
+			"webhooks":{"webhooks":0,"depth":0,"capacity":5,"pending":0,"failed":0}
+		}`, string(data))
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		for _, name := range []string{"reminders", "cleanup", "digests"} {
+			status, data := doRequest(t, http.MethodPost, ts.URL+"/jobs/"+name, token, "")
+			require.Equal(t, http.StatusOK, status, name)
+			var resp jobResponse
+			require.NoError(t, json.Unmarshal(data, &resp))
+			require.Equal(t, name, resp.Job)
+			if name == "cleanup" {
+				require.Equal(t, &purgedResponse{Trashed: 2, Attachments: 1}, resp.Purged)
+			}
+		}
+		require.Equal(t, []string{"reminders", "cleanup", "digests"}, ran.runs)
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/jobs/unknown", token, "")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("log level", func(t *testing.T) {
+		status, data := doRequest(t, http.MethodPut, ts.URL+"/loglevel", token, `{"level":"debug"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"level":"DEBUG"}`, string(data))
+		require.Equal(t, logger.LevelDebug, logg.Level())
+
+		status, _ = doRequest(t, http.MethodPut, ts.URL+"/loglevel", token, `{"level":"loud"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+		_, data = doRequest(t, http.MethodGet, ts.URL+"/loglevel", token, "")
+		require.JSONEq(t, `{"level":"DEBUG"}`, string(data))
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		status, _ := doRequest(t, http.MethodGet, ts.URL+"/metrics", "", "")
+		require.Equal(t, http.StatusUnauthorized, status)
+		status, data := doRequest(t, http.MethodGet, ts.URL+"/metrics", token, "")
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(data), "go_goroutines")
+	})
+
+	t.Run("backup", func(t *testing.T) {
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/backup", token, "")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "archive\n", string(data))
+	})
+}
+
+func TestStop(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s, err := NewServer(logger.NewWithWriter("ERROR", io.Discard), Targets{
+		Backup: func(ctx context.Context, w io.Writer) error {
+			close(started)
+			<-release
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, "archive\n")
+			return err
+		},
+	}, Config{Host: host, Port: port, Token: token})
+	require.NoError(t, err)
+
+	// The lifecycle group cancels the context of Start before calling Stop.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- s.Start(ctx) }()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	type response struct {
+		status int
+		body   []byte
+	}
+	backedUp := make(chan response, 1)
+	go func() {
+		status, body := doRequest(t, http.MethodPost, "http://"+addr+"/backup", token, "")
+		backedUp <- response{status, body}
+	}()
+	<-started
+
+	cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer stopCancel()
+		stopped <- s.Stop(stopCtx)
+	}()
+	close(release)
+
+	resp := <-backedUp
+	require.Equal(t, http.StatusOK, resp.status, "a backup in flight completes during Stop")
+	require.Equal(t, "archive\n", string(resp.body))
+	require.NoError(t, <-stopped)
+	require.NoError(t, <-served)
+}
@@ -0,0 +1,73 @@
+package internalhttp
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type attachmentResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func newAttachmentResponse(att storage.Attachment) attachmentResponse {
+	return attachmentResponse{
+		ID:          att.ID,
+		Name:        att.Name,
+		ContentType: att.ContentType,
+		Size:        att.Size,
+		CreatedAt:   att.CreatedAt,
+	}
+}
+
+// uploadAttachment takes the raw file as the body, its name from the name
+// query parameter and its type from Content-Type.
+func (s *Server) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	att, err := s.app.AddAttachment(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		r.PathValue("id"), r.URL.Query().Get("name"), r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, newAttachmentResponse(att))
+}
+
+func (s *Server) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	att, content, err := s.app.OpenAttachment(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		r.PathValue("id"), r.PathValue("attachmentId"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", att.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(att.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": att.Name}))
+	// Uploaded content must not be run as a page of the API origin.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		s.logger.Error("failed to send attachment: " + err.Error())
+	}
+}
+
+func (s *Server) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	err := s.app.DeleteAttachment(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		r.PathValue("id"), r.PathValue("attachmentId"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package internalhttp
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+)
+
+type availabilityDTO struct {
+	// TimeZone is the IANA zone of working hours, UTC when omitted.
+	TimeZone string `json:"timeZone,omitempty"`
+	// WorkingHours maps weekday names to periods. Null makes every hour a
+	// working one, a weekday missing from the map is a day off.
+	WorkingHours     map[string][]periodDTO `json:"workingHours"`
+	OutOfOffice      []absenceDTO           `json:"outOfOffice,omitempty"`
+	InvitationPolicy string                 `json:"invitationPolicy,omitempty"`
+	Strict           bool                   `json:"strict,omitempty"`
+}
+
+type periodDTO struct {
+	// Start and End are times of day as HH:MM, End may be 24:00.
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type absenceDTO struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+type slotResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (d availabilityDTO) toSettings(orgID, userID string) (availability.Settings, error) {
+	settings := availability.Settings{
+		OrgID:    orgID,
+		UserID:   userID,
+		TimeZone: d.TimeZone,
+		Policy:   availability.Policy(d.InvitationPolicy),
+		Strict:   d.Strict,
+	}
+	if d.WorkingHours != nil {
+		settings.Hours = make(map[time.Weekday][]availability.Period, len(d.WorkingHours))
+		for name, periods := range d.WorkingHours {
+			weekday, err := availability.ParseWeekday(name)
+			if err != nil {
+				return availability.Settings{}, err
+			}
+			for _, p := range periods {
+				start, err := availability.ParseClock(p.Start)
+				if err != nil {
+					return availability.Settings{}, err
+				}
+				end, err := availability.ParseClock(p.End)
+				if err != nil {
+					return availability.Settings{}, err
+				}
+				settings.Hours[weekday] = append(settings.Hours[weekday], availability.Period{Start: start, End: end})
+			}
+		}
+	}
+	for _, a := range d.OutOfOffice {
+		settings.OutOfOffice = append(settings.OutOfOffice,
+			availability.Absence{Start: a.Start, End: a.End, Reason: a.Reason})
+	}
+	return settings, nil
+}
+
+func newAvailabilityResponse(settings availability.Settings) availabilityDTO {
+	resp := availabilityDTO{
+		TimeZone:         settings.TimeZone,
+		InvitationPolicy: string(settings.Policy),
+		Strict:           settings.Strict,
+	}
+	if settings.Hours != nil {
+		resp.WorkingHours = make(map[string][]periodDTO, len(settings.Hours))
+		for weekday, periods := range settings.Hours {
+			name := strings.ToLower(weekday.String())
+			resp.WorkingHours[name] = make([]periodDTO, 0, len(periods))
+			for _, p := range periods {
+				resp.WorkingHours[name] = append(resp.WorkingHours[name],
+					periodDTO{Start: availability.FormatClock(p.Start), End: availability.FormatClock(p.End)})
+			}
+		}
+	}
+	for _, a := range settings.OutOfOffice {
+		resp.OutOfOffice = append(resp.OutOfOffice, absenceDTO{Start: a.Start, End: a.End, Reason: a.Reason})
+	}
+	return resp
+}
+
+func (s *Server) getAvailability(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.app.Availability(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newAvailabilityResponse(settings))
+}
+
+func (s *Server) setAvailability(w http.ResponseWriter, r *http.Request) {
+	var req availabilityDTO
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	settings, err := req.toSettings(auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	saved, err := s.app.SetAvailability(r.Context(), settings)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newAvailabilityResponse(saved))
+}
+
+// freeSlots answers free periods within [from, to) of at least the duration.
+func (s *Server) freeSlots(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := s.parseRange(w, r)
+	if !ok {
+		return
+	}
+	duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "duration must be a duration such as 30m"})
+		return
+	}
+
+	slots, err := s.app.FreeSlots(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), from, to, duration)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]slotResponse, 0, len(slots))
+	for _, slot := range slots {
+		resp = append(resp, slotResponse(slot))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,128 @@
+package internalhttp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+)
+
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+)
+
+type batchRequest struct {
+	// Mode is atomic (the default) or best_effort.
+	Mode       string           `json:"mode"`
+	Operations []batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Op    string        `json:"op"`
+	ID    string        `json:"id,omitempty"`
+	Event *eventRequest `json:"event,omitempty"`
+}
+
+type batchResponse struct {
+	Applied int                 `json:"applied"`
+	Results []batchItemResponse `json:"results"`
+}
+
+type batchItemResponse struct {
+	Index  int            `json:"index"`
+	Op     string         `json:"op"`
+	Status int            `json:"status"`
+	Event  *eventResponse `json:"event,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// batchEvents answers 200 whenever the batch was run, the outcome of every
+// operation is in its own status. A malformed operation rejects the whole
+// request before anything is applied.
+func (s *Server) batchEvents(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	atomic := true
+	switch req.Mode {
+	case "", batchModeAtomic:
+	case batchModeBestEffort:
+		atomic = false
+	default:
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "mode must be atomic or best_effort"})
+		return
+	}
+
+	orgID, userID := auth.OrgID(r.Context()), auth.UserID(r.Context())
+	ops := make([]app.Operation, 0, len(req.Operations))
+	for i, op := range req.Operations {
+		operation, err := op.toOperation(orgID, userID)
+		if err != nil {
+			s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("operations[%d]: %s", i, err)})
+			return
+		}
+		ops = append(ops, operation)
+	}
+
+	results, err := s.app.BatchMutate(r.Context(), orgID, userID, ops, atomic)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchItemResponse, 0, len(results))}
+	for i, result := range results {
+		item := batchItemResponse{Index: i, Op: req.Operations[i].Op}
+		switch {
+		case result.Err != nil:
+			item.Status = errorStatus(result.Err)
+			item.Error = result.Err.Error()
+			if item.Status == http.StatusInternalServerError {
+				s.logger.Error(result.Err.Error())
+				item.Error = http.StatusText(item.Status)
+			}
+		case ops[i].Kind == app.OperationCreate:
+			item.Status = http.StatusCreated
+		case ops[i].Kind == app.OperationDelete:
+			item.Status = http.StatusNoContent
+		default:
+			item.Status = http.StatusOK
+		}
+		if result.Err == nil {
+			resp.Applied++
+			if ops[i].Kind != app.OperationDelete {
+				event := newEventResponse(result.Event)
+				item.Event = &event
+			}
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (op batchOperation) toOperation(orgID, userID string) (app.Operation, error) {
+	operation := app.Operation{Kind: app.OperationKind(op.Op), ID: op.ID}
+	switch operation.Kind {
+	case app.OperationCreate, app.OperationUpdate:
+		if op.Event == nil {
+			return app.Operation{}, fmt.Errorf("%s needs an event", op.Op)
+		}
+		event, err := op.Event.toEvent(orgID, userID)
+		if err != nil {
+			return app.Operation{}, err
+		}
+		operation.Event = event
+	case app.OperationDelete:
+	default:
+		return app.Operation{}, fmt.Errorf("%w %q", app.ErrUnknownOperation, op.Op)
+	}
+
+	if operation.Kind != app.OperationCreate && op.ID == "" {
+		return app.Operation{}, fmt.Errorf("%s needs an id", op.Op)
+	}
+	return operation, nil
+}
@@ -0,0 +1,97 @@
+package internalhttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+)
+
+type digestDTO struct {
+	// Period is daily or weekly.
+	Period string `json:"period"`
+	// At is the local time of day as HH:MM.
+	At string `json:"at"`
+	// Weekday names the day weekly digests are sent on.
+	Weekday  string `json:"weekday,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+	// Format is text or html, text when omitted.
+	Format string `json:"format,omitempty"`
+	// Channel is a reminder channel, webhook when omitted.
+	Channel string `json:"channel,omitempty"`
+}
+
+func (d digestDTO) toSettings(orgID, userID string) (digest.Settings, error) {
+	settings := digest.Settings{
+		OrgID:    orgID,
+		UserID:   userID,
+		Period:   digest.Period(d.Period),
+		TimeZone: d.TimeZone,
+		Format:   digest.Format(d.Format),
+		Channel:  d.Channel,
+	}
+
+	at, err := availability.ParseClock(d.At)
+	if err != nil {
+		return digest.Settings{}, errInvalidDigestTime
+	}
+	settings.At = at
+	if d.Weekday != "" {
+		if settings.Weekday, err = availability.ParseWeekday(d.Weekday); err != nil {
+			return digest.Settings{}, errInvalidDigestWeekday
+		}
+	}
+	return settings, nil
+}
+
+func newDigestResponse(settings digest.Settings) digestDTO {
+	resp := digestDTO{
+		Period:   string(settings.Period),
+		At:       availability.FormatClock(settings.At),
+		TimeZone: settings.TimeZone,
+		Format:   string(settings.Format),
+		Channel:  settings.Channel,
+	}
+	if settings.Period == digest.PeriodWeekly {
+		resp.Weekday = strings.ToLower(settings.Weekday.String())
+	}
+	return resp
+}
+
+func (s *Server) getDigest(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.app.Digest(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newDigestResponse(settings))
+}
+
+func (s *Server) setDigest(w http.ResponseWriter, r *http.Request) {
+	var req digestDTO
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+	settings, err := req.toSettings(auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	saved, err := s.app.SetDigest(r.Context(), settings)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newDigestResponse(saved))
+}
+
+func (s *Server) disableDigest(w http.ResponseWriter, r *http.Request) {
+	if err := s.app.DisableDigest(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context())); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,318 @@
+package internalhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tabular"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	errInvalidNotifyBefore   = errors.New("invalid notifyBefore duration")
+	errInvalidReminderBefore = errors.New("invalid reminder before duration")
+	errInvalidDigestTime     = errors.New("digest time must be in HH:MM format")
+	errInvalidDigestWeekday  = errors.New("unknown digest weekday")
+)
+
+type eventRequest struct {
+	Title        string        `json:"title"`
+	StartAt      time.Time     `json:"startAt"`
+	EndAt        time.Time     `json:"endAt"`
+	Description  string        `json:"description,omitempty"`
+	NotifyBefore string        `json:"notifyBefore,omitempty"`
+	Reminders    []reminderDTO `json:"reminders,omitempty"`
+}
+
+type reminderDTO struct {
+	Before string `json:"before"`
+	// Channel defaults to the webhook channel when omitted.
+	Channel string `json:"channel"`
+}
+
+type eventResponse struct {
+	ID           string        `json:"id"`
+	Title        string        `json:"title"`
+	StartAt      time.Time     `json:"startAt"`
+	EndAt        time.Time     `json:"endAt"`
+	Description  string        `json:"description,omitempty"`
+	UserID       string        `json:"userId"`
+	OrgID        string        `json:"orgId,omitempty"`
+	NotifyBefore string        `json:"notifyBefore,omitempty"`
+	Reminders    []reminderDTO `json:"reminders,omitempty"`
+	DeletedAt    *time.Time    `json:"deletedAt,omitempty"`
+	// Attachments are managed by the attachment endpoints, PUT keeps them.
+	Attachments []attachmentResponse `json:"attachments,omitempty"`
+	// SubscriptionID marks a read-only entry of a subscribed calendar.
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (r eventRequest) toEvent(orgID, userID string) (storage.Event, error) {
+	event := storage.Event{
+		Title:       r.Title,
+		StartAt:     r.StartAt,
+		EndAt:       r.EndAt,
+		Description: r.Description,
+		UserID:      userID,
+		OrgID:       orgID,
+	}
+	if r.NotifyBefore != "" {
+		d, err := time.ParseDuration(r.NotifyBefore)
+		if err != nil {
+			return storage.Event{}, errInvalidNotifyBefore
+		}
+		event.NotifyBefore = d
+	}
+	for _, dto := range r.Reminders {
+		d, err := time.ParseDuration(dto.Before)
+		if err != nil {
+			return storage.Event{}, errInvalidReminderBefore
+		}
+		channel := dto.Channel
+		if channel == "" {
+			channel = reminder.DefaultChannel
+		}
+		event.Reminders = append(event.Reminders, storage.Reminder{Before: d, Channel: channel})
+	}
+	return event, nil
+}
+
+func newEventResponse(event storage.Event) eventResponse {
+	resp := eventResponse{
+		ID:          event.ID,
+		Title:       event.Title,
+		StartAt:     event.StartAt,
+		EndAt:       event.EndAt,
+		Description: event.Description,
+		UserID:      event.UserID,
+		OrgID:       event.OrgID,
+	}
+	if event.NotifyBefore > 0 {
+		resp.NotifyBefore = event.NotifyBefore.String()
+	}
+	for _, r := range event.Reminders {
+		resp.Reminders = append(resp.Reminders, reminderDTO{Before: r.Before.String(), Channel: r.Channel})
+	}
+	for _, att := range event.Attachments {
+		resp.Attachments = append(resp.Attachments, newAttachmentResponse(att))
+	}
+	if event.Deleted() {
+		resp.DeletedAt = &event.DeletedAt
+	}
+	resp.SubscriptionID = event.SubscriptionID
+	return resp
+}
+
+func newEventsResponse(events []storage.Event) []eventResponse {
+	resp := make([]eventResponse, 0, len(events))
+	for _, event := range events {
+		resp = append(resp, newEventResponse(event))
+	}
+	return resp
+}
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	event, ok := s.decodeEvent(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := s.app.CreateEvent(r.Context(), event)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, newEventResponse(created))
+}
+
+func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
+	event, ok := s.decodeEvent(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := s.app.UpdateEvent(r.Context(), r.PathValue("id"), event)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newEventResponse(updated))
+}
+
+func (s *Server) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	err := s.app.DeleteEvent(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getEvent(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("at") {
+		s.eventAt(w, r)
+		return
+	}
+
+	event, err := s.app.GetEvent(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newEventResponse(event))
+}
+
+func (s *Server) listDay(w http.ResponseWriter, r *http.Request) {
+	s.list(w, r, s.app.ListDay)
+}
+
+func (s *Server) listWeek(w http.ResponseWriter, r *http.Request) {
+	s.list(w, r, s.app.ListWeek)
+}
+
+func (s *Server) listMonth(w http.ResponseWriter, r *http.Request) {
+	s.list(w, r, s.app.ListMonth)
+}
+
+type listFunc func(ctx context.Context, orgID, userID string, date time.Time) ([]storage.Event, error)
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, list listFunc) {
+	date, err := time.Parse(dateLayout, r.URL.Query().Get("date"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "date must be in YYYY-MM-DD format"})
+		return
+	}
+
+	events, err := list(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), date)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeEvents(w, r, events)
+}
+
+func (s *Server) decodeEvent(w http.ResponseWriter, r *http.Request) (storage.Event, bool) {
+	var req eventRequest
+	if !s.decodeJSON(w, r, &req) {
+		return storage.Event{}, false
+	}
+
+	event, err := req.toEvent(auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return storage.Event{}, false
+	}
+	return event, true
+}
+
+// decodeJSON reads the request body into v and writes the error response itself.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: "request body too large"})
+		return false
+	}
+	s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+	return false
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		s.logger.Error(err.Error())
+		s.writeJSON(w, status, errorResponse{Error: http.StatusText(status)})
+		return
+	}
+	s.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrEmptyUserID),
+		errors.Is(err, app.ErrEmptyOrgID):
+		return http.StatusUnauthorized
+	case errors.Is(err, tenant.ErrQuotaExceeded):
+		return http.StatusForbidden
+	case errors.Is(err, app.ErrEmptyTitle),
+		errors.Is(err, app.ErrInvalidPeriod),
+		errors.Is(err, app.ErrNegativeNotify),
+		errors.Is(err, app.ErrNothingToRestore),
+		errors.Is(err, app.ErrInvalidReminder),
+		errors.Is(err, app.ErrEmptyBatch),
+		errors.Is(err, app.ErrBatchTooLarge),
+		errors.Is(err, app.ErrUnknownOperation),
+		errors.Is(err, app.ErrInvalidRange),
+		errors.Is(err, app.ErrInvalidTimeZone),
+		errors.Is(err, app.ErrNotUnderstood),
+		errors.Is(err, app.ErrInvalidSlotDuration),
+		errors.Is(err, app.ErrEmptyImport),
+		errors.Is(err, app.ErrImportTooLarge),
+		errors.Is(err, availability.ErrInvalidSettings),
+		errors.Is(err, digest.ErrInvalidSettings):
+		return http.StatusBadRequest
+	case errors.Is(err, tabular.ErrUnknownFormat),
+		errors.Is(err, tabular.ErrUnknownColumn),
+		errors.Is(err, tabular.ErrMissingColumn),
+		errors.Is(err, tabular.ErrMalformed),
+		errors.Is(err, tabular.ErrInvalidValue):
+		return http.StatusBadRequest
+	case errors.Is(err, subscription.ErrInvalidSource),
+		errors.Is(err, subscription.ErrFetch):
+		return http.StatusBadRequest
+	case errors.Is(err, webhook.ErrInvalidURL),
+		errors.Is(err, attachment.ErrInvalidName),
+		errors.Is(err, app.ErrTooManyAttachments):
+		return http.StatusBadRequest
+	case errors.Is(err, attachment.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, attachment.ErrTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, storage.ErrEventNotFound),
+		errors.Is(err, app.ErrForeignEvent),
+		errors.Is(err, storage.ErrRevisionNotFound),
+		errors.Is(err, webhook.ErrWebhookNotFound),
+		errors.Is(err, subscription.ErrSubscriptionNotFound),
+		errors.Is(err, digest.ErrDigestNotFound),
+		errors.Is(err, attachment.ErrAttachmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrDateBusy),
+		errors.Is(err, storage.ErrEventExists),
+		errors.Is(err, availability.ErrOutOfOffice),
+		errors.Is(err, availability.ErrOutsideWorkingHours):
+		return http.StatusConflict
+	case errors.Is(err, app.ErrBatchAborted):
+		return http.StatusFailedDependency
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("failed to write response: " + err.Error())
+	}
+}
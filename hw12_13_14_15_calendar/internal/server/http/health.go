@@ -0,0 +1,31 @@
+package internalhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+)
+
+type Health interface {
+	Ready(ctx context.Context) health.Report
+	Version() health.Version
+}
+
+// healthz only tells that the process serves requests.
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, health.Report{Status: health.StatusOK})
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	report := s.health.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, status, report)
+}
+
+func (s *Server) version(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, s.health.Version())
+}
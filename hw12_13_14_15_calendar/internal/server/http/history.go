@@ -0,0 +1,91 @@
+package internalhttp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type revisionResponse struct {
+	Version int                   `json:"version"`
+	Action  string                `json:"action"`
+	Actor   string                `json:"actor"`
+	At      time.Time             `json:"at"`
+	Changes []fieldChangeResponse `json:"changes"`
+	Event   *eventResponse        `json:"event,omitempty"`
+}
+
+type fieldChangeResponse struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func newRevisionResponse(revision storage.Revision) revisionResponse {
+	resp := revisionResponse{
+		Version: revision.Version,
+		Action:  string(revision.Action),
+		Actor:   revision.Actor,
+		At:      revision.At,
+		Changes: make([]fieldChangeResponse, 0, len(revision.Changes)),
+	}
+	for _, change := range revision.Changes {
+		resp.Changes = append(resp.Changes, fieldChangeResponse(change))
+	}
+	if revision.After != nil {
+		event := newEventResponse(*revision.After)
+		resp.Event = &event
+	}
+	return resp
+}
+
+func (s *Server) eventHistory(w http.ResponseWriter, r *http.Request) {
+	revisions, err := s.app.EventHistory(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]revisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		resp = append(resp, newRevisionResponse(revision))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// eventAt serves GET /events/{id}?at=<RFC3339>, the event as it was then.
+func (s *Server) eventAt(w http.ResponseWriter, r *http.Request) {
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "at must be an RFC 3339 time"})
+		return
+	}
+
+	event, err := s.app.EventAt(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"), at)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newEventResponse(event))
+}
+
+func (s *Server) restoreEvent(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid version"})
+		return
+	}
+
+	event, err := s.app.RestoreEvent(
+		r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"), version,
+	)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newEventResponse(event))
+}
@@ -1,11 +1,129 @@
 package internalhttp
 
 import (
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler { //nolint:unused
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach Flush of the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func loggingMiddleware(logger Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		logger.Info(fmt.Sprintf("%s [%s] %s %s %s %d %d %q",
+			clientIP(r),
+			start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method,
+			r.URL.RequestURI(),
+			r.Proto,
+			rec.status,
+			time.Since(start).Milliseconds(),
+			r.UserAgent(),
+		))
+	})
+}
+
+// authMiddleware puts the authenticated organization and user IDs into the
+// request context. Rejected credentials take a token of the client IP.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := s.auth.Authenticate(r)
+		if err != nil {
+			s.limiter.Allow(clientKey(r))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calendar"`)
+			s.writeJSON(w, http.StatusUnauthorized, errorResponse{Error: err.Error()})
+			return
+		}
+		ctx := auth.WithOrgID(r.Context(), identity.OrgID)
+		next.ServeHTTP(w, r.WithContext(auth.WithUserID(ctx, identity.UserID)))
+	})
+}
+
+// clientLimitMiddleware runs before authentication. A client IP that ran
+// out of tokens with anonymous requests or rejected credentials is turned
+// away without verifying its credentials again.
+func (s *Server) clientLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO
+		if ok, retryAfter := s.limiter.Check(clientKey(r)); !ok {
+			s.tooManyRequests(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+// rateLimitMiddleware limits requests per user of an organization. Anonymous
+// requests are limited per client IP, they are rejected later by the
+// handlers anyway.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r)
+		if userID := auth.UserID(r.Context()); userID != "" {
+			key = userKey(auth.OrgID(r.Context()), userID)
+		}
+
+		if ok, retryAfter := s.limiter.Allow(key); !ok {
+			s.tooManyRequests(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	s.writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded"})
+}
+
+// userKey is the limiter key of a user. The quoted IDs cannot run into each
+// other, nor into a client IP, whatever characters they contain.
+func userKey(orgID, userID string) string {
+	return strconv.Quote(orgID) + strconv.Quote(userID)
+}
+
+// clientKey is the limiter key of anonymous requests and rejected credentials.
+func clientKey(r *http.Request) string {
+	return clientIP(r)
+}
+
+func bodyLimitMiddleware(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
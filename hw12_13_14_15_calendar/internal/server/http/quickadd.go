@@ -0,0 +1,64 @@
+package internalhttp
+
+import (
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/quickadd"
+)
+
+type quickAddRequest struct {
+	Text string `json:"text"`
+	// TZ is an IANA time zone name, UTC when omitted.
+	TZ string `json:"tz,omitempty"`
+	// Commit creates the event, otherwise only the interpretation is returned.
+	Commit bool `json:"commit,omitempty"`
+}
+
+type quickAddResponse struct {
+	Event          eventResponse `json:"event"`
+	Interpretation []partDTO     `json:"interpretation"`
+	Committed      bool          `json:"committed"`
+}
+
+type partDTO struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+func (s *Server) quickAdd(w http.ResponseWriter, r *http.Request) {
+	var req quickAddRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	event, parts, err := s.app.QuickAdd(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		req.Text, req.TZ)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if req.Commit {
+		if event, err = s.app.CreateEvent(r.Context(), event); err != nil {
+			s.writeError(w, err)
+			return
+		}
+		status = http.StatusCreated
+	}
+	s.writeJSON(w, status, quickAddResponse{
+		Event:          newEventResponse(event),
+		Interpretation: newPartsResponse(parts),
+		Committed:      req.Commit,
+	})
+}
+
+func newPartsResponse(parts []quickadd.Part) []partDTO {
+	resp := make([]partDTO, 0, len(parts))
+	for _, part := range parts {
+		resp = append(resp, partDTO{Kind: string(part.Kind), Text: part.Text, Value: part.Value})
+	}
+	return resp
+}
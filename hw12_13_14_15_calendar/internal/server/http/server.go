@@ -2,30 +2,185 @@ package internalhttp
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/quickadd"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-type Server struct { // TODO
+type Server struct {
+	logger  Logger
+	app     Application
+	health  Health
+	auth    Authenticator
+	limiter Limiter
+	server  *http.Server
+	// stopping is done once Stop begins, it ends the event streams.
+	stopping context.Context
+}
+
+type Config struct {
+	Host string
+	Port string
+	// MaxBodySize limits request bodies in bytes, zero means no limit.
+	MaxBodySize int64
+}
+
+// Authenticator resolves the organization and the user of the request. An
+// empty user ID without an error means an anonymous request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (auth.Identity, error)
+}
+
+type Limiter interface {
+	Allow(key string) (ok bool, retryAfter time.Duration)
+	Check(key string) (ok bool, retryAfter time.Duration)
 }
 
-type Logger interface { // TODO
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
 }
 
-type Application interface { // TODO
+type Application interface {
+	CreateEvent(ctx context.Context, event storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, id string, event storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, orgID, userID, id string) error
+	BatchMutate(ctx context.Context, orgID, userID string, ops []app.Operation, atomic bool) ([]app.BatchResult, error)
+	QuickAdd(ctx context.Context, orgID, userID, text, tz string) (storage.Event, []quickadd.Part, error)
+	GetEvent(ctx context.Context, orgID, userID, id string) (storage.Event, error)
+	ListDay(ctx context.Context, orgID, userID string, date time.Time) ([]storage.Event, error)
+	ListWeek(ctx context.Context, orgID, userID string, weekStart time.Time) ([]storage.Event, error)
+	ListMonth(ctx context.Context, orgID, userID string, monthStart time.Time) ([]storage.Event, error)
+	EventHistory(ctx context.Context, orgID, userID, id string) ([]storage.Revision, error)
+	EventAt(ctx context.Context, orgID, userID, id string, at time.Time) (storage.Event, error)
+	ImportEvents(
+		ctx context.Context, orgID, userID string, events []storage.Event, dryRun bool,
+	) ([]app.BatchResult, error)
+	RestoreEvent(ctx context.Context, orgID, userID, id string, version int) (storage.Event, error)
+	ListTrash(ctx context.Context, orgID, userID string) ([]storage.Event, error)
+	RestoreDeleted(ctx context.Context, orgID, userID, id string) (storage.Event, error)
+	Subscribe(ctx context.Context, orgID, userID, name, source string) (subscription.Subscription, error)
+	ListSubscriptions(ctx context.Context, orgID, userID string) ([]subscription.Subscription, error)
+	Unsubscribe(ctx context.Context, orgID, userID, id string) error
+	FreeBusy(ctx context.Context, orgID, userID string, from, to time.Time) ([]app.BusyInterval, error)
+	FreeSlots(
+		ctx context.Context, orgID, userID string, from, to time.Time, duration time.Duration,
+	) ([]app.FreeSlot, error)
+	Availability(ctx context.Context, orgID, userID string) (availability.Settings, error)
+	SetAvailability(ctx context.Context, settings availability.Settings) (availability.Settings, error)
+	Digest(ctx context.Context, orgID, userID string) (digest.Settings, error)
+	SetDigest(ctx context.Context, settings digest.Settings) (digest.Settings, error)
+	DisableDigest(ctx context.Context, orgID, userID string) error
+	WatchEvents(ctx context.Context, orgID, userID string, lastID uint64) ([]feed.Change, <-chan feed.Change, error)
+	AddAttachment(
+		ctx context.Context, orgID, userID, eventID, name, contentType string, body io.Reader,
+	) (storage.Attachment, error)
+	OpenAttachment(ctx context.Context, orgID, userID, eventID, id string) (storage.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, orgID, userID, eventID, id string) error
+	RegisterWebhook(ctx context.Context, orgID, userID, url, secret string) (webhook.Webhook, error)
+	ListWebhooks(ctx context.Context, orgID, userID string) ([]webhook.Webhook, error)
+	DeleteWebhook(ctx context.Context, orgID, userID, id string) error
+	WebhookDeliveries(ctx context.Context, orgID, userID, id string) ([]webhook.Delivery, error)
 }
 
-func NewServer(logger Logger, app Application) *Server {
-	return &Server{}
+func NewServer(
+	logger Logger, app Application, health Health, auth Authenticator, limiter Limiter, config Config,
+) *Server {
+	s := &Server{
+		logger:  logger,
+		app:     app,
+		health:  health,
+		auth:    auth,
+		limiter: limiter,
+	}
+
+	mux := http.NewServeMux()
+	route := func(pattern string, h http.Handler) {
+		h = s.rateLimitMiddleware(h)
+		h = s.authMiddleware(h)
+		h = s.clientLimitMiddleware(h)
+		mux.Handle(pattern, otelhttp.NewHandler(metrics.Middleware(pattern, h), pattern))
+	}
+	handle := func(pattern string, handler http.HandlerFunc) {
+		route(pattern, bodyLimitMiddleware(config.MaxBodySize, handler))
+	}
+	handle("POST /events", s.createEvent)
+	handle("POST /events:batch", s.batchEvents)
+	handle("POST /events:quickadd", s.quickAdd)
+	handle("POST /events:import", s.importEvents)
+	handle("GET /events/day", s.listDay)
+	handle("GET /events/week", s.listWeek)
+	handle("GET /events/month", s.listMonth)
+	handle("GET /events/stream", s.streamEvents)
+	handle("GET /events/{id}", s.getEvent)
+	handle("PUT /events/{id}", s.updateEvent)
+	handle("DELETE /events/{id}", s.deleteEvent)
+	handle("GET /events/{id}/history", s.eventHistory)
+	handle("POST /events/{id}/history/{version}/restore", s.restoreEvent)
+	// Uploads are bounded by the attachment size limit instead of the body one.
+	route("POST /events/{id}/attachments", http.HandlerFunc(s.uploadAttachment))
+	handle("GET /events/{id}/attachments/{attachmentId}", s.downloadAttachment)
+	handle("DELETE /events/{id}/attachments/{attachmentId}", s.deleteAttachment)
+	handle("GET /trash", s.listTrash)
+	handle("POST /trash/{id}/restore", s.restoreDeleted)
+	handle("POST /subscriptions", s.subscribe)
+	handle("GET /subscriptions", s.listSubscriptions)
+	handle("DELETE /subscriptions/{id}", s.unsubscribe)
+	handle("GET /freebusy", s.freeBusy)
+	handle("GET /freebusy/slots", s.freeSlots)
+	handle("GET /availability", s.getAvailability)
+	handle("PUT /availability", s.setAvailability)
+	handle("GET /digest", s.getDigest)
+	handle("PUT /digest", s.setDigest)
+	handle("DELETE /digest", s.disableDigest)
+	handle("POST /webhooks", s.registerWebhook)
+	handle("GET /webhooks", s.listWebhooks)
+	handle("DELETE /webhooks/{id}", s.deleteWebhook)
+	handle("GET /webhooks/{id}/deliveries", s.webhookDeliveries)
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	mux.HandleFunc("GET /version", s.version)
+
+	s.server = &http.Server{
+		Addr:              net.JoinHostPort(config.Host, config.Port),
+		Handler:           loggingMiddleware(logger, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	stopping, stop := context.WithCancel(context.Background())
+	s.stopping = stopping
+	s.server.RegisterOnShutdown(stop)
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
 }
 
+// Start serves requests until Stop. Requests get the values of ctx but not
+// its cancellation, so those drained by Stop are not cut short.
 func (s *Server) Start(ctx context.Context) error {
-	// TODO
-	<-ctx.Done()
+	s.server.BaseContext = func(net.Listener) context.Context { return context.WithoutCancel(ctx) }
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 	return nil
 }
 
 func (s *Server) Stop(ctx context.Context) error {
-	// TODO
-	return nil
+	return s.server.Shutdown(ctx)
 }
-
-// TODO
@@ -0,0 +1,1038 @@
+package internalhttp
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+type testOptions struct {
+	feedBuffer int
+	auth       Authenticator
+	limiter    Limiter
+	config     Config
+	tenants    tenant.Config
+	// attachments limits uploads, the blobs are kept in memory.
+	attachments attachment.Config
+	// calendarsDir holds subscribable .ics files.
+	calendarsDir string
+	// wrap replaces the application, it gets the real one.
+	wrap func(Application) Application
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWith(t, testOptions{})
+}
+
+func newTestServerWith(t *testing.T, opts testOptions) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(newServer(t, opts).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newServer(t *testing.T, opts testOptions) *Server {
+	t.Helper()
+
+	if opts.feedBuffer == 0 {
+		opts.feedBuffer = 100
+	}
+	if opts.auth == nil {
+		opts.auth = auth.Header{}
+	}
+	if opts.limiter == nil {
+		opts.limiter = ratelimit.New(ratelimit.Config{})
+	}
+
+	logg := logger.NewWithWriter("ERROR", io.Discard)
+	webhooks := webhook.NewDispatcher(logg, webhook.Config{LogSize: 10})
+	subscriptions := subscription.NewManager(logg, subscription.Config{Dir: opts.calendarsDir})
+	var calendar Application = app.New(logg, memorystorage.New(), feed.NewBroker(opts.feedBuffer, 10), webhooks,
+		subscriptions, availability.NewStore(), digest.NewStore(),
+		attachment.NewStore(attachment.NewMemory(), opts.attachments), opts.tenants)
+	if opts.wrap != nil {
+		calendar = opts.wrap(calendar)
+	}
+	checker := health.NewChecker(health.Version{Release: "test"}, time.Second)
+	checker.Add("webhooks", webhooks.Ping)
+	return NewServer(logg, calendar, checker, opts.auth, opts.limiter, opts.config)
+}
+
+// countingAuth counts the requests reaching the authenticator.
+type countingAuth struct {
+	Authenticator
+	calls *atomic.Int32
+}
+
+func (a countingAuth) Authenticate(r *http.Request) (auth.Identity, error) {
+	a.calls.Add(1)
+	return a.Authenticator.Authenticate(r)
+}
+
+func doRequest(t *testing.T, method, url, userID, body string) (int, []byte) {
+	t.Helper()
+	return doOrgRequest(t, method, url, "", userID, body)
+}
+
+func doOrgRequest(t *testing.T, method, url, orgID, userID, body string) (int, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(auth.UserIDHeader, userID)
+	if orgID != "" {
+		req.Header.Set(auth.OrgIDHeader, orgID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+const eventBody = `{"title":"standup","startAt":"2024-03-01T10:00:00Z","endAt":"2024-03-01T10:15:00Z"}`
+
+func TestServer(t *testing.T) {
+	t.Run("crud and listings", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+		var created eventResponse
+		require.NoError(t, json.Unmarshal(data, &created))
+		require.NotEmpty(t, created.ID)
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusConflict, status)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/"+created.ID, "other", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		for _, path := range []string{"day", "week", "month"} {
+			status, data = doRequest(t, http.MethodGet, ts.URL+"/events/"+path+"?date=2024-03-01", "user", "")
+			require.Equal(t, http.StatusOK, status)
+			var events []eventResponse
+			require.NoError(t, json.Unmarshal(data, &events))
+			require.Len(t, events, 1, path)
+		}
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/events/"+created.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "", eventBody)
+		require.Equal(t, http.StatusUnauthorized, status)
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user", `{"title":""}`)
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=yesterday", "user", "")
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("reminders", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		body := `{"title":"review","startAt":"2024-03-01T10:00:00Z","endAt":"2024-03-01T11:00:00Z",` +
+			`"reminders":[{"before":"24h","channel":"log"},{"before":"10m","channel":"webhook"}]}`
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user", body)
+		require.Equal(t, http.StatusCreated, status)
+		var created eventResponse
+		require.NoError(t, json.Unmarshal(data, &created))
+		require.Equal(t, []reminderDTO{{Before: "24h0m0s", Channel: "log"}, {Before: "10m0s", Channel: "webhook"}},
+			created.Reminders)
+
+		for _, reminders := range []string{
+			`[{"before":"1h","channel":"email"}]`,
+			`[{"before":"-1h","channel":"log"}]`,
+			`[{"before":"1h","channel":"log"},{"before":"60m","channel":"log"}]`,
+		} {
+			body := `{"title":"review","startAt":"2024-03-01T10:00:00Z","endAt":"2024-03-01T11:00:00Z",` +
+				`"reminders":` + reminders + `}`
+			status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user", body)
+			require.Equal(t, http.StatusBadRequest, status, reminders)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		batch := func(body string) (int, batchResponse) {
+			t.Helper()
+			status, data := doRequest(t, http.MethodPost, ts.URL+"/events:batch", "user", body)
+			var resp batchResponse
+			if status == http.StatusOK {
+				require.NoError(t, json.Unmarshal(data, &resp))
+			}
+			return status, resp
+		}
+		statuses := func(resp batchResponse) []int {
+			var out []int
+			for _, item := range resp.Results {
+				out = append(out, item.Status)
+			}
+			return out
+		}
+		dayEvents := func() []eventResponse {
+			t.Helper()
+			status, data := doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "user", "")
+			require.Equal(t, http.StatusOK, status)
+			var events []eventResponse
+			require.NoError(t, json.Unmarshal(data, &events))
+			return events
+		}
+
+		status, resp := batch(`{"operations":[{"op":"create","event":` + eventBody + `},` +
+			`{"op":"update","id":"missing","event":` + eventBody + `}]}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []int{http.StatusFailedDependency, http.StatusNotFound}, statuses(resp))
+		require.Zero(t, resp.Applied)
+		require.Empty(t, dayEvents(), "an atomic batch is reverted")
+
+		status, resp = batch(`{"mode":"best_effort","operations":[{"op":"create","event":` + eventBody + `},` +
+			`{"op":"create","event":` + eventBody + `},{"op":"delete","id":"missing"}]}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []int{http.StatusCreated, http.StatusConflict, http.StatusNotFound}, statuses(resp))
+		require.Equal(t, 1, resp.Applied)
+		id := resp.Results[0].Event.ID
+
+		status, resp = batch(`{"mode":"atomic","operations":[` +
+			`{"op":"update","id":"` + id + `","event":{"title":"retro","startAt":"2024-03-01T10:00:00Z",` +
+			`"endAt":"2024-03-01T10:15:00Z"}},{"op":"delete","id":"` + id + `"}]}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []int{http.StatusOK, http.StatusNoContent}, statuses(resp))
+		require.Equal(t, "retro", resp.Results[0].Event.Title)
+		require.Empty(t, dayEvents())
+
+		status, data := doRequest(t, http.MethodGet, ts.URL+"/events/"+id+"/history", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(data), `"action":"deleted"`)
+
+		for _, body := range []string{
+			`{"operations":[]}`,
+			`{"mode":"eventually","operations":[{"op":"delete","id":"1"}]}`,
+			`{"operations":[{"op":"merge","id":"1"}]}`,
+			`{"operations":[{"op":"update","event":` + eventBody + `}]}`,
+			`{"operations":[{"op":"create"}]}`,
+			`{"operations":[` + strings.Repeat(`{"op":"delete","id":"1"},`, app.MaxBatchSize) +
+				`{"op":"delete","id":"1"}]}`,
+		} {
+			status, _ = batch(body)
+			require.Equal(t, http.StatusBadRequest, status, body)
+		}
+	})
+
+	t.Run("quick add", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		body := `{"text":"Созвон завтра в 10:00 на 15 минут напомнить за 5 минут","tz":"Europe/Moscow"}`
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events:quickadd", "user", body)
+		require.Equal(t, http.StatusOK, status, string(data))
+		var preview quickAddResponse
+		require.NoError(t, json.Unmarshal(data, &preview))
+		require.False(t, preview.Committed)
+		require.Empty(t, preview.Event.ID)
+		require.Equal(t, "Созвон", preview.Event.Title)
+		require.Equal(t, 15*time.Minute, preview.Event.EndAt.Sub(preview.Event.StartAt))
+		require.Equal(t, "10:00", preview.Event.StartAt.Format("15:04"))
+		require.Equal(t, []reminderDTO{{Before: "5m0s", Channel: "webhook"}}, preview.Event.Reminders)
+		require.Len(t, preview.Interpretation, 5)
+
+		date := preview.Event.StartAt.UTC().Format(dateLayout)
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/events/day?date="+date, "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `[]`, string(data), "preview does not store the event")
+
+		body = `{"text":"Созвон завтра в 10:00 на 15 минут","tz":"Europe/Moscow","commit":true}`
+		status, data = doRequest(t, http.MethodPost, ts.URL+"/events:quickadd", "user", body)
+		require.Equal(t, http.StatusCreated, status, string(data))
+		var created quickAddResponse
+		require.NoError(t, json.Unmarshal(data, &created))
+		require.True(t, created.Committed)
+		require.NotEmpty(t, created.Event.ID)
+
+		for _, body := range []string{
+			`{"text":"standup tomorrow"}`,
+			`{"text":"standup at 10","tz":"Mars/Olympus"}`,
+		} {
+			status, data = doRequest(t, http.MethodPost, ts.URL+"/events:quickadd", "user", body)
+			require.Equal(t, http.StatusBadRequest, status, string(data))
+		}
+	})
+
+	t.Run("subscriptions", func(t *testing.T) {
+		dir := t.TempDir()
+		holidays := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:womens-day\r\n" +
+			"DTSTART;VALUE=DATE:20240308\r\nDTEND;VALUE=DATE:20240309\r\nSUMMARY:Women's Day\r\n" +
+			"END:VEVENT\r\nEND:VCALENDAR\r\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ru.ics"), []byte(holidays), 0o600))
+		ts := newTestServerWith(t, testOptions{calendarsDir: dir})
+
+		for _, source := range []string{"../ru.ics", "/etc/passwd", "missing.ics", "ftp://example.com/h.ics"} {
+			status, _ := doRequest(t, http.MethodPost, ts.URL+"/subscriptions", "user", `{"source":"`+source+`"}`)
+			require.Equal(t, http.StatusBadRequest, status, source)
+		}
+
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/subscriptions", "user",
+			`{"name":"holidays","source":"ru.ics"}`)
+		require.Equal(t, http.StatusCreated, status)
+		var sub subscriptionResponse
+		require.NoError(t, json.Unmarshal(data, &sub))
+		require.NotNil(t, sub.RefreshedAt)
+
+		body := `{"title":"standup","startAt":"2024-03-07T10:00:00Z","endAt":"2024-03-07T10:15:00Z"}`
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user", body)
+		require.Equal(t, http.StatusCreated, status)
+
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/events/week?date=2024-03-04", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var events []eventResponse
+		require.NoError(t, json.Unmarshal(data, &events))
+		require.Len(t, events, 2)
+		require.Equal(t, "standup", events[0].Title)
+		require.Equal(t, "Women's Day", events[1].Title)
+		require.Equal(t, sub.ID, events[1].SubscriptionID)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/"+events[1].ID, "user", "")
+		require.Equal(t, http.StatusNotFound, status, "entries are not stored")
+
+		status, data = doRequest(t, http.MethodGet,
+			ts.URL+"/freebusy?from=2024-03-07T10:05:00Z&to=2024-03-09T12:00:00Z", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var busy []busyResponse
+		require.NoError(t, json.Unmarshal(data, &busy))
+		require.Equal(t, []busyResponse{
+			{Start: time.Date(2024, 3, 7, 10, 5, 0, 0, time.UTC), End: time.Date(2024, 3, 7, 10, 15, 0, 0, time.UTC)},
+			{Start: time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+		}, busy)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/freebusy?from=2024-03-09T00:00:00Z&to=2024-03-08T00:00:00Z",
+			"user", "")
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/subscriptions/"+sub.ID, "other", "")
+		require.Equal(t, http.StatusNotFound, status)
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/subscriptions/"+sub.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/events/week?date=2024-03-04", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.NoError(t, json.Unmarshal(data, &events))
+		require.Len(t, events, 1)
+	})
+
+	t.Run("availability", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, data := doRequest(t, http.MethodGet, ts.URL+"/availability", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"workingHours":null}`, string(data))
+
+		status, _ = doRequest(t, http.MethodPut, ts.URL+"/availability", "user",
+			`{"workingHours":{"caturday":[{"start":"09:00","end":"18:00"}]}}`)
+		require.Equal(t, http.StatusBadRequest, status)
+
+		// Friday 2024-03-01 has a lunch break, the next week is a vacation.
+		settings := `{"timeZone":"UTC","workingHours":{` +
+			`"friday":[{"start":"09:00","end":"13:00"},{"start":"14:00","end":"18:00"}],` +
+			`"monday":[{"start":"09:00","end":"18:00"}]},` +
+			`"outOfOffice":[{"start":"2024-03-04T00:00:00Z","end":"2024-03-09T00:00:00Z","reason":"vacation"}],` +
+			`"invitationPolicy":"decline","strict":true}`
+		status, data = doRequest(t, http.MethodPut, ts.URL+"/availability", "user", settings)
+		require.Equal(t, http.StatusOK, status, string(data))
+		require.JSONEq(t, settings, string(data))
+
+		for body, want := range map[string]int{
+			`{"title":"a","startAt":"2024-03-01T10:00:00Z","endAt":"2024-03-01T11:00:00Z"}`: http.StatusCreated,
+			`{"title":"b","startAt":"2024-03-01T12:30:00Z","endAt":"2024-03-01T13:30:00Z"}`: http.StatusConflict,
+			`{"title":"c","startAt":"2024-03-05T10:00:00Z","endAt":"2024-03-05T11:00:00Z"}`: http.StatusConflict,
+		} {
+			status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user", body)
+			require.Equal(t, want, status, body)
+		}
+
+		status, data = doRequest(t, http.MethodGet,
+			ts.URL+"/freebusy?from=2024-03-01T08:00:00Z&to=2024-03-01T20:00:00Z", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var busy []busyResponse
+		require.NoError(t, json.Unmarshal(data, &busy))
+		at := func(hour int) time.Time { return time.Date(2024, 3, 1, hour, 0, 0, 0, time.UTC) }
+		require.Equal(t, []busyResponse{
+			{Start: at(8), End: at(9)},
+			{Start: at(10), End: at(11)},
+			{Start: at(13), End: at(14)},
+			{Start: at(18), End: at(20)},
+		}, busy)
+
+		status, data = doRequest(t, http.MethodGet,
+			ts.URL+"/freebusy/slots?from=2024-03-01T08:00:00Z&to=2024-03-01T20:00:00Z&duration=90m", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var slots []slotResponse
+		require.NoError(t, json.Unmarshal(data, &slots))
+		require.Equal(t, []slotResponse{{Start: at(11), End: at(13)}, {Start: at(14), End: at(18)}}, slots)
+
+		status, _ = doRequest(t, http.MethodGet,
+			ts.URL+"/freebusy/slots?from=2024-03-01T08:00:00Z&to=2024-03-01T20:00:00Z&duration=0s", "user", "")
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("restore respects availability", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user",
+			`{"title":"a","startAt":"2024-03-01T10:00:00Z","endAt":"2024-03-01T11:00:00Z"}`)
+		require.Equal(t, http.StatusCreated, status)
+		var moved eventResponse
+		require.NoError(t, json.Unmarshal(data, &moved))
+		status, _ = doRequest(t, http.MethodPut, ts.URL+"/events/"+moved.ID, "user",
+			`{"title":"a","startAt":"2024-03-01T14:00:00Z","endAt":"2024-03-01T15:00:00Z"}`)
+		require.Equal(t, http.StatusOK, status)
+
+		status, data = doRequest(t, http.MethodPost, ts.URL+"/events", "user",
+			`{"title":"b","startAt":"2024-03-01T16:00:00Z","endAt":"2024-03-01T17:00:00Z"}`)
+		require.Equal(t, http.StatusCreated, status)
+		var trashed eventResponse
+		require.NoError(t, json.Unmarshal(data, &trashed))
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/events/"+trashed.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		// Mornings became unavailable and the afternoon is off.
+		settings := `{"timeZone":"UTC","workingHours":{"friday":[{"start":"12:00","end":"18:00"}]},` +
+			`"outOfOffice":[{"start":"2024-03-01T15:30:00Z","end":"2024-03-01T18:00:00Z"}],"strict":true}`
+		status, data = doRequest(t, http.MethodPut, ts.URL+"/availability", "user", settings)
+		require.Equal(t, http.StatusOK, status, string(data))
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events/"+moved.ID+"/history/1/restore", "user", "")
+		require.Equal(t, http.StatusConflict, status, "version 1 is outside working hours")
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/trash/"+trashed.ID+"/restore", "user", "")
+		require.Equal(t, http.StatusConflict, status, "the trashed event is out of office")
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, _ := doRequest(t, http.MethodGet, ts.URL+"/digest", "user", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		for _, body := range []string{
+			`{"period":"hourly","at":"08:00"}`,
+			`{"period":"daily","at":"8am"}`,
+			`{"period":"weekly","at":"08:00","weekday":"caturday"}`,
+			`{"period":"daily","at":"08:00","channel":"email"}`,
+		} {
+			status, _ = doRequest(t, http.MethodPut, ts.URL+"/digest", "user", body)
+			require.Equal(t, http.StatusBadRequest, status, body)
+		}
+
+		body := `{"period":"weekly","at":"20:00","weekday":"sunday","timeZone":"Europe/Moscow","format":"html"}`
+		status, data := doRequest(t, http.MethodPut, ts.URL+"/digest", "user", body)
+		require.Equal(t, http.StatusOK, status, string(data))
+		require.JSONEq(t, `{"period":"weekly","at":"20:00","weekday":"sunday","timeZone":"Europe/Moscow",`+
+			`"format":"html","channel":"webhook"}`, string(data))
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/digest", "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/digest", "user", "")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("history and restore", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+		var created eventResponse
+		require.NoError(t, json.Unmarshal(data, &created))
+		eventURL := ts.URL + "/events/" + created.ID
+		beforeUpdate := time.Now().UTC().Format(time.RFC3339Nano)
+
+		updated := strings.Replace(eventBody, "standup", "retro", 1)
+		status, _ = doRequest(t, http.MethodPut, eventURL, "user", updated)
+		require.Equal(t, http.StatusOK, status)
+		status, _ = doRequest(t, http.MethodDelete, eventURL, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		status, data = doRequest(t, http.MethodGet, eventURL+"/history", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var history []revisionResponse
+		require.NoError(t, json.Unmarshal(data, &history))
+		require.Len(t, history, 3)
+		require.Equal(t, []string{"created", "updated", "deleted"},
+			[]string{history[0].Action, history[1].Action, history[2].Action})
+		require.Equal(t, "user", history[1].Actor)
+		require.Equal(t, []fieldChangeResponse{{Field: "title", Before: "standup", After: "retro"}}, history[1].Changes)
+		require.Nil(t, history[2].Event)
+
+		status, _ = doRequest(t, http.MethodGet, eventURL+"/history", "other", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, data = doRequest(t, http.MethodGet, eventURL+"?at="+beforeUpdate, "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var past eventResponse
+		require.NoError(t, json.Unmarshal(data, &past))
+		require.Equal(t, "standup", past.Title)
+
+		status, _ = doRequest(t, http.MethodPost, eventURL+"/history/3/restore", "user", "")
+		require.Equal(t, http.StatusBadRequest, status)
+		status, _ = doRequest(t, http.MethodPost, eventURL+"/history/9/restore", "user", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, data = doRequest(t, http.MethodPost, eventURL+"/history/1/restore", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var restored eventResponse
+		require.NoError(t, json.Unmarshal(data, &restored))
+		require.Equal(t, created, restored)
+
+		status, _ = doRequest(t, http.MethodGet, eventURL, "user", "")
+		require.Equal(t, http.StatusOK, status)
+		status, data = doRequest(t, http.MethodGet, eventURL+"/history", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.NoError(t, json.Unmarshal(data, &history))
+		require.Len(t, history, 4)
+		require.Equal(t, "restored", history[3].Action)
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		ts := newTestServerWith(t, testOptions{
+			config:      Config{MaxBodySize: 256},
+			attachments: attachment.Config{MaxSize: 1024, Types: []string{"text/plain"}},
+		})
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+		var event eventResponse
+		require.NoError(t, json.Unmarshal(data, &event))
+		attachments := ts.URL + "/events/" + event.ID + "/attachments"
+
+		upload := func(userID, contentType, body string) (int, []byte) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+				attachments+"?name=agenda.txt", strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set(auth.UserIDHeader, userID)
+			req.Header.Set("Content-Type", contentType)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return resp.StatusCode, data
+		}
+
+		agenda := strings.Repeat("agenda ", 100)
+		status, data = upload("user", "text/plain", agenda)
+		require.Equal(t, http.StatusCreated, status, "the body limit does not apply to uploads")
+		var att attachmentResponse
+		require.NoError(t, json.Unmarshal(data, &att))
+		require.EqualValues(t, len(agenda), att.Size)
+
+		status, _ = upload("user", "text/plain", strings.Repeat("x", 1025))
+		require.Equal(t, http.StatusRequestEntityTooLarge, status)
+		status, _ = upload("user", "text/html", "<script>alert(1)</script>")
+		require.Equal(t, http.StatusUnsupportedMediaType, status)
+		status, _ = upload("other", "text/plain", agenda)
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, data = doRequest(t, http.MethodPut, ts.URL+"/events/"+event.ID, "user",
+			`{"title":"planning","startAt":"2024-03-01T10:00:00Z","endAt":"2024-03-01T10:15:00Z"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.NoError(t, json.Unmarshal(data, &event))
+		require.Equal(t, []attachmentResponse{att}, event.Attachments, "update keeps attachments")
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, attachments+"/"+att.ID, nil)
+		require.NoError(t, err)
+		req.Header.Set(auth.UserIDHeader, "user")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		data, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, agenda, string(data))
+		require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+		require.Equal(t, `attachment; filename=agenda.txt`, resp.Header.Get("Content-Disposition"))
+
+		status, _ = doRequest(t, http.MethodGet, attachments+"/"+att.ID, "other", "")
+		require.Equal(t, http.StatusNotFound, status)
+		status, _ = doRequest(t, http.MethodDelete, attachments+"/"+att.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+		status, _ = doRequest(t, http.MethodGet, attachments+"/"+att.ID, "user", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/events/"+event.ID+"/history", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, string(data), `"field":"attachments"`)
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		ts := newTestServer(t)
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+
+		status, data := doRequest(t, http.MethodGet,
+			ts.URL+"/events/day?date=2024-03-01&format=csv&columns=title,startAt,duration", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "\ufefftitle,startAt,duration\nstandup,2024-03-01T10:00:00Z,15m0s\n", string(data))
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01&format=xlsx", "user", "")
+		require.Equal(t, http.StatusBadRequest, status)
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01&format=csv&columns=x", "user", "")
+		require.Equal(t, http.StatusBadRequest, status)
+
+		csv := "Тема,Начало,duration\n" +
+			"Retro,2024-03-01 13:00,1h\n" +
+			"Overlap,2024-03-01 13:10,1h\n" +
+			"Morning,2024-03-01 13:05,-\n" +
+			",2024-03-01 16:00,1h\n" +
+			"Demo,2024-03-01 17:00,30m\n"
+		importURL := ts.URL + "/events:import?mapping=Тема:title,Начало:startAt&tz=Europe/Moscow"
+		run := func(url string) importResponse {
+			t.Helper()
+			status, data := doRequest(t, http.MethodPost, url, "user", csv)
+			require.Equal(t, http.StatusOK, status, string(data))
+			var resp importResponse
+			require.NoError(t, json.Unmarshal(data, &resp))
+			return resp
+		}
+		dayEvents := func() []eventResponse {
+			t.Helper()
+			status, data := doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "user", "")
+			require.Equal(t, http.StatusOK, status)
+			var events []eventResponse
+			require.NoError(t, json.Unmarshal(data, &events))
+			return events
+		}
+		statuses := func(resp importResponse) []int {
+			result := make([]int, 0, len(resp.Rows))
+			for _, row := range resp.Rows {
+				result = append(result, row.Status)
+			}
+			return result
+		}
+
+		// The Moscow 13:00 rows overlap the standup at 10:00 UTC.
+		dry := run(importURL + "&dryRun=true")
+		require.True(t, dry.DryRun)
+		require.Equal(t, 1, dry.Imported)
+		require.Equal(t, []int{
+			http.StatusConflict, http.StatusConflict, http.StatusBadRequest, http.StatusBadRequest, http.StatusOK,
+		}, statuses(dry))
+		require.Equal(t, 5, dry.Rows[3].Line)
+		require.Len(t, dayEvents(), 1, "a dry run stores nothing")
+
+		done := run(strings.Replace(importURL, "tz=Europe/Moscow", "tz=UTC", 1))
+		require.Equal(t, []int{
+			http.StatusCreated, http.StatusConflict, http.StatusBadRequest, http.StatusBadRequest, http.StatusCreated,
+		}, statuses(done))
+		require.Equal(t, "Demo", done.Rows[4].Event.Title)
+		require.Len(t, dayEvents(), 3)
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events:import", "user", "title,startAt\n")
+		require.Equal(t, http.StatusBadRequest, status, "missing end column")
+	})
+	t.Run("trash", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+		var created eventResponse
+		require.NoError(t, json.Unmarshal(data, &created))
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/events/"+created.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/"+created.ID, "user", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/trash", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var trash []eventResponse
+		require.NoError(t, json.Unmarshal(data, &trash))
+		require.Len(t, trash, 1)
+		require.NotNil(t, trash[0].DeletedAt)
+
+		status, data = doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status, "trashed events do not take time")
+		var other eventResponse
+		require.NoError(t, json.Unmarshal(data, &other))
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/trash/"+created.ID+"/restore", "user", "")
+		require.Equal(t, http.StatusConflict, status)
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/trash/"+created.ID+"/restore", "other", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/events/"+other.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+		status, data = doRequest(t, http.MethodPost, ts.URL+"/trash/"+created.ID+"/restore", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var restored eventResponse
+		require.NoError(t, json.Unmarshal(data, &restored))
+		require.Equal(t, created, restored)
+	})
+
+	t.Run("event stream", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set(auth.UserIDHeader, "user")
+		req.Header.Set("Last-Event-ID", "0")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "other", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+		body := strings.Replace(eventBody, "2024-03-01", "2024-03-02", 2)
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "user", body)
+		require.Equal(t, http.StatusCreated, status)
+
+		reader := bufio.NewReader(resp.Body)
+		ids := make([]string, 0, 2)
+		for len(ids) < 2 {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if id, ok := strings.CutPrefix(line, "id: "); ok {
+				ids = append(ids, strings.TrimSpace(id))
+			}
+		}
+		require.Equal(t, []string{"1", "3"}, ids)
+	})
+
+	t.Run("expired last event id", func(t *testing.T) {
+		ts := newTestServerWith(t, testOptions{feedBuffer: 1})
+
+		doRequest(t, http.MethodPost, ts.URL+"/events", "user", eventBody)
+		doRequest(t, http.MethodPost, ts.URL+"/events", "user", strings.ReplaceAll(eventBody, "03-01", "03-02"))
+
+		status, _ := doRequest(t, http.MethodGet, ts.URL+"/events/stream?lastEventId=0", "user", "")
+		require.Equal(t, http.StatusGone, status)
+
+		// A client without a last event ID starts with the next change.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set(auth.UserIDHeader, "user")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/webhooks", "user", `{"url":"not a url"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, data := doRequest(t, http.MethodPost, ts.URL+"/webhooks", "user", `{"url":"http://localhost/hook"}`)
+		require.Equal(t, http.StatusCreated, status)
+		var hook webhookResponse
+		require.NoError(t, json.Unmarshal(data, &hook))
+		require.NotEmpty(t, hook.Secret)
+
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/webhooks", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		var hooks []webhookResponse
+		require.NoError(t, json.Unmarshal(data, &hooks))
+		require.Len(t, hooks, 1)
+		require.Empty(t, hooks[0].Secret)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/webhooks/"+hook.ID+"/deliveries", "other", "")
+		require.Equal(t, http.StatusNotFound, status)
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/webhooks/"+hook.ID+"/deliveries", "user", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, "[]", string(data))
+
+		status, _ = doRequest(t, http.MethodDelete, ts.URL+"/webhooks/"+hook.ID, "user", "")
+		require.Equal(t, http.StatusNoContent, status)
+	})
+
+	t.Run("health", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, _ := doRequest(t, http.MethodGet, ts.URL+"/healthz", "", "")
+		require.Equal(t, http.StatusOK, status)
+
+		status, data := doRequest(t, http.MethodGet, ts.URL+"/version", "", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"release":"test","buildDate":"","gitHash":""}`, string(data))
+
+		// The webhook dispatcher is not running in tests.
+		status, data = doRequest(t, http.MethodGet, ts.URL+"/readyz", "", "")
+		require.Equal(t, http.StatusServiceUnavailable, status)
+		var report health.Report
+		require.NoError(t, json.Unmarshal(data, &report))
+		require.Equal(t, webhook.ErrNotRunning.Error(), report.Checks["webhooks"])
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/metrics", "", "")
+		require.Equal(t, http.StatusNotFound, status, "metrics are served on the admin listener")
+	})
+
+	t.Run("tenants", func(t *testing.T) {
+		ts := newTestServerWith(t, testOptions{tenants: tenant.Config{
+			RequireOrg: true,
+			Default:    tenant.Quota{MaxEvents: 1},
+			Orgs:       map[string]tenant.Quota{"globex": {MaxEvents: 2}},
+		}})
+
+		status, _ := doRequest(t, http.MethodPost, ts.URL+"/events", "alice", eventBody)
+		require.Equal(t, http.StatusUnauthorized, status, "organization is required")
+
+		status, data := doOrgRequest(t, http.MethodPost, ts.URL+"/events", "acme", "alice", eventBody)
+		require.Equal(t, http.StatusCreated, status)
+		var created eventResponse
+		require.NoError(t, json.Unmarshal(data, &created))
+		require.Equal(t, "acme", created.OrgID)
+
+		status, _ = doOrgRequest(t, http.MethodGet, ts.URL+"/events/"+created.ID, "globex", "alice", "")
+		require.Equal(t, http.StatusNotFound, status, "same user in another organization")
+		status, _ = doOrgRequest(t, http.MethodDelete, ts.URL+"/events/"+created.ID, "globex", "alice", "")
+		require.Equal(t, http.StatusNotFound, status)
+		status, data = doOrgRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "globex", "alice", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `[]`, string(data))
+
+		later := `{"title":"retro","startAt":"2024-03-01T15:00:00Z","endAt":"2024-03-01T16:00:00Z"}`
+		status, _ = doOrgRequest(t, http.MethodPost, ts.URL+"/events", "acme", "bob", later)
+		require.Equal(t, http.StatusForbidden, status, "quota is shared by the organization")
+
+		status, _ = doOrgRequest(t, http.MethodPost, ts.URL+"/events", "globex", "alice", eventBody)
+		require.Equal(t, http.StatusCreated, status, "busy time is not shared across organizations")
+		status, _ = doOrgRequest(t, http.MethodPost, ts.URL+"/events", "globex", "alice", later)
+		require.Equal(t, http.StatusCreated, status)
+	})
+
+	t.Run("rate and body limits", func(t *testing.T) {
+		ts := newTestServerWith(t, testOptions{
+			limiter: ratelimit.New(ratelimit.Config{Rate: 0.1, Burst: 2}),
+			config:  Config{MaxBodySize: 64},
+		})
+
+		status, _ := doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "alice", "")
+		require.Equal(t, http.StatusOK, status)
+
+		long := `{"title":"` + strings.Repeat("x", 100) + `"}`
+		status, _ = doRequest(t, http.MethodPost, ts.URL+"/events", "alice", long)
+		require.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+			ts.URL+"/events/day?date=2024-03-01", nil)
+		require.NoError(t, err)
+		req.Header.Set(auth.UserIDHeader, "alice")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.Equal(t, "10", resp.Header.Get("Retry-After"))
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "bob", "")
+		require.Equal(t, http.StatusOK, status)
+
+		status, _ = doRequest(t, http.MethodGet, ts.URL+"/healthz", "alice", "")
+		require.Equal(t, http.StatusOK, status, "service endpoints are not limited")
+
+		status, _ = doOrgRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "acme/eu", "carol", "")
+		require.Equal(t, http.StatusOK, status)
+		status, _ = doOrgRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "acme/eu", "carol", "")
+		require.Equal(t, http.StatusOK, status)
+		status, _ = doOrgRequest(t, http.MethodGet, ts.URL+"/events/day?date=2024-03-01", "acme", "eu/carol", "")
+		require.Equal(t, http.StatusOK, status, "users of different organizations do not share a bucket")
+	})
+
+	t.Run("rejected credentials are limited before auth", func(t *testing.T) {
+		jwt, err := auth.NewJWT(auth.JWTConfig{Secret: "top-secret"})
+		require.NoError(t, err)
+		calls := new(atomic.Int32)
+		ts := newTestServerWith(t, testOptions{
+			auth:    countingAuth{Authenticator: jwt, calls: calls},
+			limiter: ratelimit.New(ratelimit.Config{Rate: 0.1, Burst: 2}),
+		})
+
+		call := func(token string) int {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+				ts.URL+"/events/day?date=2024-03-01", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		exp := time.Now().Add(time.Hour).Unix()
+		valid := hs256Token(t, "top-secret", "alice", exp)
+		require.Equal(t, http.StatusOK, call(valid))
+		require.Equal(t, http.StatusOK, call(valid), "valid tokens are limited per user")
+		require.Equal(t, http.StatusUnauthorized, call(hs256Token(t, "guess", "alice", exp)))
+		require.Equal(t, http.StatusUnauthorized, call(hs256Token(t, "guess", "alice", exp)))
+		require.Equal(t, int32(4), calls.Load())
+
+		require.Equal(t, http.StatusTooManyRequests, call(hs256Token(t, "guess", "alice", exp)))
+		require.Equal(t, int32(4), calls.Load(), "the token is not verified")
+	})
+
+	t.Run("jwt auth", func(t *testing.T) {
+		jwt, err := auth.NewJWT(auth.JWTConfig{Secret: "top-secret"})
+		require.NoError(t, err)
+		ts := newTestServerWith(t, testOptions{auth: jwt})
+
+		call := func(token string) int {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+				ts.URL+"/events", strings.NewReader(eventBody))
+			require.NoError(t, err)
+			req.Header.Set(auth.UserIDHeader, "mallory")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var created eventResponse
+			if resp.StatusCode == http.StatusCreated {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+				require.Equal(t, "alice", created.UserID, "user comes from the token, not the header")
+			}
+			return resp.StatusCode
+		}
+
+		exp := time.Now().Add(time.Hour).Unix()
+		require.Equal(t, http.StatusUnauthorized, call(""))
+		require.Equal(t, http.StatusUnauthorized, call(hs256Token(t, "guess", "alice", exp)))
+		require.Equal(t, http.StatusCreated, call(hs256Token(t, "top-secret", "alice", exp)))
+	})
+}
+
+func hs256Token(t *testing.T, secret, sub string, exp int64) string {
+	t.Helper()
+
+	claims, err := json.Marshal(map[string]any{"sub": sub, "exp": exp})
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString(claims)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// slowApp holds day listings until release is closed.
+type slowApp struct {
+	Application
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a slowApp) ListDay(ctx context.Context, orgID, userID string, date time.Time) ([]storage.Event, error) {
+	close(a.started)
+	<-a.release
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Application.ListDay(ctx, orgID, userID, date)
+}
+
+func TestStop(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	slow := slowApp{started: make(chan struct{}), release: make(chan struct{})}
+	s := newServer(t, testOptions{
+		config: Config{Host: host, Port: port},
+		wrap: func(a Application) Application {
+			slow.Application = a
+			return slow
+		},
+	})
+
+	// The lifecycle group cancels the context of Start before calling Stop.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan error, 1)
+	go func() { started <- s.Start(ctx) }()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	streamCtx, streamCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer streamCancel()
+	stream, err := http.NewRequestWithContext(streamCtx, http.MethodGet, "http://"+addr+"/events/stream", nil)
+	require.NoError(t, err)
+	stream.Header.Set(auth.UserIDHeader, "user")
+	streamResp, err := http.DefaultClient.Do(stream)
+	require.NoError(t, err)
+	defer streamResp.Body.Close()
+	require.Equal(t, http.StatusOK, streamResp.StatusCode)
+
+	listed := make(chan int, 1)
+	go func() {
+		status, _ := doRequest(t, http.MethodGet, "http://"+addr+"/events/day?date=2024-03-01", "user", "")
+		listed <- status
+	}()
+	<-slow.started
+
+	cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer stopCancel()
+		stopped <- s.Stop(stopCtx)
+	}()
+
+	_, err = io.ReadAll(streamResp.Body)
+	require.NoError(t, err, "the stream ends when the server stops")
+	close(slow.release)
+	require.Equal(t, http.StatusOK, <-listed, "a request in flight completes during Stop")
+	require.NoError(t, <-stopped)
+	require.NoError(t, <-started)
+}
@@ -0,0 +1,113 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+)
+
+const streamKeepAlive = 15 * time.Second
+
+type changeResponse struct {
+	ID    uint64        `json:"id"`
+	Type  string        `json:"type"`
+	At    time.Time     `json:"at"`
+	Event eventResponse `json:"event"`
+}
+
+// streamEvents pushes changes of user events as Server-Sent Events.
+// Clients resume after reconnect with the standard Last-Event-ID header
+// or the lastEventId query parameter, without either the stream starts
+// with the next change. Streams end when the server stops, so that they
+// do not hold up draining the other requests.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	lastID, err := parseLastEventID(r)
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid last event id"})
+		return
+	}
+
+	backlog, changes, err := s.app.WatchEvents(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), lastID)
+	if errors.Is(err, feed.ErrChangeExpired) {
+		s.writeJSON(w, http.StatusGone, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, change := range backlog {
+		if err := writeChange(w, change); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("failed to flush event stream: " + err.Error())
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.stopping.Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case change, ok := <-changes:
+			if !ok {
+				// Subscriber lagged behind, the client reconnects and resumes.
+				return
+			}
+			if err := writeChange(w, change); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeChange(w http.ResponseWriter, change feed.Change) error {
+	data, err := json.Marshal(changeResponse{
+		ID:    change.ID,
+		Type:  string(change.Type),
+		At:    change.At,
+		Event: newEventResponse(change.Event),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+	return err
+}
+
+func parseLastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	if value == "" {
+		return feed.Latest, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
@@ -0,0 +1,113 @@
+package internalhttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+)
+
+type subscriptionRequest struct {
+	Name string `json:"name,omitempty"`
+	// Source is an http(s) URL or a path inside the server calendars directory.
+	Source string `json:"source"`
+}
+
+type subscriptionResponse struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Source      string     `json:"source"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RefreshedAt *time.Time `json:"refreshedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type busyResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func newSubscriptionResponse(sub subscription.Subscription) subscriptionResponse {
+	resp := subscriptionResponse{
+		ID:        sub.ID,
+		Name:      sub.Name,
+		Source:    sub.Source,
+		CreatedAt: sub.CreatedAt,
+		Error:     sub.Error,
+	}
+	if !sub.RefreshedAt.IsZero() {
+		resp.RefreshedAt = &sub.RefreshedAt
+	}
+	return resp
+}
+
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) {
+	var req subscriptionRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	sub, err := s.app.Subscribe(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), req.Name, req.Source)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, newSubscriptionResponse(sub))
+}
+
+func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := s.app.ListSubscriptions(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]subscriptionResponse, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, newSubscriptionResponse(sub))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	err := s.app.Unsubscribe(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// freeBusy answers busy intervals within [from, to) given in RFC 3339.
+func (s *Server) freeBusy(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := s.parseRange(w, r)
+	if !ok {
+		return
+	}
+
+	intervals, err := s.app.FreeBusy(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), from, to)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]busyResponse, 0, len(intervals))
+	for _, interval := range intervals {
+		resp = append(resp, busyResponse(interval))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// parseRange reads the from and to query parameters and writes the error
+// response itself.
+func (s *Server) parseRange(w http.ResponseWriter, r *http.Request) (from, to time.Time, ok bool) {
+	query := r.URL.Query()
+	from, errFrom := time.Parse(time.RFC3339, query.Get("from"))
+	to, errTo := time.Parse(time.RFC3339, query.Get("to"))
+	if errFrom != nil || errTo != nil {
+		s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "from and to must be in RFC 3339 format"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
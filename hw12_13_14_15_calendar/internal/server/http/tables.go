@@ -0,0 +1,158 @@
+package internalhttp
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tabular"
+)
+
+type importResponse struct {
+	DryRun bool `json:"dryRun"`
+	// Imported counts created events, or on a dry run the ones that would be.
+	Imported int                 `json:"imported"`
+	Failed   int                 `json:"failed"`
+	Ignored  []string            `json:"ignored,omitempty"`
+	Rows     []importRowResponse `json:"rows"`
+}
+
+type importRowResponse struct {
+	Line   int            `json:"line"`
+	Status int            `json:"status"`
+	Event  *eventResponse `json:"event,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// writeEvents answers with the listing of the JSON API unless the format
+// query parameter asks for a csv or json table, columns then lists the
+// columns of the table.
+func (s *Server) writeEvents(w http.ResponseWriter, r *http.Request, events []storage.Event) {
+	query := r.URL.Query()
+	if !query.Has("format") {
+		s.writeJSON(w, http.StatusOK, newEventsResponse(events))
+		return
+	}
+	format, err := tabular.ParseFormat(query.Get("format"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	columns, err := tabular.ParseColumns(query.Get("columns"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == tabular.JSON {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": "events." + string(format)}))
+	w.WriteHeader(http.StatusOK)
+	if err := tabular.Write(w, format, events, columns); err != nil {
+		s.logger.Error("failed to write events: " + err.Error())
+	}
+}
+
+// importEvents creates events from a CSV or JSON table in the body. The
+// format is taken from the format query parameter or the Content-Type,
+// mapping names columns of other headers, tz is the time zone of times
+// without an offset and dryRun=true only reports what would fail. Like a
+// batch it answers 200 with the outcome of every row.
+func (s *Server) importEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := tabular.CSV
+	if name := query.Get("format"); name != "" {
+		var err error
+		if format, err = tabular.ParseFormat(name); err != nil {
+			s.writeError(w, err)
+			return
+		}
+	} else if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		format = tabular.JSON
+	}
+	mapping, err := tabular.ParseMapping(query.Get("mapping"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	loc, err := time.LoadLocation(query.Get("tz"))
+	if err != nil {
+		s.writeError(w, fmt.Errorf("%w: %q", app.ErrInvalidTimeZone, query.Get("tz")))
+		return
+	}
+	dryRun := false
+	if v := query.Get("dryRun"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			s.writeJSON(w, http.StatusBadRequest, errorResponse{Error: "dryRun must be true or false"})
+			return
+		}
+	}
+
+	table, err := tabular.Read(r.Body, format, tabular.Options{Mapping: mapping, Location: loc})
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: "request body too large"})
+		return
+	}
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	// Rows that could not be read are reported, the rest go to the app.
+	events := make([]storage.Event, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		if row.Err == nil {
+			events = append(events, row.Event)
+		}
+	}
+	var results []app.BatchResult
+	if len(events) > 0 {
+		orgID, userID := auth.OrgID(r.Context()), auth.UserID(r.Context())
+		if results, err = s.app.ImportEvents(r.Context(), orgID, userID, events, dryRun); err != nil {
+			s.writeError(w, err)
+			return
+		}
+	} else if len(table.Rows) == 0 {
+		s.writeError(w, app.ErrEmptyImport)
+		return
+	}
+
+	resp := importResponse{DryRun: dryRun, Ignored: table.Ignored, Rows: make([]importRowResponse, 0, len(table.Rows))}
+	for _, row := range table.Rows {
+		item := importRowResponse{Line: row.Line}
+		if row.Err == nil {
+			row.Err, row.Event = results[0].Err, results[0].Event
+			results = results[1:]
+		}
+		if row.Err != nil {
+			item.Status = errorStatus(row.Err)
+			item.Error = row.Err.Error()
+			if item.Status == http.StatusInternalServerError {
+				s.logger.Error(row.Err.Error())
+				item.Error = http.StatusText(item.Status)
+			}
+			resp.Failed++
+		} else {
+			item.Status = http.StatusCreated
+			if dryRun {
+				item.Status = http.StatusOK
+			}
+			event := newEventResponse(row.Event)
+			item.Event = &event
+			resp.Imported++
+		}
+		resp.Rows = append(resp.Rows, item)
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
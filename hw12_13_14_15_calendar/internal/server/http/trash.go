@@ -0,0 +1,26 @@
+package internalhttp
+
+import (
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+)
+
+func (s *Server) listTrash(w http.ResponseWriter, r *http.Request) {
+	events, err := s.app.ListTrash(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeEvents(w, r, events)
+}
+
+func (s *Server) restoreDeleted(w http.ResponseWriter, r *http.Request) {
+	event, err := s.app.RestoreDeleted(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newEventResponse(event))
+}
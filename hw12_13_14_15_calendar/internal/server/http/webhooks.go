@@ -0,0 +1,114 @@
+package internalhttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/auth"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+type webhookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type deliveryResponse struct {
+	ID         string     `json:"id"`
+	EventType  string     `json:"eventType"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	StatusCode int        `json:"statusCode,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+func newWebhookResponse(hook webhook.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        hook.ID,
+		URL:       hook.URL,
+		CreatedAt: hook.CreatedAt,
+	}
+}
+
+func newDeliveryResponse(delivery webhook.Delivery) deliveryResponse {
+	resp := deliveryResponse{
+		ID:         delivery.ID,
+		EventType:  string(delivery.EventType),
+		Status:     string(delivery.Status),
+		Attempts:   delivery.Attempts,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		CreatedAt:  delivery.CreatedAt,
+	}
+	if !delivery.FinishedAt.IsZero() {
+		resp.FinishedAt = &delivery.FinishedAt
+	}
+	return resp
+}
+
+func (s *Server) registerWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	hook, err := s.app.RegisterWebhook(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()),
+		req.URL, req.Secret)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	// The secret is shown once so that the receiver can verify signatures.
+	resp := newWebhookResponse(hook)
+	resp.Secret = hook.Secret
+	s.writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := s.app.ListWebhooks(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]webhookResponse, 0, len(hooks))
+	for _, hook := range hooks {
+		resp = append(resp, newWebhookResponse(hook))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	err := s.app.DeleteWebhook(r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := s.app.WebhookDeliveries(
+		r.Context(), auth.OrgID(r.Context()), auth.UserID(r.Context()), r.PathValue("id"),
+	)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := make([]deliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		resp = append(resp, newDeliveryResponse(delivery))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
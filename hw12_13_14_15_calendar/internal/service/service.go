@@ -0,0 +1,219 @@
+// Package service assembles the calendar from its components the way the
+// calendar binary runs it, so that integration tests start the very same
+// service in process.
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/attachment"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/availability"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/backup"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cache"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/cleanup"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/lifecycle"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/admin"
+	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/subscription"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tenant"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/webhook"
+)
+
+type Config struct {
+	HTTP internalhttp.Config
+	// Admin serves the operator API, it is off while Port is empty.
+	Admin admin.Config
+	// Metrics serves only /metrics without a token, it is off while Port
+	// is empty. The admin listener serves /metrics either way.
+	Metrics       MetricsConfig
+	Shutdown      lifecycle.Config
+	Feed          FeedConfig
+	Webhooks      webhook.Config
+	Health        HealthConfig
+	RateLimit     ratelimit.Config
+	Cleanup       cleanup.Config
+	Reminders     reminder.Config
+	Cache         cache.Config
+	Subscriptions subscription.Config
+	Digests       digest.Config
+	Tenants       tenant.Config
+	Attachments   attachment.Config
+}
+
+type MetricsConfig struct {
+	Host string
+	Port string
+}
+
+type FeedConfig struct {
+	BufferSize       int
+	SubscriberBuffer int
+}
+
+type HealthConfig struct {
+	Timeout time.Duration
+	Version health.Version
+}
+
+// Service runs the servers and workers of the calendar in Group. The
+// components the binary reconfigures on reload are exported, further
+// workers and closers are added to Group before it runs.
+type Service struct {
+	Group *lifecycle.Group
+
+	Storage       *memorystorage.Storage
+	Cache         *cache.CachedStorage
+	Webhooks      *webhook.Dispatcher
+	Limiter       *ratelimit.Limiter
+	Cleaner       *cleanup.Cleaner
+	Reminders     *reminder.Worker
+	Subscriptions *subscription.Manager
+	Digests       *digest.Worker
+	Attachments   *attachment.Store
+
+	availability *availability.Store
+	digests      *digest.Store
+}
+
+func New(
+	logg *logger.Logger, config Config, authenticator internalhttp.Authenticator, blobs attachment.BlobStore,
+) (*Service, error) {
+	memStorage := memorystorage.New()
+	storage := cache.NewCachedStorage(tracing.NewTracedStorage(metrics.NewInstrumentedStorage(memStorage)),
+		config.Cache)
+	changes := feed.NewBroker(config.Feed.BufferSize, config.Feed.SubscriberBuffer)
+	webhooks := webhook.NewDispatcher(logg, config.Webhooks)
+	s := &Service{
+		Storage:       memStorage,
+		Cache:         storage,
+		Webhooks:      webhooks,
+		Limiter:       ratelimit.New(config.RateLimit),
+		Subscriptions: subscription.NewManager(logg, config.Subscriptions),
+		Attachments:   attachment.NewStore(blobs, config.Attachments),
+		availability:  availability.NewStore(),
+		digests:       digest.NewStore(),
+	}
+
+	calendar := app.New(logg, storage, changes, webhooks, s.Subscriptions, s.availability, s.digests,
+		s.Attachments, config.Tenants)
+	s.Cleaner = cleanup.New(logg, storage, s.Attachments, config.Cleanup)
+	s.Reminders = reminder.NewWorker(logg, storage, newReminderRouter(logg, webhooks), config.Reminders)
+	// Digests list events through the app, so that they include subscribed calendars.
+	digests, err := digest.NewWorker(logg, s.digests, calendar, newDigestChannels(logg, webhooks), config.Digests)
+	if err != nil {
+		return nil, fmt.Errorf("set up digests: %w", err)
+	}
+	s.Digests = digests
+
+	checker := health.NewChecker(config.Health.Version, config.Health.Timeout)
+	checker.Add("storage", memStorage.Ping)
+	checker.Add("webhooks", webhooks.Ping)
+
+	s.Group = lifecycle.New(logg, config.Shutdown)
+	s.Group.AddServer("http server",
+		internalhttp.NewServer(logg, calendar, checker, authenticator, s.Limiter, config.HTTP))
+	if config.Admin.Port != "" {
+		adminServer, err := admin.NewServer(logg, admin.Targets{
+			Users:     memStorage,
+			LogLevel:  logg,
+			Feed:      changes,
+			Webhooks:  webhooks,
+			Reminders: s.Reminders,
+			Cleaner:   s.Cleaner,
+			Digests:   s.Digests,
+			Backup: func(ctx context.Context, w io.Writer) error {
+				stats, err := backup.Dump(ctx, w, s.Sources())
+				if err == nil {
+					logg.Info("backup written: " + stats.String())
+				}
+				return err
+			},
+		}, config.Admin)
+		if err != nil {
+			return nil, fmt.Errorf("set up admin api: %w", err)
+		}
+		s.Group.AddServer("admin server", adminServer)
+	}
+	if config.Metrics.Port != "" {
+		s.Group.AddServer("metrics server", metrics.NewServer(config.Metrics.Host, config.Metrics.Port))
+	}
+
+	s.Group.AddWorker("webhooks", func(ctx context.Context) error {
+		return webhooks.Run(ctx, changes)
+	})
+	s.Group.AddWorker("cleanup", Loop(s.Cleaner.Run))
+	s.Group.AddWorker("reminders", Loop(s.Reminders.Run))
+	s.Group.AddWorker("subscriptions", Loop(s.Subscriptions.Run))
+	s.Group.AddWorker("digests", Loop(s.Digests.Run))
+	return s, nil
+}
+
+// Sources are the stores kept in backups and snapshots.
+func (s *Service) Sources() backup.Sources {
+	return backup.Sources{
+		Events:        s.Storage,
+		Webhooks:      s.Webhooks,
+		Subscriptions: s.Subscriptions,
+		Availability:  s.availability,
+		Digests:       s.digests,
+	}
+}
+
+// Sinks fill the stores from a backup or snapshot, before Group runs.
+func (s *Service) Sinks() backup.Sinks {
+	return backup.Sinks{
+		Events:        s.Storage,
+		Webhooks:      s.Webhooks,
+		Subscriptions: s.Subscriptions,
+		Availability:  s.availability,
+		Digests:       s.digests,
+	}
+}
+
+// Loop adapts a worker that runs until ctx is done to the lifecycle group.
+func Loop(run func(ctx context.Context)) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		run(ctx)
+		return nil
+	}
+}
+
+func newReminderRouter(logg *logger.Logger, webhooks *webhook.Dispatcher) *reminder.Router {
+	return reminder.NewRouter(map[string]reminder.Channel{
+		reminder.ChannelWebhook: reminder.ChannelFunc(func(ctx context.Context, n reminder.Notification) error {
+			webhooks.NotificationDue(ctx, n.Event, n.Reminder)
+			return nil
+		}),
+		reminder.ChannelLog: reminder.ChannelFunc(func(_ context.Context, n reminder.Notification) error {
+			logg.Info(fmt.Sprintf("reminder: %q of %s starts at %s",
+				n.Event.Title, n.Event.UserID, n.Event.StartAt.Format(time.RFC3339)))
+			return nil
+		}),
+	})
+}
+
+func newDigestChannels(logg *logger.Logger, webhooks *webhook.Dispatcher) map[string]digest.Channel {
+	return map[string]digest.Channel{
+		reminder.ChannelWebhook: digest.ChannelFunc(func(ctx context.Context, d digest.Digest) error {
+			webhooks.DigestDue(ctx, d)
+			return nil
+		}),
+		reminder.ChannelLog: digest.ChannelFunc(func(_ context.Context, d digest.Digest) error {
+			logg.Info(fmt.Sprintf("digest for %s: %s", d.UserID, d.Subject))
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,12 @@
+package storage
+
+import "errors"
+
+var (
+	ErrEventNotFound = errors.New("event not found")
+	ErrEventExists   = errors.New("event already exists")
+	ErrDateBusy      = errors.New("time is already taken by another event")
+
+	ErrRevisionNotFound   = errors.New("revision not found")
+	ErrRevisionOutOfOrder = errors.New("revision does not follow the event history")
+)
@@ -1,7 +1,61 @@
 package storage
 
+import "time"
+
 type Event struct {
-	ID    string
-	Title string
-	// TODO
+	ID           string
+	Title        string
+	StartAt      time.Time
+	EndAt        time.Time
+	Description  string
+	UserID       string
+	NotifyBefore time.Duration
+	// OrgID is the organization owning the event, empty on deployments
+	// without organizations. Storage queries never cross it.
+	OrgID string
+	// Reminders are extra notifications, each sent over its own channel.
+	Reminders []Reminder
+	// Attachments describe files kept in the blob store under their IDs.
+	Attachments []Attachment
+	// DeletedAt is set while the event is in the trash.
+	DeletedAt time.Time
+	// SubscriptionID is set on read-only entries of a subscribed calendar,
+	// they are listed with user events but never stored.
+	SubscriptionID string
+}
+
+func (e Event) Deleted() bool {
+	return !e.DeletedAt.IsZero()
+}
+
+type Reminder struct {
+	Before  time.Duration
+	Channel string
+}
+
+type Attachment struct {
+	ID          string
+	Name        string
+	ContentType string
+	Size        int64
+	CreatedAt   time.Time
+}
+
+// UserEvents counts the events of a user.
+type UserEvents struct {
+	OrgID  string
+	UserID string
+	// Events counts live events, Trashed the ones in the trash.
+	Events  int
+	Trashed int
+}
+
+type PurgeResult struct {
+	// Trashed counts events removed from the trash.
+	Trashed int
+	// Expired counts events removed as older than the retention period.
+	Expired int
+	// Attachments lists blobs no longer referenced by the purged events or
+	// their history, they are for the caller to delete.
+	Attachments []string
 }
@@ -1,14 +1,350 @@
 package memorystorage
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
 
 type Storage struct {
-	// TODO
-	mu sync.RWMutex //nolint:unused
+	mu      sync.RWMutex
+	events  map[string]storage.Event
+	history map[string][]storage.Revision
 }
 
 func New() *Storage {
-	return &Storage{}
+	return &Storage{
+		events:  make(map[string]storage.Event),
+		history: make(map[string][]storage.Revision),
+	}
+}
+
+// Ping always succeeds, memory is always reachable.
+func (s *Storage) Ping(_ context.Context) error {
+	return nil
+}
+
+func (s *Storage) CreateEvent(_ context.Context, event storage.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[event.ID]; ok {
+		return storage.ErrEventExists
+	}
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+
+	s.events[event.ID] = event
+	return nil
+}
+
+// UpdateEvent replaces the event of the same organization.
+func (s *Storage) UpdateEvent(_ context.Context, id string, event storage.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.events[id]; !ok || stored.OrgID != event.OrgID {
+		return storage.ErrEventNotFound
+	}
+	event.ID = id
+	if s.isBusy(event) {
+		return storage.ErrDateBusy
+	}
+
+	s.events[id] = event
+	return nil
+}
+
+func (s *Storage) DeleteEvent(_ context.Context, orgID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event, ok := s.events[id]; !ok || event.OrgID != orgID {
+		return storage.ErrEventNotFound
+	}
+
+	delete(s.events, id)
+	return nil
 }
 
-// TODO
+// GetEvent returns the event of the organization, events of other
+// organizations are not found.
+func (s *Storage) GetEvent(_ context.Context, orgID, id string) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok || event.OrgID != orgID {
+		return storage.Event{}, storage.ErrEventNotFound
+	}
+	return event, nil
+}
+
+// ListEvents returns live user events intersecting [from, to) ordered by start time.
+func (s *Storage) ListEvents(
+	_ context.Context, orgID, userID string, from, to time.Time,
+) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if event.OrgID == orgID && event.UserID == userID && !event.Deleted() &&
+			event.StartAt.Before(to) && event.EndAt.After(from) {
+			result = append(result, event)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartAt.Before(result[j].StartAt)
+	})
+	return result, nil
+}
+
+// CountEvents returns the number of live events of the organization.
+func (s *Storage) CountEvents(_ context.Context, orgID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, event := range s.events {
+		if event.OrgID == orgID && !event.Deleted() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountUserEvents counts events of every user with any, ordered by
+// organization and user.
+func (s *Storage) CountUserEvents(_ context.Context) ([]storage.UserEvents, error) {
+	s.mu.RLock()
+	counts := make(map[[2]string]*storage.UserEvents)
+	for _, event := range s.events {
+		key := [2]string{event.OrgID, event.UserID}
+		count, ok := counts[key]
+		if !ok {
+			count = &storage.UserEvents{OrgID: event.OrgID, UserID: event.UserID}
+			counts[key] = count
+		}
+		if event.Deleted() {
+			count.Trashed++
+		} else {
+			count.Events++
+		}
+	}
+	s.mu.RUnlock()
+
+	result := make([]storage.UserEvents, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].OrgID != result[j].OrgID {
+			return result[i].OrgID < result[j].OrgID
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result, nil
+}
+
+// ListStarting returns live events of all organizations and users starting in [from, to).
+func (s *Storage) ListStarting(_ context.Context, from, to time.Time) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if !event.Deleted() && !event.StartAt.Before(from) && event.StartAt.Before(to) {
+			result = append(result, event)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartAt.Before(result[j].StartAt)
+	})
+	return result, nil
+}
+
+// ListDeleted returns user events in the trash, recently deleted first.
+func (s *Storage) ListDeleted(_ context.Context, orgID, userID string) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]storage.Event, 0)
+	for _, event := range s.events {
+		if event.OrgID == orgID && event.UserID == userID && event.Deleted() {
+			result = append(result, event)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DeletedAt.After(result[j].DeletedAt)
+	})
+	return result, nil
+}
+
+// PurgeEvents removes events trashed before deletedBefore and live events
+// ended before endedBefore together with their history. Trashed events
+// stay restorable for the whole trash retention however old they are. A
+// zero time skips the check.
+func (s *Storage) PurgeEvents(_ context.Context, deletedBefore, endedBefore time.Time) (storage.PurgeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result storage.PurgeResult
+	for id, event := range s.events {
+		switch {
+		case event.Deleted() && event.DeletedAt.Before(deletedBefore):
+			result.Trashed++
+		case !event.Deleted() && !endedBefore.IsZero() && event.EndAt.Before(endedBefore):
+			result.Expired++
+		default:
+			continue
+		}
+		result.Attachments = append(result.Attachments, s.attachments(event)...)
+		delete(s.events, id)
+		delete(s.history, id)
+	}
+	sort.Strings(result.Attachments)
+	return result, nil
+}
+
+// attachments returns IDs of attachments the event ever had.
+func (s *Storage) attachments(event storage.Event) []string {
+	seen := make(map[string]struct{})
+	add := func(e *storage.Event) {
+		if e == nil {
+			return
+		}
+		for _, a := range e.Attachments {
+			seen[a.ID] = struct{}{}
+		}
+	}
+	add(&event)
+	for _, revision := range s.history[event.ID] {
+		add(revision.Before)
+		add(revision.After)
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// AppendRevision adds the revision to the event history under the next version.
+func (s *Storage) AppendRevision(_ context.Context, revision storage.Revision) (storage.Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.history[revision.EventID]
+	if len(history) > 0 && history[0].OrgID != revision.OrgID {
+		return storage.Revision{}, storage.ErrEventNotFound
+	}
+	revision.Version = len(history) + 1
+	s.history[revision.EventID] = append(history, revision)
+	return revision, nil
+}
+
+// ListRevisions returns the event history from the oldest version, empty
+// for events of other organizations.
+func (s *Storage) ListRevisions(_ context.Context, orgID, eventID string) ([]storage.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.history[eventID]
+	if len(history) == 0 || history[0].OrgID != orgID {
+		return []storage.Revision{}, nil
+	}
+	return append([]storage.Revision{}, history...), nil
+}
+
+// isBusy reports whether event overlaps another live event of the same user.
+// Must be called with mu held.
+func (s *Storage) isBusy(event storage.Event) bool {
+	for _, other := range s.events {
+		if other.ID == event.ID || other.OrgID != event.OrgID || other.UserID != event.UserID ||
+			other.Deleted() || event.Deleted() {
+			continue
+		}
+		if event.StartAt.Before(other.EndAt) && event.EndAt.After(other.StartAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportEvents calls fn for every event, trashed ones included, ordered by ID.
+func (s *Storage) ExportEvents(_ context.Context, fn func(storage.Event) error) error {
+	s.mu.RLock()
+	events := make([]storage.Event, 0, len(s.events))
+	for _, event := range s.events {
+		events = append(events, event)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	for _, event := range events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportRevisions calls fn for every revision ordered by event ID and version.
+func (s *Storage) ExportRevisions(_ context.Context, fn func(storage.Revision) error) error {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.history))
+	for id := range s.history {
+		ids = append(ids, id)
+	}
+	history := make(map[string][]storage.Revision, len(s.history))
+	for id, revisions := range s.history {
+		history[id] = append([]storage.Revision{}, revisions...)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, revision := range history[id] {
+			if err := fn(revision); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ImportEvent stores the event as it is. Overlaps are not checked, the
+// events come from a storage that has checked them already.
+func (s *Storage) ImportEvent(_ context.Context, event storage.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[event.ID]; ok {
+		return storage.ErrEventExists
+	}
+	s.events[event.ID] = event
+	return nil
+}
+
+// ImportRevision appends the revision keeping its version, which must be
+// the next one of the event history.
+func (s *Storage) ImportRevision(_ context.Context, revision storage.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if want := len(s.history[revision.EventID]) + 1; revision.Version != want {
+		return fmt.Errorf("%w: version %d, want %d", storage.ErrRevisionOutOfOrder, revision.Version, want)
+	}
+	s.history[revision.EventID] = append(s.history[revision.EventID], revision)
+	return nil
+}
@@ -1,7 +1,204 @@
 package memorystorage
 
-import "testing"
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(id, userID string, start time.Time) storage.Event {
+	return storage.Event{
+		ID:      id,
+		Title:   "event " + id,
+		StartAt: start,
+		EndAt:   start.Add(time.Hour),
+		UserID:  userID,
+	}
+}
 
 func TestStorage(t *testing.T) {
-	// TODO
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("crud", func(t *testing.T) {
+		s := New()
+
+		event := newEvent("1", "user", start)
+		require.NoError(t, s.CreateEvent(ctx, event))
+
+		got, err := s.GetEvent(ctx, "", "1")
+		require.NoError(t, err)
+		require.Equal(t, event, got)
+
+		event.Title = "updated"
+		require.NoError(t, s.UpdateEvent(ctx, "1", event))
+		got, err = s.GetEvent(ctx, "", "1")
+		require.NoError(t, err)
+		require.Equal(t, "updated", got.Title)
+
+		require.NoError(t, s.DeleteEvent(ctx, "", "1"))
+		_, err = s.GetEvent(ctx, "", "1")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+	})
+
+	t.Run("business errors", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", "user", start)))
+
+		require.ErrorIs(t, s.CreateEvent(ctx, newEvent("1", "user", start.Add(5*time.Hour))), storage.ErrEventExists)
+		require.ErrorIs(t, s.CreateEvent(ctx, newEvent("2", "user", start.Add(30*time.Minute))), storage.ErrDateBusy)
+		require.NoError(t, s.CreateEvent(ctx, newEvent("3", "other", start)))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("4", "user", start.Add(time.Hour))))
+		require.ErrorIs(t, s.UpdateEvent(ctx, "4", newEvent("", "user", start)), storage.ErrDateBusy)
+
+		require.ErrorIs(t, s.UpdateEvent(ctx, "5", newEvent("5", "user", start)), storage.ErrEventNotFound)
+		require.ErrorIs(t, s.DeleteEvent(ctx, "", "5"), storage.ErrEventNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s := New()
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", "user", start.Add(2*time.Hour))))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("1", "user", start)))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("3", "user", start.AddDate(0, 0, 1))))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("4", "other", start)))
+
+		events, err := s.ListEvents(ctx, "", "user", start.Add(-time.Hour), start.Add(12*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, "1", events[0].ID)
+		require.Equal(t, "2", events[1].ID)
+
+		events, err = s.ListEvents(ctx, "", "nobody", start, start.AddDate(0, 1, 0))
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		s := New()
+		trashed := newEvent("1", "user", start)
+		trashed.DeletedAt = start
+		require.NoError(t, s.CreateEvent(ctx, trashed))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", "user", start)), "trashed events do not take time")
+
+		events, err := s.ListEvents(ctx, "", "user", start, start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "2", events[0].ID)
+
+		events, err = s.ListDeleted(ctx, "", "user")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{trashed}, events)
+
+		trashed.DeletedAt = time.Time{}
+		require.ErrorIs(t, s.UpdateEvent(ctx, "1", trashed), storage.ErrDateBusy)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		s := New()
+		retention := start.Add(24 * time.Hour)
+		old := newEvent("1", "user", start)
+		old.DeletedAt = start
+		recent := newEvent("2", "user", start)
+		recent.DeletedAt = start.Add(48 * time.Hour)
+		for _, event := range []storage.Event{
+			old, recent, newEvent("3", "user", start), newEvent("4", "user", start.Add(48*time.Hour)),
+		} {
+			require.NoError(t, s.CreateEvent(ctx, event))
+		}
+
+		result, err := s.PurgeEvents(ctx, retention, retention)
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Trashed)
+		require.Equal(t, 1, result.Expired)
+		_, err = s.GetEvent(ctx, "", "2")
+		require.NoError(t, err, "a recently trashed event stays restorable however old it is")
+		_, err = s.GetEvent(ctx, "", "4")
+		require.NoError(t, err)
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", "user", start)
+
+		created := storage.Revision{EventID: "1", Action: storage.RevisionCreated, After: &event}
+		first, err := s.AppendRevision(ctx, created)
+		require.NoError(t, err)
+		require.Equal(t, 1, first.Version)
+		deleted := storage.Revision{EventID: "1", Action: storage.RevisionDeleted, Before: &event}
+		second, err := s.AppendRevision(ctx, deleted)
+		require.NoError(t, err)
+		require.Equal(t, 2, second.Version)
+
+		revisions, err := s.ListRevisions(ctx, "", "1")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Revision{first, second}, revisions)
+
+		revisions, err = s.ListRevisions(ctx, "", "2")
+		require.NoError(t, err)
+		require.Empty(t, revisions)
+	})
+
+	t.Run("organizations", func(t *testing.T) {
+		s := New()
+		event := newEvent("1", "user", start)
+		event.OrgID = "acme"
+		require.NoError(t, s.CreateEvent(ctx, event))
+		require.NoError(t, s.CreateEvent(ctx, newEvent("2", "user", start)), "same user ID in another organization")
+		_, err := s.AppendRevision(ctx, storage.Revision{EventID: "1", OrgID: "acme", After: &event})
+		require.NoError(t, err)
+
+		_, err = s.GetEvent(ctx, "", "1")
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+		require.ErrorIs(t, s.UpdateEvent(ctx, "1", newEvent("1", "user", start)), storage.ErrEventNotFound)
+		require.ErrorIs(t, s.DeleteEvent(ctx, "", "1"), storage.ErrEventNotFound)
+		_, err = s.AppendRevision(ctx, storage.Revision{EventID: "1", After: &event})
+		require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+		events, err := s.ListEvents(ctx, "acme", "user", start, start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{event}, events)
+		revisions, err := s.ListRevisions(ctx, "", "1")
+		require.NoError(t, err)
+		require.Empty(t, revisions)
+
+		count, err := s.CountEvents(ctx, "acme")
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		trashed := newEvent("3", "user", start.Add(time.Hour))
+		trashed.DeletedAt = start
+		require.NoError(t, s.CreateEvent(ctx, trashed))
+		users, err := s.CountUserEvents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []storage.UserEvents{
+			{UserID: "user", Events: 1, Trashed: 1},
+			{OrgID: "acme", UserID: "user", Events: 1},
+		}, users)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		s := New()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id := strconv.Itoa(i)
+				_ = s.CreateEvent(ctx, newEvent(id, "user"+id, start))
+				_, _ = s.ListEvents(ctx, "", "user"+id, start, start.Add(time.Hour))
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < 100; i++ {
+			_, err := s.GetEvent(ctx, "", strconv.Itoa(i))
+			require.NoError(t, err)
+		}
+	})
 }
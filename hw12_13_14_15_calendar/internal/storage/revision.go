@@ -0,0 +1,32 @@
+package storage
+
+import "time"
+
+type RevisionAction string
+
+const (
+	RevisionCreated  RevisionAction = "created"
+	RevisionUpdated  RevisionAction = "updated"
+	RevisionDeleted  RevisionAction = "deleted"
+	RevisionRestored RevisionAction = "restored"
+)
+
+// Revision is an entry of the append-only event history. Before is nil for
+// created events, After is nil for deleted ones.
+type Revision struct {
+	EventID string
+	OrgID   string
+	Version int
+	Action  RevisionAction
+	Actor   string
+	At      time.Time
+	Before  *Event
+	After   *Event
+	Changes []FieldChange
+}
+
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
@@ -0,0 +1,436 @@
+// Package subscription keeps read-only calendars users subscribe to, such
+// as national holidays. Entries are fetched from an .ics URL or file, cached
+// once per source and refreshed periodically. They are never stored as
+// user events.
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/egress"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultInterval = 6 * time.Hour
+	defaultTimeout  = 30 * time.Second
+	defaultMaxSize  = 1 << 20
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrInvalidSource        = errors.New("source must be an http(s) url or a file in the calendars directory")
+	ErrSubscriptionExists   = errors.New("subscription already exists")
+	ErrFetch                = errors.New("calendar source unreachable")
+	ErrLimitReached         = errors.New("organization has reached its subscription limit")
+)
+
+type Config struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// MaxSize limits a fetched calendar in bytes.
+	MaxSize int64
+	// Dir holds .ics files subscribable by a path relative to it, empty
+	// allows URLs only.
+	Dir string
+	// AllowPrivate lets URLs point to loopback and private addresses.
+	AllowPrivate bool `toml:"allow_private"`
+}
+
+type Logger interface {
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+type Subscription struct {
+	ID        string
+	OrgID     string
+	UserID    string
+	Name      string
+	Source    string
+	CreatedAt time.Time
+	// RefreshedAt and Error describe the last fetch of the source. A failed
+	// refresh keeps the entries of the previous one.
+	RefreshedAt time.Time
+	Error       string
+}
+
+func (s Subscription) ownedBy(orgID, userID string) bool {
+	return s.OrgID == orgID && s.UserID == userID
+}
+
+type cached struct {
+	entries     []ical.Event
+	refreshedAt time.Time
+	err         error
+}
+
+// Manager keeps subscriptions of all users in memory.
+type Manager struct {
+	logger Logger
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	config  Config
+	subs    map[string]Subscription
+	sources map[string]*cached
+}
+
+func NewManager(logger Logger, config Config) *Manager {
+	m := &Manager{
+		logger:  logger,
+		now:     time.Now,
+		config:  config,
+		subs:    make(map[string]Subscription),
+		sources: make(map[string]*cached),
+	}
+	m.client = egress.NewClient(func() bool { return m.currentConfig().AllowPrivate })
+	return m
+}
+
+// Reconfigure applies new settings from the next refresh.
+func (m *Manager) Reconfigure(config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+}
+
+func (m *Manager) currentConfig() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// Subscribe adds a subscription of the user. The source is fetched right
+// away, so that a wrong one is reported to the user. The reason of a failed
+// fetch is only logged: it would tell users about hosts they cannot reach.
+// A positive limit caps subscriptions of the whole organization.
+func (m *Manager) Subscribe(
+	ctx context.Context, orgID, userID, name, source string, limit int,
+) (Subscription, error) {
+	config := m.currentConfig()
+	if !validSource(source, config) {
+		return Subscription{}, ErrInvalidSource
+	}
+
+	if name == "" {
+		name = source
+	}
+	sub := Subscription{
+		ID:        uuid.NewString(),
+		OrgID:     orgID,
+		UserID:    userID,
+		Name:      name,
+		Source:    source,
+		CreatedAt: m.now().UTC(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A cached source stays cached while the lock is held. An unknown one
+	// is fetched with the lock released and stored below, unless another
+	// subscriber stored it meanwhile.
+	_, known := m.sources[source]
+	var entries []ical.Event
+	if !known {
+		m.mu.Unlock()
+		var err error
+		entries, err = m.fetch(ctx, source, config)
+		m.mu.Lock()
+		if err != nil {
+			m.logger.Warn(fmt.Sprintf("subscriptions: fetch %s: %s", source, err))
+			return Subscription{}, ErrFetch
+		}
+	}
+
+	if limit > 0 && m.count(orgID) >= limit {
+		return Subscription{}, ErrLimitReached
+	}
+	m.subs[sub.ID] = sub
+	if _, ok := m.sources[source]; !ok {
+		m.store(source, entries, nil)
+	}
+	return m.withStatus(sub), nil
+}
+
+func (m *Manager) List(_ context.Context, orgID, userID string) ([]Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Subscription, 0)
+	for _, sub := range m.subs {
+		if sub.ownedBy(orgID, userID) {
+			result = append(result, m.withStatus(sub))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// Unsubscribe removes the subscription, the cached source goes with its
+// last subscriber.
+func (m *Manager) Unsubscribe(_ context.Context, orgID, userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subs[id]
+	if !ok || !sub.ownedBy(orgID, userID) {
+		return ErrSubscriptionNotFound
+	}
+	delete(m.subs, id)
+	if !m.subscribed(sub.Source) {
+		delete(m.sources, sub.Source)
+	}
+	return nil
+}
+
+// Entries returns entries of the user subscriptions intersecting [from, to)
+// as read-only events with SubscriptionID set.
+func (m *Manager) Entries(
+	_ context.Context, orgID, userID string, from, to time.Time,
+) ([]storage.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]storage.Event, 0)
+	for _, sub := range m.subs {
+		if !sub.ownedBy(orgID, userID) {
+			continue
+		}
+		source, ok := m.sources[sub.Source]
+		if !ok {
+			continue
+		}
+		for _, entry := range source.entries {
+			if entry.Start.Before(to) && entry.End.After(from) {
+				result = append(result, storage.Event{
+					ID:             sub.ID + "/" + entry.UID,
+					Title:          entry.Summary,
+					StartAt:        entry.Start,
+					EndAt:          entry.End,
+					Description:    entry.Description,
+					OrgID:          orgID,
+					UserID:         userID,
+					SubscriptionID: sub.ID,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// ExportSubscriptions calls fn for every subscription of all users. Fetched
+// entries are not exported, they are fetched again.
+func (m *Manager) ExportSubscriptions(_ context.Context, fn func(Subscription) error) error {
+	m.mu.RLock()
+	subs := make([]Subscription, 0, len(m.subs))
+	for _, sub := range m.subs {
+		subs = append(subs, sub)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	for _, sub := range subs {
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportSubscription adds the subscription keeping its ID. Its source is
+// fetched by the next refresh.
+func (m *Manager) ImportSubscription(_ context.Context, sub Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.subs[sub.ID]; ok {
+		return ErrSubscriptionExists
+	}
+	sub.RefreshedAt, sub.Error = time.Time{}, ""
+	m.subs[sub.ID] = sub
+	return nil
+}
+
+// Run refreshes all sources on start and then every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		m.Refresh(ctx)
+
+		interval := m.currentConfig().Interval
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Refresh fetches every subscribed source once. Failures are logged and
+// recorded on the subscriptions.
+func (m *Manager) Refresh(ctx context.Context) {
+	config := m.currentConfig()
+
+	m.mu.RLock()
+	sources := make(map[string]bool)
+	for _, sub := range m.subs {
+		sources[sub.Source] = true
+	}
+	m.mu.RUnlock()
+
+	for source := range sources {
+		if ctx.Err() != nil {
+			return
+		}
+		entries, err := m.fetch(ctx, source, config)
+		if err != nil {
+			metrics.SubscriptionRefreshes.WithLabelValues("error").Inc()
+			m.logger.Warn(fmt.Sprintf("subscriptions: refresh %s: %s", source, err))
+		} else {
+			metrics.SubscriptionRefreshes.WithLabelValues("ok").Inc()
+		}
+		m.mu.Lock()
+		if m.subscribed(source) {
+			m.store(source, entries, err)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// count returns the number of subscriptions of the organization. Must be
+// called with mu held.
+func (m *Manager) count(orgID string) int {
+	count := 0
+	for _, sub := range m.subs {
+		if sub.OrgID == orgID {
+			count++
+		}
+	}
+	return count
+}
+
+// subscribed reports whether anyone is still subscribed to the source. Must
+// be called with mu held.
+func (m *Manager) subscribed(source string) bool {
+	for _, sub := range m.subs {
+		if sub.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// store caches the result of a fetch. Must be called with mu held.
+func (m *Manager) store(source string, entries []ical.Event, err error) {
+	c, ok := m.sources[source]
+	if !ok {
+		c = &cached{}
+		m.sources[source] = c
+	}
+	c.refreshedAt = m.now().UTC()
+	c.err = err
+	if err == nil {
+		c.entries = entries
+	}
+}
+
+// withStatus fills the fetch status of the subscription. Must be called
+// with mu held.
+func (m *Manager) withStatus(sub Subscription) Subscription {
+	if c, ok := m.sources[sub.Source]; ok {
+		sub.RefreshedAt = c.refreshedAt
+		if c.err != nil {
+			sub.Error = c.err.Error()
+		}
+	}
+	return sub
+}
+
+func (m *Manager) fetch(ctx context.Context, source string, config Config) ([]ical.Event, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := m.open(ctx, source, config)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("calendar is larger than %d bytes", maxSize)
+	}
+	return ical.Decode(bytes.NewReader(data))
+}
+
+func (m *Manager) open(ctx context.Context, source string, config Config) (io.ReadCloser, error) {
+	if !isURL(source) {
+		if !validSource(source, config) {
+			return nil, ErrInvalidSource
+		}
+		return os.Open(filepath.Join(config.Dir, source))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func isURL(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validSource accepts URLs and files that stay inside the calendars directory.
+func validSource(source string, config Config) bool {
+	if isURL(source) {
+		return true
+	}
+	return config.Dir != "" && filepath.IsLocal(source)
+}
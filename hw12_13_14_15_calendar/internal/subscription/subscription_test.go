@@ -0,0 +1,124 @@
+package subscription
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const newYear = "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:new-year\r\n" +
+	"DTSTART;VALUE=DATE:20250101\r\nDTEND;VALUE=DATE:20250102\r\nSUMMARY:New Year\r\n" +
+	"END:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	var fail atomic.Bool
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, newYear)
+	}))
+	defer ts.Close()
+
+	m := NewManager(logger.NewWithWriter("ERROR", io.Discard), Config{AllowPrivate: true})
+	alice, err := m.Subscribe(ctx, "", "alice", "", ts.URL, 0)
+	require.NoError(t, err)
+	require.Equal(t, ts.URL, alice.Name)
+	_, err = m.Subscribe(ctx, "", "bob", "holidays", ts.URL, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, fetches.Load(), "a source is fetched once for all subscribers")
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := m.Entries(ctx, "", "alice", from, from.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "New Year", entries[0].Title)
+	require.Equal(t, alice.ID, entries[0].SubscriptionID)
+
+	fail.Store(true)
+	m.Refresh(ctx)
+	require.EqualValues(t, 2, fetches.Load())
+	entries, err = m.Entries(ctx, "", "alice", from, from.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "a failed refresh keeps the previous entries")
+	subs, err := m.List(ctx, "", "alice")
+	require.NoError(t, err)
+	require.Contains(t, subs[0].Error, "503")
+
+	require.ErrorIs(t, m.Unsubscribe(ctx, "", "bob", alice.ID), ErrSubscriptionNotFound)
+	require.ErrorIs(t, m.Unsubscribe(ctx, "acme", "alice", alice.ID), ErrSubscriptionNotFound)
+	_, err = m.Subscribe(ctx, "", "carol", "", ts.URL, 2)
+	require.ErrorIs(t, err, ErrLimitReached, "alice and bob take the organization limit")
+	_, err = m.Subscribe(ctx, "acme", "carol", "", ts.URL, 2)
+	require.NoError(t, err)
+	require.NoError(t, m.Unsubscribe(ctx, "", "alice", alice.ID))
+	entries, err = m.Entries(ctx, "", "alice", from, from.Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	_, err = m.Subscribe(ctx, "", "alice", "", ts.URL+"/other", 0)
+	require.ErrorIs(t, err, ErrFetch)
+	require.Equal(t, ErrFetch, err, "the upstream error is not passed back")
+	_, err = m.Subscribe(ctx, "", "alice", "", "holidays.ics", 0)
+	require.ErrorIs(t, err, ErrInvalidSource, "files need a calendars directory")
+}
+
+func TestManagerPrivateAddresses(t *testing.T) {
+	ctx := context.Background()
+	var fetches atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		_, _ = io.WriteString(w, newYear)
+	}))
+	defer ts.Close()
+
+	m := NewManager(logger.NewWithWriter("ERROR", io.Discard), Config{})
+	for _, source := range []string{ts.URL, "http://169.254.169.254/latest/meta-data/"} {
+		_, err := m.Subscribe(ctx, "", "alice", "", source, 0)
+		require.Equal(t, ErrFetch, err, source)
+	}
+	require.Zero(t, fetches.Load())
+
+	m.Reconfigure(Config{AllowPrivate: true})
+	_, err := m.Subscribe(ctx, "", "alice", "", ts.URL, 0)
+	require.NoError(t, err)
+}
+
+func TestManagerConcurrentSubscribers(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, newYear)
+	}))
+	defer ts.Close()
+
+	m := NewManager(logger.NewWithWriter("ERROR", io.Discard), Config{AllowPrivate: true})
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				sub, err := m.Subscribe(ctx, "", userID, "", ts.URL, 0)
+				require.NoError(t, err)
+				entries, err := m.Entries(ctx, "", userID, from, from.Add(time.Hour))
+				require.NoError(t, err)
+				require.Len(t, entries, 1, "a source dropped by another subscriber is fetched again")
+				require.NoError(t, m.Unsubscribe(ctx, "", userID, sub.ID))
+			}
+		}()
+	}
+	wg.Wait()
+}
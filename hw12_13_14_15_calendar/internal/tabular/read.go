@@ -0,0 +1,165 @@
+package tabular
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Options struct {
+	// Mapping gives the column of headers not named after one.
+	Mapping map[string]Column
+	// Location is the time zone of times without an offset, UTC when nil.
+	Location *time.Location
+}
+
+// Row is an event read from the table or the reason it could not be read.
+type Row struct {
+	// Line is the CSV line of the row or the position of the JSON object,
+	// both counted from 1.
+	Line  int
+	Event storage.Event
+	Err   error
+}
+
+type Table struct {
+	Rows []Row
+	// Ignored lists headers that are not importable columns.
+	Ignored []string
+}
+
+// Read reads events written by Write or by a spreadsheet. A malformed
+// table fails as a whole, a bad value only fails its row.
+func Read(r io.Reader, format Format, options Options) (Table, error) {
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	if format == JSON {
+		return readJSON(r, options)
+	}
+	return readCSV(r, options)
+}
+
+func readCSV(r io.Reader, options Options) (Table, error) {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(len(bom)); err == nil && string(head) == bom {
+		br.Discard(len(bom))
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return Table{}, fmt.Errorf("%w: no header", ErrMalformed)
+	}
+	if err != nil {
+		return Table{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	var table Table
+	columns := make([]Column, len(header))
+	found := make(map[Column]bool)
+	for i, name := range header {
+		column, ok := resolve(name, options.Mapping)
+		if !ok {
+			table.Ignored = append(table.Ignored, name)
+			continue
+		}
+		columns[i] = column
+		found[column] = true
+	}
+	switch {
+	case !found[ColumnTitle]:
+		return Table{}, fmt.Errorf("%w: %s", ErrMissingColumn, ColumnTitle)
+	case !found[ColumnStartAt]:
+		return Table{}, fmt.Errorf("%w: %s", ErrMissingColumn, ColumnStartAt)
+	case !found[ColumnEndAt] && !found[ColumnDuration]:
+		return Table{}, fmt.Errorf("%w: %s or %s", ErrMissingColumn, ColumnEndAt, ColumnDuration)
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return table, nil
+		}
+		if err != nil {
+			return Table{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		values := make(map[Column]string)
+		for i, field := range record {
+			if i < len(columns) && columns[i] != "" {
+				values[columns[i]] = unescapeFormula(strings.TrimSpace(field))
+			}
+		}
+		if blank(values) {
+			continue
+		}
+		event, err := parseEvent(values, options.Location)
+		table.Rows = append(table.Rows, Row{Line: line, Event: event, Err: err})
+	}
+}
+
+func readJSON(r io.Reader, options Options) (Table, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return Table{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	var table Table
+	for i, object := range objects {
+		row := Row{Line: i + 1}
+		values := make(map[Column]string)
+		for key, raw := range object {
+			column, ok := resolve(key, options.Mapping)
+			if !ok {
+				if !slices.Contains(table.Ignored, key) {
+					table.Ignored = append(table.Ignored, key)
+				}
+				continue
+			}
+			var s *string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				row.Err = fmt.Errorf("%w: %s must be a string", ErrInvalidValue, key)
+				break
+			}
+			if s != nil {
+				values[column] = strings.TrimSpace(*s)
+			}
+		}
+		if row.Err == nil {
+			row.Event, row.Err = parseEvent(values, options.Location)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	slices.Sort(table.Ignored)
+	return table, nil
+}
+
+// resolve finds the importable column of a header, by the mapping first
+// and by name otherwise.
+func resolve(header string, mapping map[string]Column) (Column, bool) {
+	column, ok := mapping[strings.TrimSpace(header)]
+	if !ok {
+		column, ok = lookupColumn(header)
+	}
+	return column, ok && importable[column]
+}
+
+func blank(values map[Column]string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
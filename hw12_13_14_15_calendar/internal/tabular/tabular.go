@@ -0,0 +1,268 @@
+// Package tabular writes events as CSV or JSON tables with chosen columns
+// and reads events back from such tables, for use in spreadsheets.
+package tabular
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/reminder"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+var (
+	ErrUnknownFormat = errors.New("format must be csv or json")
+	ErrUnknownColumn = errors.New("unknown column")
+	ErrMissingColumn = errors.New("required column is missing")
+	ErrMalformed     = errors.New("malformed table")
+	ErrInvalidValue  = errors.New("invalid value")
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CSV, JSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// Column is an event field, named as in the JSON API.
+type Column string
+
+const (
+	ColumnID             Column = "id"
+	ColumnTitle          Column = "title"
+	ColumnStartAt        Column = "startAt"
+	ColumnEndAt          Column = "endAt"
+	ColumnDuration       Column = "duration"
+	ColumnDescription    Column = "description"
+	ColumnUserID         Column = "userId"
+	ColumnOrgID          Column = "orgId"
+	ColumnNotifyBefore   Column = "notifyBefore"
+	ColumnReminders      Column = "reminders"
+	ColumnAttachments    Column = "attachments"
+	ColumnDeletedAt      Column = "deletedAt"
+	ColumnSubscriptionID Column = "subscriptionId"
+)
+
+var allColumns = []Column{
+	ColumnID, ColumnTitle, ColumnStartAt, ColumnEndAt, ColumnDuration, ColumnDescription, ColumnUserID,
+	ColumnOrgID, ColumnNotifyBefore, ColumnReminders, ColumnAttachments, ColumnDeletedAt, ColumnSubscriptionID,
+}
+
+// DefaultColumns are written when no columns are chosen.
+var DefaultColumns = []Column{ColumnID, ColumnTitle, ColumnStartAt, ColumnEndAt, ColumnDescription}
+
+// importable columns set event fields on import, the rest are ignored:
+// IDs and ownership come from the importing user.
+var importable = map[Column]bool{
+	ColumnTitle:        true,
+	ColumnStartAt:      true,
+	ColumnEndAt:        true,
+	ColumnDuration:     true,
+	ColumnDescription:  true,
+	ColumnNotifyBefore: true,
+	ColumnReminders:    true,
+}
+
+// ParseColumns reads a comma separated list of columns, DefaultColumns
+// when it is empty. Names are matched ignoring case, spaces, - and _.
+func ParseColumns(s string) ([]Column, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultColumns, nil
+	}
+
+	var columns []Column
+	seen := make(map[Column]bool)
+	for _, name := range strings.Split(s, ",") {
+		column, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, strings.TrimSpace(name))
+		}
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+// ParseMapping reads header:column pairs separated by commas, such as
+// "Тема:title,Начало:startAt".
+func ParseMapping(s string) (map[string]Column, error) {
+	mapping := make(map[string]Column)
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		header, name, ok := strings.Cut(pair, ":")
+		if !ok || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("%w: mapping %q must be header:column", ErrUnknownColumn, pair)
+		}
+		column, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, strings.TrimSpace(name))
+		}
+		mapping[strings.TrimSpace(header)] = column
+	}
+	return mapping, nil
+}
+
+func lookupColumn(name string) (Column, bool) {
+	key := normalize(name)
+	for _, column := range allColumns {
+		if normalize(string(column)) == key {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.TrimSpace(name)))
+}
+
+// value formats the column of the event. Times keep their offset, durations
+// use the Go syntax such as 1h30m0s, lists are separated by "; ".
+func value(event storage.Event, column Column) string {
+	switch column {
+	case ColumnID:
+		return event.ID
+	case ColumnTitle:
+		return event.Title
+	case ColumnStartAt:
+		return event.StartAt.Format(time.RFC3339)
+	case ColumnEndAt:
+		return event.EndAt.Format(time.RFC3339)
+	case ColumnDuration:
+		return event.EndAt.Sub(event.StartAt).String()
+	case ColumnDescription:
+		return event.Description
+	case ColumnUserID:
+		return event.UserID
+	case ColumnOrgID:
+		return event.OrgID
+	case ColumnNotifyBefore:
+		if event.NotifyBefore > 0 {
+			return event.NotifyBefore.String()
+		}
+	case ColumnReminders:
+		reminders := make([]string, 0, len(event.Reminders))
+		for _, r := range event.Reminders {
+			reminders = append(reminders, r.Before.String()+" "+r.Channel)
+		}
+		return strings.Join(reminders, "; ")
+	case ColumnAttachments:
+		names := make([]string, 0, len(event.Attachments))
+		for _, att := range event.Attachments {
+			names = append(names, att.Name)
+		}
+		return strings.Join(names, "; ")
+	case ColumnDeletedAt:
+		if event.Deleted() {
+			return event.DeletedAt.Format(time.RFC3339)
+		}
+	case ColumnSubscriptionID:
+		return event.SubscriptionID
+	}
+	return ""
+}
+
+// timeLayouts are tried in order, the ones without an offset are read in
+// the import location. The last ones are what spreadsheets usually write.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("not a date and time")
+}
+
+// parseEvent builds the event from the importable column values. The end
+// is endAt when both it and duration are set.
+func parseEvent(values map[Column]string, loc *time.Location) (storage.Event, error) {
+	event := storage.Event{
+		Title:       values[ColumnTitle],
+		Description: values[ColumnDescription],
+	}
+	invalid := func(column Column, err error) error {
+		return fmt.Errorf("%w: %s %q: %w", ErrInvalidValue, column, values[column], err)
+	}
+
+	var err error
+	if values[ColumnStartAt] == "" {
+		return storage.Event{}, fmt.Errorf("%w: %s is empty", ErrInvalidValue, ColumnStartAt)
+	}
+	if event.StartAt, err = parseTime(values[ColumnStartAt], loc); err != nil {
+		return storage.Event{}, invalid(ColumnStartAt, err)
+	}
+	switch {
+	case values[ColumnEndAt] != "":
+		if event.EndAt, err = parseTime(values[ColumnEndAt], loc); err != nil {
+			return storage.Event{}, invalid(ColumnEndAt, err)
+		}
+	case values[ColumnDuration] != "":
+		d, err := time.ParseDuration(values[ColumnDuration])
+		if err != nil {
+			return storage.Event{}, invalid(ColumnDuration, err)
+		}
+		event.EndAt = event.StartAt.Add(d)
+	default:
+		return storage.Event{}, fmt.Errorf("%w: %s and %s are empty", ErrInvalidValue, ColumnEndAt, ColumnDuration)
+	}
+
+	if s := values[ColumnNotifyBefore]; s != "" {
+		if event.NotifyBefore, err = time.ParseDuration(s); err != nil {
+			return storage.Event{}, invalid(ColumnNotifyBefore, err)
+		}
+	}
+	if event.Reminders, err = parseReminders(values[ColumnReminders]); err != nil {
+		return storage.Event{}, invalid(ColumnReminders, err)
+	}
+	return event, nil
+}
+
+// parseReminders reads "before channel" items separated by ";", the
+// channel defaults to the one of notifyBefore.
+func parseReminders(s string) ([]storage.Reminder, error) {
+	var reminders []storage.Reminder
+	for _, item := range strings.Split(s, ";") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("want before and channel, got %q", strings.TrimSpace(item))
+		}
+		before, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		r := storage.Reminder{Before: before, Channel: reminder.DefaultChannel}
+		if len(fields) == 2 {
+			r.Channel = fields[1]
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, nil
+}
@@ -0,0 +1,118 @@
+package tabular
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	events := []storage.Event{
+		{
+			ID:           "1",
+			Title:        "Планёрка, \"weekly\"",
+			Description:  "line one\nline two",
+			StartAt:      time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			EndAt:        time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+			NotifyBefore: 15 * time.Minute,
+			Reminders:    []storage.Reminder{{Before: time.Hour, Channel: "email"}},
+		},
+		{
+			ID:      "2",
+			Title:   "=1+2",
+			StartAt: time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC),
+			EndAt:   time.Date(2024, 3, 2, 18, 0, 0, 0, time.UTC),
+		},
+	}
+	columns, err := ParseColumns("id, title, start_at, Duration, description, notifyBefore, reminders")
+	require.NoError(t, err)
+
+	for _, format := range []Format{CSV, JSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, format, events, columns))
+			if format == CSV {
+				require.Contains(t, buf.String(), ",'=1+2,", "formulas are escaped")
+			}
+
+			table, err := Read(&buf, format, Options{})
+			require.NoError(t, err)
+			require.Equal(t, []string{"id"}, table.Ignored)
+			require.Len(t, table.Rows, 2)
+			for i, row := range table.Rows {
+				require.NoError(t, row.Err)
+				want := events[i]
+				want.ID = ""
+				require.Equal(t, want, row.Event)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	t.Run("mapping and spreadsheet values", func(t *testing.T) {
+		mapping, err := ParseMapping("Тема:title, Начало:startAt,Конец:endAt")
+		require.NoError(t, err)
+		csv := "Тема;Начало;Конец;Заметки\n" +
+			"Ретро;01.03.2024 10:00;01.03.2024 11:00;\n" +
+			";;;\n" +
+			"Демо;2024-03-01 12:00;soon;\n"
+
+		table, err := Read(strings.NewReader(strings.ReplaceAll(csv, ";", ",")), CSV,
+			Options{Mapping: mapping, Location: moscow})
+		require.NoError(t, err)
+		require.Equal(t, []string{"Заметки"}, table.Ignored)
+		require.Len(t, table.Rows, 2, "blank rows are skipped")
+
+		require.Equal(t, 2, table.Rows[0].Line)
+		require.NoError(t, table.Rows[0].Err)
+		require.Equal(t, "Ретро", table.Rows[0].Event.Title)
+		require.True(t, table.Rows[0].Event.StartAt.Equal(time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)))
+
+		require.Equal(t, 4, table.Rows[1].Line)
+		require.ErrorIs(t, table.Rows[1].Err, ErrInvalidValue)
+		require.ErrorContains(t, table.Rows[1].Err, "endAt")
+	})
+
+	t.Run("missing columns", func(t *testing.T) {
+		_, err := Read(strings.NewReader("title,startAt\nRetro,2024-03-01T10:00:00Z\n"), CSV, Options{})
+		require.ErrorIs(t, err, ErrMissingColumn)
+		_, err = Read(strings.NewReader(""), CSV, Options{})
+		require.ErrorIs(t, err, ErrMalformed)
+	})
+
+	t.Run("json values", func(t *testing.T) {
+		table, err := Read(strings.NewReader(`[
+			{"title": "Retro", "startAt": "2024-03-01T10:00:00Z", "duration": "1h", "reminders": "10m"},
+			{"title": "Demo", "startAt": 1709287200}
+		]`), JSON, Options{})
+		require.NoError(t, err)
+		require.Len(t, table.Rows, 2)
+		require.NoError(t, table.Rows[0].Err)
+		reminders := []storage.Reminder{{Before: 10 * time.Minute, Channel: "webhook"}}
+		require.Equal(t, reminders, table.Rows[0].Event.Reminders)
+		require.ErrorIs(t, table.Rows[1].Err, ErrInvalidValue)
+	})
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultColumns, columns)
+
+	columns, err = ParseColumns("Title,title,END_AT")
+	require.NoError(t, err)
+	require.Equal(t, []Column{ColumnTitle, ColumnEndAt}, columns)
+
+	_, err = ParseColumns("title,priority")
+	require.ErrorIs(t, err, ErrUnknownColumn)
+	_, err = ParseMapping("Тема=title")
+	require.ErrorIs(t, err, ErrUnknownColumn)
+}
@@ -0,0 +1,91 @@
+package tabular
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// bom makes spreadsheets read the CSV as UTF-8.
+const bom = "\ufeff"
+
+// Write writes a header of the columns and a row per event. JSON is an
+// array of objects with the columns as keys in the same order.
+func Write(w io.Writer, format Format, events []storage.Event, columns []Column) error {
+	if format == JSON {
+		return writeJSON(w, events, columns)
+	}
+	return writeCSV(w, events, columns)
+}
+
+func writeCSV(w io.Writer, events []storage.Event, columns []Column) error {
+	if _, err := io.WriteString(w, bom); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = string(column)
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, event := range events {
+		for i, column := range columns {
+			record[i] = escapeFormula(value(event, column))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, events []storage.Event, columns []Column) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[")
+	for i, event := range events {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n  {")
+		for j, column := range columns {
+			if j > 0 {
+				bw.WriteString(", ")
+			}
+			key, _ := json.Marshal(string(column))
+			val, _ := json.Marshal(value(event, column))
+			bw.Write(key)
+			bw.WriteString(": ")
+			bw.Write(val)
+		}
+		bw.WriteString("}")
+	}
+	if len(events) > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// escapeFormula keeps spreadsheets from running a cell as a formula by
+// prefixing it with a quote, unescapeFormula drops the prefix on import.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
@@ -0,0 +1,33 @@
+// Package tenant keeps settings of organizations sharing one deployment.
+// The organization of a request comes from its credentials, the empty ID
+// is the only organization of a single-tenant deployment.
+package tenant
+
+import "errors"
+
+var ErrQuotaExceeded = errors.New("organization quota exceeded")
+
+// Quota limits an organization, zero fields mean no limit.
+type Quota struct {
+	// MaxEvents counts live events, the trash does not count.
+	MaxEvents int
+	// MaxCalendars counts subscribed calendars. Every user has an own
+	// calendar besides them, it is limited by MaxEvents only.
+	MaxCalendars int
+}
+
+type Config struct {
+	// RequireOrg rejects users without an organization.
+	RequireOrg bool
+	// Default applies to organizations without a quota of their own.
+	Default Quota
+	Orgs    map[string]Quota
+}
+
+// Quota returns the quota of the organization.
+func (c Config) Quota(orgID string) Quota {
+	if quota, ok := c.Orgs[orgID]; ok {
+		return quota
+	}
+	return c.Default
+}
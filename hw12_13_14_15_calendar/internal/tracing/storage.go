@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Storage interface {
+	CreateEvent(ctx context.Context, event storage.Event) error
+	UpdateEvent(ctx context.Context, id string, event storage.Event) error
+	DeleteEvent(ctx context.Context, orgID, id string) error
+	GetEvent(ctx context.Context, orgID, id string) (storage.Event, error)
+	ListEvents(ctx context.Context, orgID, userID string, from, to time.Time) ([]storage.Event, error)
+	CountEvents(ctx context.Context, orgID string) (int, error)
+	ListStarting(ctx context.Context, from, to time.Time) ([]storage.Event, error)
+	ListDeleted(ctx context.Context, orgID, userID string) ([]storage.Event, error)
+	PurgeEvents(ctx context.Context, deletedBefore, endedBefore time.Time) (storage.PurgeResult, error)
+	AppendRevision(ctx context.Context, revision storage.Revision) (storage.Revision, error)
+	ListRevisions(ctx context.Context, orgID, eventID string) ([]storage.Revision, error)
+}
+
+// TracedStorage starts a span around every call to the wrapped storage.
+type TracedStorage struct {
+	next Storage
+}
+
+func NewTracedStorage(next Storage) *TracedStorage {
+	return &TracedStorage{next: next}
+}
+
+func (s *TracedStorage) CreateEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := startSpan(ctx, "create",
+		attribute.String("org.id", event.OrgID),
+		attribute.String("event.id", event.ID),
+	)
+	defer func() { end(span, err) }()
+	return s.next.CreateEvent(ctx, event)
+}
+
+func (s *TracedStorage) UpdateEvent(ctx context.Context, id string, event storage.Event) (err error) {
+	ctx, span := startSpan(ctx, "update",
+		attribute.String("org.id", event.OrgID),
+		attribute.String("event.id", id),
+	)
+	defer func() { end(span, err) }()
+	return s.next.UpdateEvent(ctx, id, event)
+}
+
+func (s *TracedStorage) DeleteEvent(ctx context.Context, orgID, id string) (err error) {
+	ctx, span := startSpan(ctx, "delete", attribute.String("org.id", orgID), attribute.String("event.id", id))
+	defer func() { end(span, err) }()
+	return s.next.DeleteEvent(ctx, orgID, id)
+}
+
+func (s *TracedStorage) GetEvent(ctx context.Context, orgID, id string) (_ storage.Event, err error) {
+	ctx, span := startSpan(ctx, "get", attribute.String("org.id", orgID), attribute.String("event.id", id))
+	defer func() { end(span, err) }()
+	return s.next.GetEvent(ctx, orgID, id)
+}
+
+func (s *TracedStorage) ListEvents(
+	ctx context.Context, orgID, userID string, from, to time.Time,
+) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "list",
+		attribute.String("org.id", orgID),
+		attribute.String("user.id", userID),
+		attribute.String("range.from", from.Format(time.RFC3339)),
+		attribute.String("range.to", to.Format(time.RFC3339)),
+	)
+	defer func() { end(span, err) }()
+	return s.next.ListEvents(ctx, orgID, userID, from, to)
+}
+
+func (s *TracedStorage) CountEvents(ctx context.Context, orgID string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "count", attribute.String("org.id", orgID))
+	defer func() { end(span, err) }()
+	return s.next.CountEvents(ctx, orgID)
+}
+
+func (s *TracedStorage) ListStarting(ctx context.Context, from, to time.Time) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "list_starting",
+		attribute.String("range.from", from.Format(time.RFC3339)),
+		attribute.String("range.to", to.Format(time.RFC3339)),
+	)
+	defer func() { end(span, err) }()
+	return s.next.ListStarting(ctx, from, to)
+}
+
+func (s *TracedStorage) ListDeleted(ctx context.Context, orgID, userID string) (_ []storage.Event, err error) {
+	ctx, span := startSpan(ctx, "list_deleted",
+		attribute.String("org.id", orgID),
+		attribute.String("user.id", userID),
+	)
+	defer func() { end(span, err) }()
+	return s.next.ListDeleted(ctx, orgID, userID)
+}
+
+func (s *TracedStorage) PurgeEvents(
+	ctx context.Context, deletedBefore, endedBefore time.Time,
+) (result storage.PurgeResult, err error) {
+	ctx, span := startSpan(ctx, "purge",
+		attribute.String("deleted.before", deletedBefore.Format(time.RFC3339)),
+		attribute.String("ended.before", endedBefore.Format(time.RFC3339)),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("purged.trashed", result.Trashed),
+			attribute.Int("purged.expired", result.Expired),
+			attribute.Int("purged.attachments", len(result.Attachments)),
+		)
+		end(span, err)
+	}()
+	return s.next.PurgeEvents(ctx, deletedBefore, endedBefore)
+}
+
+func (s *TracedStorage) AppendRevision(
+	ctx context.Context, revision storage.Revision,
+) (_ storage.Revision, err error) {
+	ctx, span := startSpan(ctx, "append_revision",
+		attribute.String("org.id", revision.OrgID),
+		attribute.String("event.id", revision.EventID),
+		attribute.String("revision.action", string(revision.Action)),
+	)
+	defer func() { end(span, err) }()
+	return s.next.AppendRevision(ctx, revision)
+}
+
+func (s *TracedStorage) ListRevisions(
+	ctx context.Context, orgID, eventID string,
+) (_ []storage.Revision, err error) {
+	ctx, span := startSpan(ctx, "list_revisions",
+		attribute.String("org.id", orgID),
+		attribute.String("event.id", eventID),
+	)
+	defer func() { end(span, err) }()
+	return s.next.ListRevisions(ctx, orgID, eventID)
+}
+
+func startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	_, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+
+	t.Run("storage spans", func(t *testing.T) {
+		s := NewTracedStorage(memorystorage.New())
+		ctx, parent := Tracer().Start(context.Background(), "request")
+
+		start := time.Now()
+		event := storage.Event{ID: "1", UserID: "user", StartAt: start, EndAt: start.Add(time.Hour)}
+		require.NoError(t, s.CreateEvent(ctx, event))
+		require.ErrorIs(t, s.DeleteEvent(ctx, "", "2"), storage.ErrEventNotFound)
+		parent.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 3)
+		require.Equal(t, "storage.create", spans[0].Name())
+		require.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+		require.Equal(t, "storage.delete", spans[1].Name())
+		require.Equal(t, codes.Error, spans[1].Status().Code)
+	})
+
+	t.Run("inject and extract", func(t *testing.T) {
+		ctx, span := Tracer().Start(context.Background(), "write")
+		defer span.End()
+
+		carrier := Inject(ctx)
+		require.Contains(t, carrier, "traceparent")
+
+		remote := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+		require.True(t, remote.IsRemote())
+		require.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+
+		require.Nil(t, Inject(context.Background()))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), Config{Exporter: "zipkin"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,487 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/egress"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const metricsChannel = "webhook"
+
+type EventType string
+
+const (
+	EventCreated         EventType = "event.created"
+	EventUpdated         EventType = "event.updated"
+	EventDeleted         EventType = "event.deleted"
+	EventNotificationDue EventType = "notification.due"
+	EventDigestDue       EventType = "digest.due"
+)
+
+var ErrNotRunning = errors.New("webhook dispatcher is not running")
+
+// errStopped finishes the deliveries still queued when Run returns.
+var errStopped = errors.New("dispatcher stopped before delivery")
+
+var changeEventTypes = map[feed.ChangeType]EventType{
+	feed.ChangeCreated: EventCreated,
+	feed.ChangeUpdated: EventUpdated,
+	feed.ChangeDeleted: EventDeleted,
+}
+
+type Config struct {
+	Workers       int
+	QueueSize     int
+	MaxAttempts   int
+	RetryInterval time.Duration
+	Timeout       time.Duration
+	LogSize       int
+	// AllowPrivate lets webhooks point to loopback and private addresses.
+	AllowPrivate bool `toml:"allow_private"`
+}
+
+type Logger interface {
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+// Source is the change feed webhooks are delivered from.
+type Source interface {
+	Subscribe(ctx context.Context, orgID, userID string, lastID uint64) ([]feed.Change, <-chan feed.Change, error)
+	LastID() uint64
+}
+
+type payload struct {
+	ID         string           `json:"id"`
+	Type       EventType        `json:"type"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	OrgID      string           `json:"orgId,omitempty"`
+	UserID     string           `json:"userId"`
+	Event      *payloadEvent    `json:"event,omitempty"`
+	Reminder   *payloadReminder `json:"reminder,omitempty"`
+	Digest     *payloadDigest   `json:"digest,omitempty"`
+}
+
+type payloadEvent struct {
+	ID           string            `json:"id"`
+	Title        string            `json:"title"`
+	StartAt      time.Time         `json:"startAt"`
+	EndAt        time.Time         `json:"endAt"`
+	Description  string            `json:"description,omitempty"`
+	NotifyBefore string            `json:"notifyBefore,omitempty"`
+	Reminders    []payloadReminder `json:"reminders,omitempty"`
+}
+
+type payloadReminder struct {
+	Before  string `json:"before"`
+	Channel string `json:"channel"`
+}
+
+type payloadDigest struct {
+	Period  string    `json:"period"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Events  int       `json:"events"`
+	Format  string    `json:"format"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+}
+
+type job struct {
+	hook     Webhook
+	delivery Delivery
+	body     []byte
+	trace    map[string]string
+}
+
+// Dispatcher delivers signed event payloads to registered webhooks.
+type Dispatcher struct {
+	logger   Logger
+	mu       sync.RWMutex
+	config   Config
+	client   *http.Client
+	registry *registry
+	queue    chan job
+	running  atomic.Bool
+}
+
+func NewDispatcher(logger Logger, config Config) *Dispatcher {
+	d := &Dispatcher{
+		logger:   logger,
+		config:   config,
+		registry: newRegistry(config.LogSize),
+		queue:    make(chan job, config.QueueSize),
+	}
+	d.client = egress.NewClient(func() bool { return d.currentConfig().AllowPrivate })
+	return d
+}
+
+func (d *Dispatcher) Register(_ context.Context, orgID, userID, url, secret string) (Webhook, error) {
+	return d.registry.add(orgID, userID, url, secret)
+}
+
+func (d *Dispatcher) List(_ context.Context, orgID, userID string) ([]Webhook, error) {
+	return d.registry.byUser(orgID, userID), nil
+}
+
+func (d *Dispatcher) Delete(_ context.Context, orgID, userID, id string) error {
+	return d.registry.remove(orgID, userID, id)
+}
+
+func (d *Dispatcher) Deliveries(_ context.Context, orgID, userID, id string) ([]Delivery, error) {
+	return d.registry.recent(orgID, userID, id)
+}
+
+// ExportWebhooks calls fn for every webhook of all users. Delivery logs are
+// not exported.
+func (d *Dispatcher) ExportWebhooks(_ context.Context, fn func(Webhook) error) error {
+	for _, hook := range d.registry.all() {
+		if err := fn(hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportWebhook registers the webhook keeping its ID and secret.
+func (d *Dispatcher) ImportWebhook(_ context.Context, hook Webhook) error {
+	return d.registry.restore(hook)
+}
+
+// NotificationDue sends the notification.due payload for the event reminder
+// to the webhooks of its owner.
+func (d *Dispatcher) NotificationDue(ctx context.Context, event storage.Event, reminder storage.Reminder) {
+	r := newPayloadReminder(reminder)
+	d.enqueue(ctx, payload{
+		Type:       EventNotificationDue,
+		OccurredAt: time.Now(),
+		OrgID:      event.OrgID,
+		UserID:     event.UserID,
+		Event:      newPayloadEvent(event),
+		Reminder:   &r,
+	}, tracing.Inject(ctx))
+}
+
+// DigestDue sends the digest.due payload with the rendered agenda to the
+// webhooks of its user.
+func (d *Dispatcher) DigestDue(ctx context.Context, dg digest.Digest) {
+	d.enqueue(ctx, payload{
+		Type:       EventDigestDue,
+		OccurredAt: time.Now(),
+		OrgID:      dg.OrgID,
+		UserID:     dg.UserID,
+		Digest: &payloadDigest{
+			Period:  string(dg.Period),
+			From:    dg.From,
+			To:      dg.To,
+			Events:  dg.Events,
+			Format:  string(dg.Format),
+			Subject: dg.Subject,
+			Body:    dg.Body,
+		},
+	}, tracing.Inject(ctx))
+}
+
+// Reconfigure applies new retry settings, request timeout and address
+// restriction to the following delivery attempts. Workers, queue and log
+// sizes are fixed at construction.
+func (d *Dispatcher) Reconfigure(config Config) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.config.MaxAttempts = config.MaxAttempts
+	d.config.RetryInterval = config.RetryInterval
+	d.config.Timeout = config.Timeout
+	d.config.AllowPrivate = config.AllowPrivate
+}
+
+func (d *Dispatcher) currentConfig() Config {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.config
+}
+
+// QueueStats describes the delivery queue and the logged deliveries.
+type QueueStats struct {
+	Webhooks int
+	// Depth is the number of deliveries waiting for a worker, at most Capacity.
+	Depth    int
+	Capacity int
+	// Pending and Failed count deliveries in the logs of the webhooks,
+	// pending ones are queued, in flight or waiting for a retry.
+	Pending int
+	Failed  int
+}
+
+func (d *Dispatcher) QueueStats() QueueStats {
+	stats := d.registry.stats()
+	stats.Depth = len(d.queue)
+	stats.Capacity = cap(d.queue)
+	return stats
+}
+
+// Ping reports whether Run is delivering changes.
+func (d *Dispatcher) Ping(_ context.Context) error {
+	if !d.running.Load() {
+		return ErrNotRunning
+	}
+	return nil
+}
+
+// Run delivers changes from source until ctx is done. Deliveries still
+// queued then are finished as failed, nothing delivers them after a restart.
+func (d *Dispatcher) Run(ctx context.Context, source Source) error {
+	d.running.Store(true)
+	defer d.running.Store(false)
+	defer d.abandon()
+
+	for i := 0; i < d.currentConfig().Workers; i++ {
+		go d.worker(ctx)
+	}
+
+	lastID := source.LastID()
+	for {
+		backlog, changes, err := source.Subscribe(ctx, "", "", lastID)
+		if errors.Is(err, feed.ErrChangeExpired) {
+			d.logger.Warn(fmt.Sprintf("webhooks: changes after %d are lost, skipping to the latest", lastID))
+			lastID = source.LastID()
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("subscribe to changes: %w", err)
+		}
+
+		for _, change := range backlog {
+			d.handleChange(ctx, change)
+			lastID = change.ID
+		}
+		for change := range changes {
+			d.handleChange(ctx, change)
+			lastID = change.ID
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) handleChange(ctx context.Context, change feed.Change) {
+	d.enqueue(ctx, payload{
+		Type:       changeEventTypes[change.Type],
+		OccurredAt: change.At,
+		OrgID:      change.OrgID,
+		UserID:     change.UserID,
+		Event:      newPayloadEvent(change.Event),
+	}, change.Trace)
+}
+
+// enqueue queues the payload for every webhook of its user, each delivery
+// under its own ID.
+func (d *Dispatcher) enqueue(ctx context.Context, p payload, carrier map[string]string) {
+	for _, hook := range d.registry.byUser(p.OrgID, p.UserID) {
+		delivery := Delivery{
+			ID:        uuid.NewString(),
+			WebhookID: hook.ID,
+			EventType: p.Type,
+			Status:    DeliveryPending,
+			CreatedAt: time.Now(),
+		}
+
+		p.ID = delivery.ID
+		body, err := json.Marshal(p)
+		if err != nil {
+			d.logger.Error("webhooks: failed to encode payload: " + err.Error())
+			continue
+		}
+
+		d.registry.log(delivery)
+		select {
+		case d.queue <- job{hook: hook, delivery: delivery, body: body, trace: carrier}:
+			metrics.DeliveryQueueDepth.WithLabelValues(metricsChannel).Inc()
+		case <-ctx.Done():
+			d.finish(delivery, errStopped)
+			return
+		}
+	}
+}
+
+// abandon finishes the queued deliveries as failed.
+func (d *Dispatcher) abandon() {
+	abandoned := 0
+	for {
+		select {
+		case j := <-d.queue:
+			metrics.DeliveryQueueDepth.WithLabelValues(metricsChannel).Dec()
+			d.finish(j.delivery, errStopped)
+			abandoned++
+		default:
+			if abandoned > 0 {
+				d.logger.Warn(fmt.Sprintf("webhooks: %d queued deliveries failed on shutdown", abandoned))
+			}
+			return
+		}
+	}
+}
+
+// finish logs the delivery as failed with err without sending it.
+func (d *Dispatcher) finish(delivery Delivery, err error) {
+	delivery.Status = DeliveryFailed
+	delivery.Error = err.Error()
+	delivery.FinishedAt = time.Now()
+	d.registry.log(delivery)
+	metrics.Deliveries.WithLabelValues(metricsChannel, string(delivery.Status)).Inc()
+}
+
+func (d *Dispatcher) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-d.queue:
+			metrics.DeliveryQueueDepth.WithLabelValues(metricsChannel).Dec()
+			metrics.DeliveryQueueLag.WithLabelValues(metricsChannel).Observe(time.Since(j.delivery.CreatedAt).Seconds())
+			d.deliver(ctx, j)
+		}
+	}
+}
+
+// deliver sends the job retrying with exponential backoff on network
+// errors, 429 and 5xx responses. The delivery stays pending with the last
+// error until it succeeds or the final attempt fails. The delivery span
+// continues the trace of the change and is propagated to the receiver.
+func (d *Dispatcher) deliver(ctx context.Context, j job) {
+	ctx, span := tracing.Tracer().Start(tracing.Extract(ctx, j.trace), "webhook.deliver",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("webhook.id", j.hook.ID),
+			attribute.String("webhook.event_type", string(j.delivery.EventType)),
+			attribute.String("webhook.delivery_id", j.delivery.ID),
+		),
+	)
+	defer span.End()
+
+	config := d.currentConfig()
+	delivery := j.delivery
+	interval := config.RetryInterval
+
+	for delivery.Attempts < config.MaxAttempts {
+		delivery.Attempts++
+		status, err := d.send(ctx, j, config.Timeout)
+		delivery.StatusCode = status
+
+		if err == nil {
+			delivery.Status = DeliverySucceeded
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+		if !retryable(status) || delivery.Attempts == config.MaxAttempts {
+			delivery.Status = DeliveryFailed
+			break
+		}
+
+		d.registry.log(delivery)
+		if !sleep(ctx, interval) {
+			// Shutting down: the delivery is finished as failed rather
+			// than left pending, nothing retries it after a restart.
+			delivery.Status = DeliveryFailed
+			delivery.Error = ctx.Err().Error()
+			break
+		}
+		interval *= 2
+	}
+
+	delivery.FinishedAt = time.Now()
+	d.registry.log(delivery)
+	metrics.Deliveries.WithLabelValues(metricsChannel, string(delivery.Status)).Inc()
+	span.SetAttributes(attribute.Int("webhook.attempts", delivery.Attempts))
+	if delivery.Status == DeliveryFailed {
+		span.SetStatus(codes.Error, delivery.Error)
+		d.logger.Warn(fmt.Sprintf("webhooks: delivery %s to %s failed after %d attempts: %s",
+			delivery.ID, j.hook.URL, delivery.Attempts, delivery.Error))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, j job, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.hook.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(j.delivery.EventType))
+	req.Header.Set(HeaderDelivery, j.delivery.ID)
+	timestamp := time.Now().Unix()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(j.hook.Secret, timestamp, j.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// sleep waits for d and reports false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func newPayloadEvent(event storage.Event) *payloadEvent {
+	result := &payloadEvent{
+		ID:          event.ID,
+		Title:       event.Title,
+		StartAt:     event.StartAt,
+		EndAt:       event.EndAt,
+		Description: event.Description,
+	}
+	if event.NotifyBefore > 0 {
+		result.NotifyBefore = event.NotifyBefore.String()
+	}
+	for _, reminder := range event.Reminders {
+		result.Reminders = append(result.Reminders, newPayloadReminder(reminder))
+	}
+	return result
+}
+
+func newPayloadReminder(reminder storage.Reminder) payloadReminder {
+	return payloadReminder{Before: reminder.Before.String(), Channel: reminder.Channel}
+}
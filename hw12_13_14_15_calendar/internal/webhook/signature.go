@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Calendar-Event"
+	HeaderDelivery  = "X-Calendar-Delivery"
+	HeaderTimestamp = "X-Calendar-Timestamp"
+	HeaderSignature = "X-Calendar-Signature"
+
+	signaturePrefix = "sha256="
+
+	// DefaultMaxAge is the timestamp tolerance suggested to receivers. It
+	// leaves room for clock skew and the delivery timeout.
+	DefaultMaxAge = 5 * time.Minute
+)
+
+// Sign returns the value of the signature header: HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret. Including the
+// timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature header of a received delivery. Deliveries
+// with a timestamp further than maxAge from now either way are rejected,
+// so a captured delivery cannot be replayed later.
+func Verify(secret string, timestamp int64, body []byte, signature string, maxAge time.Duration, now time.Time) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > maxAge || age < -maxAge {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidURL      = errors.New("webhook url must be an absolute http(s) url")
+	ErrWebhookExists   = errors.New("webhook already exists")
+)
+
+type Webhook struct {
+	ID        string
+	OrgID     string
+	UserID    string
+	URL       string
+	Secret    string
+	CreatedAt time.Time
+}
+
+func (w Webhook) ownedBy(orgID, userID string) bool {
+	return w.OrgID == orgID && w.UserID == userID
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+type Delivery struct {
+	ID         string
+	WebhookID  string
+	EventType  EventType
+	Status     DeliveryStatus
+	Attempts   int
+	StatusCode int
+	Error      string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// registry keeps webhooks and the log of their last deliveries in memory.
+type registry struct {
+	mu         sync.RWMutex
+	hooks      map[string]Webhook
+	deliveries map[string][]Delivery
+	logSize    int
+}
+
+func newRegistry(logSize int) *registry {
+	return &registry{
+		hooks:      make(map[string]Webhook),
+		deliveries: make(map[string][]Delivery),
+		logSize:    logSize,
+	}
+}
+
+func (r *registry) add(orgID, userID, rawURL, secret string) (Webhook, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if secret == "" {
+		if secret, err = newSecret(); err != nil {
+			return Webhook{}, err
+		}
+	}
+
+	hook := Webhook{
+		ID:        uuid.NewString(),
+		OrgID:     orgID,
+		UserID:    userID,
+		URL:       u.String(),
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[hook.ID] = hook
+	return hook, nil
+}
+
+// restore adds the webhook keeping its ID and secret.
+func (r *registry) restore(hook Webhook) error {
+	if _, err := parseURL(hook.URL); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.hooks[hook.ID]; ok {
+		return ErrWebhookExists
+	}
+	r.hooks[hook.ID] = hook
+	return nil
+}
+
+func (r *registry) all() []Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Webhook, 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		result = append(result, hook)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func (r *registry) get(orgID, userID, id string) (Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hook, ok := r.hooks[id]
+	if !ok || !hook.ownedBy(orgID, userID) {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return hook, nil
+}
+
+func (r *registry) remove(orgID, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook, ok := r.hooks[id]
+	if !ok || !hook.ownedBy(orgID, userID) {
+		return ErrWebhookNotFound
+	}
+	delete(r.hooks, id)
+	delete(r.deliveries, id)
+	return nil
+}
+
+func (r *registry) byUser(orgID, userID string) []Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Webhook, 0)
+	for _, hook := range r.hooks {
+		if hook.ownedBy(orgID, userID) {
+			result = append(result, hook)
+		}
+	}
+	return result
+}
+
+// stats counts webhooks and logged deliveries by status.
+func (r *registry) stats() QueueStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := QueueStats{Webhooks: len(r.hooks)}
+	for _, log := range r.deliveries {
+		for _, delivery := range log {
+			switch delivery.Status {
+			case DeliveryPending:
+				stats.Pending++
+			case DeliveryFailed:
+				stats.Failed++
+			case DeliverySucceeded:
+			}
+		}
+	}
+	return stats
+}
+
+// log stores the delivery, replacing the previous record with the same ID.
+func (r *registry) log(delivery Delivery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[delivery.WebhookID]; !ok {
+		return
+	}
+
+	log := r.deliveries[delivery.WebhookID]
+	for i := range log {
+		if log[i].ID == delivery.ID {
+			log[i] = delivery
+			return
+		}
+	}
+
+	log = append(log, delivery)
+	if len(log) > r.logSize {
+		log = log[len(log)-r.logSize:]
+	}
+	r.deliveries[delivery.WebhookID] = log
+}
+
+// recent returns deliveries of the webhook, newest first.
+func (r *registry) recent(orgID, userID, id string) ([]Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hook, ok := r.hooks[id]
+	if !ok || !hook.ownedBy(orgID, userID) {
+		return nil, ErrWebhookNotFound
+	}
+
+	log := r.deliveries[id]
+	result := make([]Delivery, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		result = append(result, log[i])
+	}
+	return result, nil
+}
+
+func parseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	return u, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,349 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/digest"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/egress"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/feed"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver answers with the given statuses in turn, then with 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, received{header: r.Header.Clone(), body: body})
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) first() (received, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.requests) == 0 {
+		return received{}, false
+	}
+	return rc.requests[0], true
+}
+
+func newTestDispatcher() *Dispatcher {
+	return NewDispatcher(logger.NewWithWriter("ERROR", io.Discard), Config{
+		Workers:       1,
+		QueueSize:     10,
+		MaxAttempts:   3,
+		RetryInterval: 10 * time.Millisecond,
+		Timeout:       time.Second,
+		LogSize:       10,
+		AllowPrivate:  true,
+	})
+}
+
+// waitDeliveries waits until all deliveries of the webhook are finished.
+func waitDeliveries(t *testing.T, d *Dispatcher, userID, hookID string) []Delivery {
+	t.Helper()
+
+	var deliveries []Delivery
+	require.Eventually(t, func() bool {
+		var err error
+		deliveries, err = d.Deliveries(context.Background(), "", userID, hookID)
+		require.NoError(t, err)
+		for _, delivery := range deliveries {
+			if delivery.FinishedAt.IsZero() {
+				return false
+			}
+		}
+		return len(deliveries) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	return deliveries
+}
+
+func TestDispatcher(t *testing.T) {
+	event := storage.Event{ID: "event", Title: "standup", UserID: "user"}
+
+	t.Run("signed delivery with retries", func(t *testing.T) {
+		rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests}}
+		ts := httptest.NewServer(rc)
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		d := newTestDispatcher()
+		hook, err := d.Register(ctx, "", "user", ts.URL, "secret")
+		require.NoError(t, err)
+		_, err = d.Register(ctx, "", "other", ts.URL, "")
+		require.NoError(t, err)
+
+		// Run subscribes asynchronously, publish until the first request arrives.
+		broker := feed.NewBroker(10, 10)
+		go d.Run(ctx, broker)
+		var req received
+		require.Eventually(t, func() bool {
+			var ok bool
+			if req, ok = rc.first(); !ok {
+				broker.Publish(feed.Change{Type: feed.ChangeCreated, UserID: "user", Event: event})
+			}
+			return ok
+		}, time.Second, 50*time.Millisecond)
+
+		deliveries := waitDeliveries(t, d, "user", hook.ID)
+		oldest := deliveries[len(deliveries)-1]
+		require.Equal(t, EventCreated, oldest.EventType)
+		require.Equal(t, DeliverySucceeded, oldest.Status)
+		require.Equal(t, 3, oldest.Attempts)
+		require.Equal(t, http.StatusOK, oldest.StatusCode)
+
+		timestamp, err := strconv.ParseInt(req.header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		signature := req.header.Get(HeaderSignature)
+		now := time.Now()
+		require.True(t, Verify("secret", timestamp, req.body, signature, DefaultMaxAge, now))
+		require.False(t, Verify("wrong", timestamp, req.body, signature, DefaultMaxAge, now))
+		require.False(t, Verify("secret", timestamp, req.body, signature, DefaultMaxAge, now.Add(time.Hour)),
+			"a stale delivery is a replay")
+		require.False(t, Verify("secret", timestamp, req.body, signature, DefaultMaxAge, now.Add(-time.Hour)),
+			"a delivery from the future is rejected too")
+		require.Equal(t, string(EventCreated), req.header.Get(HeaderEvent))
+
+		var p payload
+		require.NoError(t, json.Unmarshal(req.body, &p))
+		require.Equal(t, "user", p.UserID)
+		require.Equal(t, "standup", p.Event.Title)
+		require.Equal(t, req.header.Get(HeaderDelivery), p.ID)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		rc := &receiver{statuses: []int{http.StatusBadRequest}}
+		ts := httptest.NewServer(rc)
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		d := newTestDispatcher()
+		hook, err := d.Register(ctx, "", "user", ts.URL, "")
+		require.NoError(t, err)
+		require.Len(t, hook.Secret, 64)
+
+		go d.worker(ctx)
+		d.NotificationDue(ctx, event, storage.Reminder{Before: time.Hour, Channel: "webhook"})
+
+		deliveries := waitDeliveries(t, d, "user", hook.ID)
+		require.Equal(t, DeliveryFailed, deliveries[0].Status)
+		require.Equal(t, EventNotificationDue, deliveries[0].EventType)
+		require.Equal(t, 1, deliveries[0].Attempts)
+		require.Equal(t, http.StatusBadRequest, deliveries[0].StatusCode)
+	})
+
+	t.Run("shutdown during backoff finishes the delivery", func(t *testing.T) {
+		rc := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+		ts := httptest.NewServer(rc)
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		d := newTestDispatcher()
+		d.Reconfigure(Config{MaxAttempts: 3, RetryInterval: time.Hour, Timeout: time.Second, AllowPrivate: true})
+		hook, err := d.Register(ctx, "", "user", ts.URL, "")
+		require.NoError(t, err)
+		go d.worker(ctx)
+
+		d.NotificationDue(ctx, event, storage.Reminder{Before: time.Hour, Channel: "webhook"})
+		require.Eventually(t, func() bool {
+			deliveries, err := d.Deliveries(ctx, "", "user", hook.ID)
+			require.NoError(t, err)
+			return deliveries[0].Attempts == 1
+		}, time.Second, 10*time.Millisecond)
+		deliveries, err := d.Deliveries(ctx, "", "user", hook.ID)
+		require.NoError(t, err)
+		require.Equal(t, DeliveryPending, deliveries[0].Status, "pending until the last attempt")
+		require.Equal(t, 1, d.QueueStats().Pending)
+		cancel()
+
+		deliveries = waitDeliveries(t, d, "user", hook.ID)
+		require.Equal(t, DeliveryFailed, deliveries[0].Status)
+		require.Equal(t, 1, deliveries[0].Attempts)
+		require.Equal(t, context.Canceled.Error(), deliveries[0].Error)
+	})
+
+	t.Run("queued deliveries fail on shutdown", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		d := newTestDispatcher()
+		hook, err := d.Register(ctx, "", "user", "http://127.0.0.1:1", "")
+		require.NoError(t, err)
+
+		// No worker runs yet, the notifications stay in the queue.
+		d.NotificationDue(ctx, event, storage.Reminder{Before: time.Hour, Channel: "webhook"})
+		d.NotificationDue(ctx, event, storage.Reminder{Before: time.Minute, Channel: "webhook"})
+		require.Equal(t, 2, d.QueueStats().Depth)
+
+		cancel()
+		require.NoError(t, d.Run(ctx, feed.NewBroker(10, 10)))
+
+		deliveries := waitDeliveries(t, d, "user", hook.ID)
+		require.Len(t, deliveries, 2)
+		for _, delivery := range deliveries {
+			require.Equal(t, DeliveryFailed, delivery.Status)
+		}
+		stats := d.QueueStats()
+		require.Zero(t, stats.Depth)
+		require.Zero(t, stats.Pending)
+		require.Equal(t, 2, stats.Failed)
+	})
+
+	t.Run("private addresses are refused", func(t *testing.T) {
+		rc := &receiver{}
+		ts := httptest.NewServer(rc)
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		d := newTestDispatcher()
+		d.Reconfigure(Config{MaxAttempts: 3, RetryInterval: time.Millisecond, Timeout: time.Second})
+		hook, err := d.Register(ctx, "", "user", ts.URL, "")
+		require.NoError(t, err)
+		go d.worker(ctx)
+
+		d.NotificationDue(ctx, event, storage.Reminder{Before: time.Hour, Channel: "webhook"})
+		deliveries := waitDeliveries(t, d, "user", hook.ID)
+		require.Equal(t, DeliveryFailed, deliveries[0].Status)
+		require.Contains(t, deliveries[0].Error, egress.ErrForbiddenAddress.Error())
+		_, ok := rc.first()
+		require.False(t, ok)
+	})
+
+	t.Run("digest payload", func(t *testing.T) {
+		rc := &receiver{}
+		ts := httptest.NewServer(rc)
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		d := newTestDispatcher()
+		hook, err := d.Register(ctx, "", "user", ts.URL, "")
+		require.NoError(t, err)
+		go d.worker(ctx)
+
+		d.DigestDue(ctx, digest.Digest{
+			UserID:  "user",
+			Period:  digest.PeriodDaily,
+			Format:  digest.FormatText,
+			Events:  1,
+			Subject: "Your agenda",
+			Body:    "standup",
+		})
+		deliveries := waitDeliveries(t, d, "user", hook.ID)
+		require.Equal(t, EventDigestDue, deliveries[0].EventType)
+
+		req, ok := rc.first()
+		require.True(t, ok)
+		var p payload
+		require.NoError(t, json.Unmarshal(req.body, &p))
+		require.Nil(t, p.Event)
+		require.Equal(t, "Your agenda", p.Digest.Subject)
+		require.Equal(t, "daily", p.Digest.Period)
+	})
+
+	t.Run("trace context reaches the receiver", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		_, err := tracing.Setup(context.Background(), tracing.Config{})
+		require.NoError(t, err)
+
+		rc := &receiver{}
+		ts := httptest.NewServer(rc)
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		d := newTestDispatcher()
+		hook, err := d.Register(ctx, "", "user", ts.URL, "")
+		require.NoError(t, err)
+		go d.worker(ctx)
+
+		writeCtx, write := tracing.Tracer().Start(ctx, "write")
+		d.NotificationDue(writeCtx, event, storage.Reminder{Before: time.Hour, Channel: "webhook"})
+		write.End()
+		waitDeliveries(t, d, "user", hook.ID)
+
+		req, ok := rc.first()
+		require.True(t, ok)
+		traceID := write.SpanContext().TraceID().String()
+		require.Contains(t, req.header.Get("traceparent"), traceID)
+
+		require.Eventually(t, func() bool {
+			for _, span := range recorder.Ended() {
+				if span.Name() == "webhook.deliver" {
+					return span.SpanContext().TraceID().String() == traceID
+				}
+			}
+			return false
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		ctx := context.Background()
+		d := newTestDispatcher()
+
+		_, err := d.Register(ctx, "", "user", "ftp://example.com", "")
+		require.ErrorIs(t, err, ErrInvalidURL)
+		_, err = d.Register(ctx, "", "user", "/relative", "")
+		require.ErrorIs(t, err, ErrInvalidURL)
+
+		hook, err := d.Register(ctx, "", "user", "http://example.com/hook", "")
+		require.NoError(t, err)
+
+		_, err = d.Deliveries(ctx, "", "other", hook.ID)
+		require.ErrorIs(t, err, ErrWebhookNotFound)
+		require.ErrorIs(t, d.Delete(ctx, "", "other", hook.ID), ErrWebhookNotFound)
+		require.ErrorIs(t, d.Delete(ctx, "acme", "user", hook.ID), ErrWebhookNotFound)
+		hooks, err := d.List(ctx, "acme", "user")
+		require.NoError(t, err)
+		require.Empty(t, hooks, "same user ID in another organization")
+
+		hooks, err = d.List(ctx, "", "user")
+		require.NoError(t, err)
+		require.Len(t, hooks, 1)
+
+		require.NoError(t, d.Delete(ctx, "", "user", hook.ID))
+		hooks, err = d.List(ctx, "", "user")
+		require.NoError(t, err)
+		require.Empty(t, hooks)
+	})
+}